@@ -7,8 +7,11 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/blueprint"
 	"github.com/yansircc/locwp/internal/config"
-	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/secrets"
 	"github.com/yansircc/locwp/internal/site"
 	"github.com/yansircc/locwp/internal/template"
 )
@@ -19,6 +22,8 @@ var (
 	flagAdminUser  string
 	flagAdminPass  string
 	flagAdminEmail string
+	flagBlueprint  string
+	flagAddTags    []string
 )
 
 var addCmd = &cobra.Command{
@@ -26,92 +31,214 @@ var addCmd = &cobra.Command{
 	Short: "Add a new local WordPress site",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		baseDir := config.BaseDir()
-
-		// Allocate next available port
-		port := config.NextPort(baseDir)
-		portStr := strconv.Itoa(port)
-		siteDir := filepath.Join(baseDir, "sites", portStr)
-
-		if _, err := os.Stat(siteDir); err == nil {
-			return fmt.Errorf("site %s already exists", portStr)
-		}
-
-		sc := &site.Config{
-			Port:       port,
-			PHP:        flagPHP,
-			WPVer:      "latest",
-			SiteDir:    siteDir,
-			WPRoot:     filepath.Join(siteDir, "wordpress"),
-			AdminUser:  flagAdminUser,
-			AdminPass:  flagAdminPass,
-			AdminEmail: flagAdminEmail,
+		sc, err := newSiteConfig(flagPHP)
+		if err != nil {
+			return err
 		}
-
-		// Create directories
-		for _, d := range []string{sc.WPRoot, filepath.Join(siteDir, "logs")} {
-			if err := os.MkdirAll(d, 0755); err != nil {
-				return fmt.Errorf("mkdir %s: %w", d, err)
+		sc.WPVer = "latest"
+		sc.WPRoot = filepath.Join(sc.SiteDir, "wordpress")
+		sc.AdminUser = flagAdminUser
+		sc.AdminEmail = flagAdminEmail
+		for _, t := range flagAddTags {
+			if err := site.ValidateTag(t); err != nil {
+				return output.WithCode(output.CodeInvalidArgument, err)
 			}
 		}
+		sc.AddTags(flagAddTags...)
 
-		// Save site config
-		if err := site.Save(siteDir, sc); err != nil {
-			return err
+		if flagBlueprint != "" {
+			if err := useBlueprint(cmd, sc, flagBlueprint); err != nil {
+				return err
+			}
 		}
 
-		// Generate Caddy site config
-		caddySitesDir := config.CaddySitesDir()
-		if err := os.MkdirAll(caddySitesDir, 0755); err != nil {
+		if err := createSite(sc); err != nil {
 			return err
 		}
-		caddyConfPath := filepath.Join(caddySitesDir, portStr+".caddy")
-		if err := template.WriteCaddyConf(caddyConfPath, sc); err != nil {
+		if err := storeAdminPassword(sc, flagAdminPass); err != nil {
 			return err
 		}
 
-		// Generate PHP-FPM pool (local copy)
-		phpDir := filepath.Join(baseDir, "php")
-		if err := os.MkdirAll(phpDir, 0755); err != nil {
-			return err
-		}
-		if err := template.WriteFPMPool(filepath.Join(phpDir, portStr+".conf"), sc); err != nil {
-			return err
-		}
+		fmt.Printf("Site configured (%s, PHP %s)\n", sc.URL(), sc.PHP)
 
-		// Install pool config into Homebrew PHP-FPM pool.d
-		fpmPoolDir := template.FPMPoolDir(sc.PHP)
-		if _, err := os.Stat(fpmPoolDir); err == nil {
-			if err := template.WriteFPMPool(filepath.Join(fpmPoolDir, "locwp-"+portStr+".conf"), sc); err != nil {
-				return fmt.Errorf("write FPM pool to %s: %w", fpmPoolDir, err)
+		if !flagNoStart {
+			if err := provision(sc); err != nil {
+				return err
 			}
 		}
+		return emit(newSiteResult(sc))
+	},
+}
 
-		// Generate pawl workflows
-		workflowDir := filepath.Join(siteDir, ".pawl", "workflows")
-		if err := os.MkdirAll(workflowDir, 0755); err != nil {
-			return err
+// useBlueprint validates a blueprint, copies it into the site directory so
+// the site can be re-provisioned from it, and applies its preferred
+// versions unless overridden on the command line.
+func useBlueprint(cmd *cobra.Command, sc *site.Config, ref string) error {
+	data, err := blueprint.Fetch(ref)
+	if err != nil {
+		return err
+	}
+	bp, err := blueprint.Parse(data)
+	if err != nil {
+		return err
+	}
+	if v := bp.PreferredVersions.PHP; v != "" && v != "latest" && !cmd.Flags().Changed("php") {
+		sc.PHP = v
+	}
+	if v := bp.PreferredVersions.WP; v != "" {
+		sc.WPVer = v
+	}
+
+	if err := os.MkdirAll(sc.SiteDir, 0755); err != nil {
+		return err
+	}
+	sc.Blueprint = filepath.Join(sc.SiteDir, "blueprint.json")
+	return os.WriteFile(sc.Blueprint, data, 0644)
+}
+
+// newSiteConfig allocates the next available port and returns a config
+// with the site directory under LOCWP_HOME/sites.
+func newSiteConfig(php string) (*site.Config, error) {
+	baseDir := config.BaseDir()
+	port := config.NextPort(baseDir)
+	portStr := strconv.Itoa(port)
+	siteDir := filepath.Join(baseDir, "sites", portStr)
+
+	if _, err := os.Stat(siteDir); err == nil {
+		return nil, output.WithCode(output.CodeAlreadyExists, fmt.Errorf("site %s already exists", portStr))
+	}
+	socket, err := config.SocketPath(port)
+	if err != nil {
+		return nil, err
+	}
+	return &site.Config{
+		Port:    port,
+		PHP:     php,
+		SiteDir: siteDir,
+		Socket:  socket,
+	}, nil
+}
+
+// createSite creates the site's directories, saves its config and
+// generates all per-site files.
+func createSite(sc *site.Config) error {
+	for _, d := range []string{sc.WPRoot, filepath.Join(sc.SiteDir, "logs")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", d, err)
 		}
-		if err := template.WritePawlWorkflows(workflowDir, sc); err != nil {
+	}
+
+	// Save site config
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		return err
+	}
+
+	return writeSiteFiles(sc)
+}
+
+// storeAdminPassword saves the admin password to the secrets store,
+// generating a random one when pass is empty.
+func storeAdminPassword(sc *site.Config, pass string) error {
+	if pass == "" {
+		var err error
+		if pass, err = secrets.GeneratePassword(20); err != nil {
 			return err
 		}
+	}
+	return site.SetAdminPassword(sc, pass)
+}
+
+// provision runs the site's provision workflow, recording its progress in
+// the site config so `list` can tell an unfinished site from a broken one.
+func provision(sc *site.Config) error {
+	sc.State = site.StateProvisioning
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		return err
+	}
+	runErr := runWorkflow(sc, "provision")
+	sc.State = ""
+	if runErr != nil {
+		sc.State = site.StateFailed
+	}
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		return err
+	}
+	return runErr
+}
 
-		fmt.Printf("Site configured (%s, PHP %s)\n", sc.URL(), flagPHP)
+// writeSiteFiles generates the Caddy site config, PHP-FPM pool, WP-CLI
+// config and alias, and pawl workflows for a site whose config has already been saved.
+func writeSiteFiles(sc *site.Config) error {
+	portStr := sc.PortStr()
+
+	// Generate Caddy site config
+	caddySitesDir := config.CaddySitesDir()
+	if err := os.MkdirAll(caddySitesDir, 0755); err != nil {
+		return err
+	}
+	caddyConfPath := filepath.Join(caddySitesDir, portStr+".caddy")
+	if err := template.WriteCaddyConf(caddyConfPath, sc); err != nil {
+		return err
+	}
+
+	if err := writeFPMPools(sc); err != nil {
+		return err
+	}
+
+	if err := template.WriteWPCLIConfig(sc); err != nil {
+		return err
+	}
+	syncWPCLIAliases()
+
+	return writeWorkflows(sc)
+}
 
-		if flagNoStart {
-			return nil
-		}
+// writeWorkflows generates the site's pawl workflows.
+func writeWorkflows(sc *site.Config) error {
+	workflowDir := filepath.Join(sc.SiteDir, ".pawl", "workflows")
+	if err := os.MkdirAll(workflowDir, 0755); err != nil {
+		return err
+	}
+	return template.WritePawlWorkflows(workflowDir, sc)
+}
 
-		// Run provision workflow
-		return exec.RunInDir(siteDir, "pawl", "start", "provision")
-	},
+// writeFPMPools writes the site's PHP-FPM pool to the local php dir and,
+// when present, the package manager's PHP-FPM pool.d.
+func writeFPMPools(sc *site.Config) error {
+	portStr := sc.PortStr()
+
+	if sc.Socket != "" {
+		if err := config.EnsureRuntimeDir(filepath.Dir(sc.Socket)); err != nil {
+			return fmt.Errorf("create runtime dir: %w", err)
+		}
+	}
+
+	// Generate PHP-FPM pool (local copy)
+	phpDir := filepath.Join(config.BaseDir(), "php")
+	if err := os.MkdirAll(phpDir, 0755); err != nil {
+		return err
+	}
+	if err := template.WriteFPMPool(filepath.Join(phpDir, portStr+".conf"), sc); err != nil {
+		return err
+	}
+
+	// Install pool config into the system PHP-FPM pool.d
+	fpmPoolDir := pkgmgr.Default().FPMPoolDir(sc.PHP)
+	if _, err := os.Stat(fpmPoolDir); err == nil {
+		if err := template.WriteFPMPool(template.FPMPoolPath(sc), sc); err != nil {
+			return fmt.Errorf("write FPM pool to %s: %w", fpmPoolDir, err)
+		}
+	}
+	return nil
 }
 
 func init() {
 	addCmd.Flags().StringVar(&flagPHP, "php", config.DefaultPHP, "PHP version")
 	addCmd.Flags().BoolVar(&flagNoStart, "no-start", false, "Don't start provisioning immediately")
 	addCmd.Flags().StringVar(&flagAdminUser, "user", "admin", "WordPress admin username")
-	addCmd.Flags().StringVar(&flagAdminPass, "pass", "admin", "WordPress admin password")
+	addCmd.Flags().StringVar(&flagAdminPass, "pass", "", "WordPress admin password (default: random)")
 	addCmd.Flags().StringVar(&flagAdminEmail, "email", "admin@loc.wp", "WordPress admin email")
+	addCmd.Flags().StringSliceVar(&flagAddTags, "tag", nil, "Tag the site, for selecting it with --tag (repeatable)")
+	addCmd.Flags().StringVar(&flagBlueprint, "blueprint", "", "Blueprint to apply: name in $LOCWP_HOME/blueprints (per profile), file path or URL")
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagAdoptPHP     string
+	flagAdoptNoStart bool
+)
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <path>",
+	Short: "Serve an existing WordPress directory as a locwp site",
+	Long: `Serve an existing WordPress directory as a locwp site.
+
+The directory is used in place: nothing is copied, and deleting the site
+later removes only locwp's own configs, never the WordPress files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wpRoot, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		inst, err := site.Inspect(wpRoot)
+		if err != nil {
+			return err
+		}
+		// Two sites on one root would share its files under two pools.
+		if other := site.ServingRoot(wpRoot); other != nil {
+			return output.WithCode(output.CodeAlreadyExists, fmt.Errorf("%s is already served by site %d (%s)", wpRoot, other.Port, other.SiteDir))
+		}
+
+		sc, err := newSiteConfig(flagAdoptPHP)
+		if err != nil {
+			return err
+		}
+		sc.WPVer = inst.Version
+		sc.WPRoot = wpRoot
+		sc.Adopted = true
+		sc.DBType = inst.DBType
+		sc.DBFile = inst.DBFile
+
+		if err := createSite(sc); err != nil {
+			return err
+		}
+
+		fmt.Printf("Site adopted (%s, WordPress %s, %s, PHP %s)\n", sc.URL(), inst.Version, inst.DBType, sc.PHP)
+		fmt.Printf("  WordPress root: %s\n", wpRoot)
+		if inst.DBType == site.DBMySQL {
+			fmt.Println("  Note: this site uses MySQL from wp-config.php; locwp does not manage that server.")
+		}
+
+		if !flagAdoptNoStart {
+			if err := provision(sc); err != nil {
+				return err
+			}
+		}
+		return emit(newSiteResult(sc))
+	},
+}
+
+func init() {
+	adoptCmd.Flags().StringVar(&flagAdoptPHP, "php", config.DefaultPHP, "PHP version")
+	adoptCmd.Flags().BoolVar(&flagAdoptNoStart, "no-start", false, "Don't start the site immediately")
+	rootCmd.AddCommand(adoptCmd)
+}
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+// defaultBulkWorkers bounds how many sites a bulk command works on at
+// once.
+const defaultBulkWorkers = 4
+
+// siteSelector holds the flags that pick the sites a bulk command acts on
+// besides port arguments.
+type siteSelector struct {
+	all      bool
+	tags     []string
+	except   []int
+	parallel int
+}
+
+func (s *siteSelector) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&s.all, "all", false, "Act on every site")
+	cmd.Flags().StringSliceVar(&s.tags, "tag", nil, "Act on sites with this tag (repeatable)")
+	cmd.Flags().IntSliceVar(&s.except, "except", nil, "Leave out this port when using --all or --tag (repeatable)")
+	cmd.Flags().IntVar(&s.parallel, "parallel", defaultBulkWorkers, "Sites to work on at once")
+}
+
+// bulk reports whether the command was asked for anything but a single
+// port, and so reports a bulkResult.
+func (s *siteSelector) bulk(args []string) bool {
+	return s.all || len(s.tags) > 0 || len(args) > 1
+}
+
+// sites resolves port arguments, --all and --tag to site configs, ordered
+// by port. Unknown ports fail the whole command before anything runs.
+func (s *siteSelector) sites(cmd *cobra.Command, args []string) ([]*site.Config, error) {
+	sites, err := s.resolve(args)
+	if err != nil {
+		return nil, err
+	}
+	// Failures from here on are the sites', not the command line's.
+	cmd.SilenceUsage = true
+	return sites, nil
+}
+
+func (s *siteSelector) resolve(args []string) ([]*site.Config, error) {
+	if len(args) > 0 && (s.all || len(s.tags) > 0) {
+		return nil, output.WithCode(output.CodeInvalidArgument, errors.New("give ports or --all/--tag, not both"))
+	}
+	if len(args) == 0 && !s.all && len(s.tags) == 0 {
+		return nil, output.WithCode(output.CodeInvalidArgument, errors.New("specify a port, --all or --tag"))
+	}
+	if len(s.except) > 0 && len(args) > 0 {
+		return nil, output.WithCode(output.CodeInvalidArgument, errors.New("--except only applies to --all and --tag"))
+	}
+	if s.all || len(s.tags) > 0 {
+		all, err := site.LoadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(s.tags) > 0 {
+			all = site.WithAnyTag(all, s.tags)
+		}
+		all = slices.DeleteFunc(all, func(sc *site.Config) bool { return slices.Contains(s.except, sc.Port) })
+		if len(all) == 0 {
+			return nil, output.WithCode(output.CodeNotFound, errors.New("no sites match"))
+		}
+		return all, nil
+	}
+
+	var sites []*site.Config
+	for _, arg := range args {
+		sc, err := loadSiteArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(sites, func(o *site.Config) bool { return o.Port == sc.Port }) {
+			sites = append(sites, sc)
+		}
+	}
+	slices.SortFunc(sites, func(a, b *site.Config) int { return a.Port - b.Port })
+	return sites, nil
+}
+
+// bulkOp performs a lifecycle action on one site. quiet is set when it is
+// one of several sites: workflow output is then captured rather than
+// streamed, and the op prints nothing itself.
+type bulkOp func(sc *site.Config, quiet bool) (lifecycleResult, error)
+
+// bulkServices runs the service steps a batch's workflows skipped, once
+// for the sites the op succeeded on.
+type bulkServices func(sites []*site.Config) error
+
+// bulkResult is the structured result of start, stop and delete on
+// several sites.
+type bulkResult struct {
+	Results []lifecycleResult `json:"results"`
+	Failed  []bulkFailure     `json:"failed,omitempty"`
+}
+
+type bulkFailure struct {
+	Port  int    `json:"port"`
+	Error string `json:"error"`
+}
+
+// runBulk runs op on every site with a bounded worker pool, then services
+// once for the batch, prints a line per site and a summary, and fails if
+// any site failed.
+func runBulk(sel *siteSelector, sites []*site.Config, op bulkOp, services bulkServices) error {
+	type outcome struct {
+		res lifecycleResult
+		err error
+	}
+	outcomes := make([]outcome, len(sites))
+	var mu sync.Mutex
+	os.Setenv(template.BatchEnv, "1")
+	forEachSite(sites, sel.parallel, func(i int, sc *site.Config) {
+		res, err := op(sc, true)
+		outcomes[i] = outcome{res, err}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fmt.Printf("  [!!] %d: %v\n", sc.Port, err)
+		} else {
+			fmt.Printf("  [ok] %d %s\n", sc.Port, res.Action)
+		}
+	})
+	os.Unsetenv(template.BatchEnv)
+
+	// A failed service step fails every site it was run for.
+	var done []*site.Config
+	for i, o := range outcomes {
+		if o.err == nil {
+			done = append(done, sites[i])
+		}
+	}
+	if len(done) > 0 {
+		if err := services(done); err != nil {
+			fmt.Printf("  [!!] %v\n", err)
+			for i := range outcomes {
+				if outcomes[i].err == nil {
+					outcomes[i].err = err
+				}
+			}
+		}
+	}
+
+	res := bulkResult{Results: []lifecycleResult{}}
+	var failed []string
+	for i, o := range outcomes {
+		if o.err != nil {
+			res.Failed = append(res.Failed, bulkFailure{Port: sites[i].Port, Error: o.err.Error()})
+			failed = append(failed, sites[i].PortStr())
+			continue
+		}
+		res.Results = append(res.Results, o.res)
+	}
+	fmt.Printf("%d succeeded, %d failed\n", len(res.Results), len(res.Failed))
+	if len(failed) > 0 {
+		return emitFailure(res, fmt.Errorf("%d of %d site(s) failed: %s", len(failed), len(sites), strings.Join(failed, ", ")))
+	}
+	return emit(res)
+}
+
+// forEachSite calls fn for every site, at most workers at a time, and
+// waits for all of them.
+func forEachSite(sites []*site.Config, workers int, fn func(i int, sc *site.Config)) {
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i, sc := range sites {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			fn(i, sc)
+			<-sem
+		}()
+	}
+	wg.Wait()
+}
+
+// runLifecycle runs op on the sites selected by args: directly, with the workflow
+// output streamed, for a single port, or through runBulk otherwise.
+func runLifecycle(sel *siteSelector, args []string, sites []*site.Config, op bulkOp, services bulkServices) error {
+	if !sel.bulk(args) {
+		res, err := op(sites[0], false)
+		if err != nil {
+			return err
+		}
+		return emit(res)
+	}
+	return runBulk(sel, sites, op, services)
+}
+
+// legacyWorkflows serializes the workflows of sites written before
+// template.BatchEnv: they still run their own service steps, which must
+// not race each other.
+var legacyWorkflows sync.Mutex
+
+// workflowOutput runs a site workflow with its output captured.
+func workflowOutput(sc *site.Config, args ...string) (string, error) {
+	if !template.BatchAware(sc) {
+		legacyWorkflows.Lock()
+		defer legacyWorkflows.Unlock()
+	}
+	return exec.CombinedOutputInDir(sc.SiteDir, "pawl", append([]string{"start"}, args...)...)
+}
+
+// sitePHPVersions returns the distinct PHP versions of sites.
+func sitePHPVersions(sites []*site.Config) []string {
+	var versions []string
+	for _, sc := range sites {
+		if !slices.Contains(versions, sc.PHP) {
+			versions = append(versions, sc.PHP)
+		}
+	}
+	return versions
+}
+
+// serviceCmd runs a service command, returning its output's tail with
+// the error.
+func serviceCmd(cmd string) error {
+	if out, err := exec.CombinedOutput("sh", "-c", cmd); err != nil {
+		return fmt.Errorf("%w%s", err, outputTail(out))
+	}
+	return nil
+}
+
+// runSiteWorkflow runs a site workflow, capturing its output when quiet
+// and returning its tail with the error.
+func runSiteWorkflow(sc *site.Config, quiet bool, args ...string) error {
+	if !quiet {
+		return runWorkflow(sc, args...)
+	}
+	out, err := workflowOutput(sc, args...)
+	if err != nil {
+		return output.WithCode(output.CodeWorkflowFailed, fmt.Errorf("pawl workflow %s: %w%s", args[len(args)-1], err, outputTail(out)))
+	}
+	return nil
+}
+
+// outputTail returns the last lines of command output, indented for an
+// error message.
+func outputTail(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return "\n    " + strings.Join(lines, "\n    ")
+}
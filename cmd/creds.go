@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/secrets"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var flagCredsPasswordOnly bool
+
+// credsResult is the structured result of `creds`.
+type credsResult struct {
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	AdminURL string `json:"admin_url"`
+	Store    string `json:"store"`
+}
+
+var credsCmd = &cobra.Command{
+	Use:   "creds <port>",
+	Short: "Show a site's WordPress admin credentials",
+	Long: `Show a site's WordPress admin credentials. The password is kept in the
+OS keyring (macOS keychain, Secret Service on Linux) or in the 0600 file
+LOCWP_HOME/secrets.json, never in config.json or workflow files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		// Sites from older versions keep the password in config.json.
+		if err := site.MigrateAdminPass(sc); err != nil {
+			return err
+		}
+		pass, err := site.AdminPassword(sc)
+		if err != nil {
+			return err
+		}
+
+		if flagCredsPasswordOnly {
+			fmt.Fprintln(resultOut, pass)
+			return nil
+		}
+
+		res := credsResult{
+			Port:     sc.Port,
+			User:     sc.AdminUser,
+			Password: pass,
+			Email:    sc.AdminEmail,
+			AdminURL: sc.URL() + "/wp-admin/",
+			Store:    secrets.Default().Name(),
+		}
+		if outputFormat.Structured() {
+			return emit(res)
+		}
+		fmt.Printf("  User:      %s\n", res.User)
+		fmt.Printf("  Password:  %s\n", res.Password)
+		fmt.Printf("  Email:     %s\n", res.Email)
+		fmt.Printf("  Admin:     %s\n", res.AdminURL)
+		fmt.Printf("  Stored in: %s\n", res.Store)
+		return nil
+	},
+}
+
+func init() {
+	credsCmd.Flags().BoolVar(&flagCredsPasswordOnly, "password-only", false, "Print only the password, for piping into other tools")
+	rootCmd.AddCommand(credsCmd)
+}
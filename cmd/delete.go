@@ -1,41 +1,140 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
 	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/pkgmgr"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+var (
+	flagDeleteYes   bool
+	flagDeletePurge bool
+	deleteSelector  siteSelector
 )
 
 var deleteCmd = &cobra.Command{
-	Use:     "delete <port>",
+	Use:     "delete <port>... | --all | --tag <tag>",
 	Aliases: []string{"rm"},
-	Short:   "Delete a WordPress site",
-	Args:    cobra.ExactArgs(1),
+	Short:   "Delete WordPress sites",
+	Long: fmt.Sprintf(`Delete WordPress sites.
+
+Sites are moved to the trash, where they keep their port reserved for %d
+days; bring one back with `+"`locwp trash restore <port>`"+`. --purge deletes
+them for good.`, int(config.TrashRetention.Hours()/24)),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid port %q: %w", args[0], err)
-		}
-		sc, err := site.LoadByPort(port)
+		sites, err := deleteSelector.sites(cmd, args)
 		if err != nil {
 			return err
 		}
 
-		// Run destroy workflow (removes Caddy/FPM configs, reloads Caddy)
+		if !flagDeleteYes {
+			what := fmt.Sprintf("site %d (%s)", sites[0].Port, sites[0].WPRoot)
+			if len(sites) > 1 {
+				ports := make([]string, len(sites))
+				for i, sc := range sites {
+					ports[i] = sc.PortStr()
+				}
+				what = fmt.Sprintf("%d sites (%s)", len(sites), strings.Join(ports, ", "))
+			}
+			question := "Delete " + what + "?"
+			if flagDeletePurge {
+				question = "Permanently delete " + what + "? This cannot be undone."
+			}
+			if err := confirmOrYes(question); err != nil {
+				return err
+			}
+		}
+
+		err = runLifecycle(&deleteSelector, args, sites, deleteSite, deleteServices)
+		syncWPCLIAliases()
+		return err
+	},
+}
+
+func deleteSite(sc *site.Config, quiet bool) (lifecycleResult, error) {
+	res := lifecycleResult{Port: sc.Port, Action: "deleted"}
+
+	// Adopted sites point at the user's own WordPress directory, which
+	// must survive the delete.
+	if sc.Adopted {
+		if rel, err := filepath.Rel(sc.SiteDir, sc.WPRoot); err == nil && !strings.HasPrefix(rel, "..") {
+			return res, fmt.Errorf("refusing to remove %s: it contains the adopted WordPress root %s", sc.SiteDir, sc.WPRoot)
+		}
+	}
+
+	// Run destroy workflow (removes Caddy/FPM configs, reloads Caddy)
+	if quiet {
+		_, _ = workflowOutput(sc, "destroy")
+	} else {
 		_ = exec.RunInDir(sc.SiteDir, "pawl", "start", "destroy")
+	}
 
-		// Remove site directory
-		os.RemoveAll(sc.SiteDir)
+	if flagDeletePurge {
+		if err := os.RemoveAll(sc.SiteDir); err != nil {
+			return res, err
+		}
+		_ = site.DeleteSecrets(sc)
+		if !quiet {
+			fmt.Printf("Site %d deleted.\n", sc.Port)
+		}
+	} else {
+		e, err := site.MoveToTrash(sc)
+		if err != nil {
+			return res, err
+		}
+		res.Action = "trashed"
+		res.Trash = e.ID
+		if !quiet {
+			fmt.Printf("Site %d moved to the trash; port reserved until %s.\n", sc.Port, e.ReservedUntil().Local().Format("2006-01-02 15:04"))
+			fmt.Printf("Restore it with `locwp trash restore %d`.\n", sc.Port)
+		}
+	}
+	if sc.Adopted {
+		if !quiet {
+			fmt.Printf("WordPress files kept at %s\n", sc.WPRoot)
+		}
+		res.Kept = sc.WPRoot
+	}
+	return res, nil
+}
 
-		fmt.Printf("Site %d deleted.\n", sc.Port)
-		return nil
-	},
+// deleteServices restarts PHP-FPM for every PHP version in the batch, so
+// it drops the deleted sites' pools, and reloads Caddy once. As in the
+// destroy workflow, failures don't fail the delete.
+func deleteServices(sites []*site.Config) error {
+	pm := pkgmgr.Default()
+	for _, v := range sitePHPVersions(sites) {
+		_ = serviceCmd(pm.ServiceCmd("restart", pm.PHPService(v)))
+	}
+	_ = serviceCmd(template.CaddyReloadCmd())
+	return nil
+}
+
+// confirmOrYes asks for confirmation on the terminal, and fails when
+// there is none to ask on: destructive commands need --yes in scripts.
+func confirmOrYes(question string) error {
+	if !interactive() {
+		return output.WithCode(output.CodeInvalidArgument, errors.New("confirmation required: pass --yes to run without a terminal"))
+	}
+	if !confirm(question, false) {
+		return errors.New("cancelled")
+	}
+	return nil
 }
 
 func init() {
+	deleteCmd.Flags().BoolVarP(&flagDeleteYes, "yes", "y", false, "Don't ask for confirmation")
+	deleteCmd.Flags().BoolVar(&flagDeletePurge, "purge", false, "Delete for good instead of moving to the trash")
+	deleteSelector.register(deleteCmd)
 	rootCmd.AddCommand(deleteCmd)
 }
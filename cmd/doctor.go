@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/cleanup"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+	"github.com/yansircc/locwp/internal/tools"
+)
+
+var flagDoctorFix bool
+
+// finding is the result of one doctor check. hint tells the user what to
+// do; fix, when set, does it automatically under --fix.
+type finding struct {
+	ok   bool
+	msg  string
+	hint string
+	fix  func() error
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the locwp environment and sites",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sites, broken := loadSitesForDoctor()
+
+		sections := []struct {
+			title    string
+			findings []finding
+		}{
+			{"Tools", checkTools(sites)},
+			{"Caddy", checkCaddyfile()},
+			{"Site configs", broken},
+			{"PHP-FPM sockets", checkSockets(sites)},
+			{"Ports", checkPorts(sites)},
+			{"Orphan files", checkOrphans()},
+		}
+
+		problems, fixed := 0, 0
+		for i, s := range sections {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", s.title)
+			for _, f := range s.findings {
+				if f.ok {
+					fmt.Printf("  [ok] %s\n", f.msg)
+					continue
+				}
+				problems++
+				fmt.Printf("  [!!] %s\n", f.msg)
+				if flagDoctorFix && f.fix != nil {
+					if err := f.fix(); err != nil {
+						fmt.Printf("       fix failed: %v\n", err)
+					} else {
+						fixed++
+						fmt.Println("       fixed")
+					}
+					continue
+				}
+				if f.hint != "" {
+					fmt.Printf("       fix: %s\n", f.hint)
+				}
+			}
+		}
+
+		fmt.Println()
+		switch {
+		case problems == 0:
+			fmt.Println("No problems found.")
+		case flagDoctorFix:
+			fmt.Printf("%d problem(s) found, %d fixed.\n", problems, fixed)
+		default:
+			fmt.Printf("%d problem(s) found. Run `locwp doctor --fix` to apply automatic fixes.\n", problems)
+		}
+		// The summary above is the report; just fail the exit status.
+		if problems > fixed {
+			cmd.SilenceUsage, cmd.SilenceErrors = true, true
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+// loadSitesForDoctor loads every site, reporting unreadable configs.
+func loadSitesForDoctor() ([]*site.Config, []finding) {
+	sitesDir := filepath.Join(config.BaseDir(), "sites")
+	entries, _ := os.ReadDir(sitesDir)
+	var (
+		sites    []*site.Config
+		findings []finding
+	)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(sitesDir, e.Name())
+		sc, err := site.Load(dir)
+		if err != nil {
+			findings = append(findings, finding{
+				msg:  fmt.Sprintf("%s/config.json: %v", dir, err),
+				hint: "repair the JSON by hand, or remove the directory if the site is gone",
+			})
+			continue
+		}
+		sites = append(sites, sc)
+		if site.HasLegacyAdminPass(sc) {
+			findings = append(findings, finding{
+				msg:  fmt.Sprintf("%s/config.json holds the admin password in plain text", dir),
+				hint: fmt.Sprintf("locwp creds %d", sc.Port),
+				fix:  func() error { return site.MigrateAdminPass(sc) },
+			})
+		}
+	}
+	findings = append(findings, finding{ok: true, msg: fmt.Sprintf("%d site config(s) readable", len(sites))})
+	return sites, findings
+}
+
+// phpVersions returns the PHP versions in use, including the default.
+func phpVersions(sites []*site.Config) []string {
+	versions := []string{config.DefaultPHP}
+	for _, sc := range sites {
+		if !slices.Contains(versions, sc.PHP) {
+			versions = append(versions, sc.PHP)
+		}
+	}
+	return versions
+}
+
+func checkTools(sites []*site.Config) []finding {
+	var out []finding
+	for _, t := range []struct{ bin, hint string }{
+		{"pawl", "cargo install pawl"},
+		{tools.CaddyBin(), "locwp setup"},
+		{tools.WPBin(), "locwp setup"},
+	} {
+		if exec.CommandExists(t.bin) {
+			out = append(out, finding{ok: true, msg: t.bin + " found"})
+		} else {
+			out = append(out, finding{msg: t.bin + " not found", hint: t.hint})
+		}
+	}
+	mgr := pkgmgr.Default()
+	for _, v := range phpVersions(sites) {
+		bin := mgr.PHPBin(v)
+		if _, err := os.Stat(bin); err != nil {
+			out = append(out, finding{msg: "PHP " + v + " not installed (" + bin + ")", hint: "locwp setup --php " + v})
+			continue
+		}
+		mods, _ := exec.Output(bin, "-m")
+		if !slices.Contains(strings.Fields(mods), "pdo_sqlite") {
+			out = append(out, finding{msg: "PHP " + v + " lacks the pdo_sqlite extension", hint: installHint(mgr, mgr.PHPPackages(v))})
+			continue
+		}
+		out = append(out, finding{ok: true, msg: "PHP " + v + " with pdo_sqlite"})
+	}
+	return out
+}
+
+func checkCaddyfile() []finding {
+	data, err := os.ReadFile(caddyfilePath())
+	if err == nil && strings.Contains(string(data), template.CaddyImportLine()) {
+		return []finding{{ok: true, msg: caddyfilePath() + " imports locwp sites"}}
+	}
+	msg := caddyfilePath() + " does not import " + config.CaddySitesDir()
+	if err != nil {
+		msg = caddyfilePath() + " missing"
+	}
+	return []finding{{
+		msg:  msg,
+		hint: "locwp setup",
+		fix: func() error {
+			if err := os.MkdirAll(config.CaddySitesDir(), 0755); err != nil {
+				return err
+			}
+			if err := template.WriteCaddyfile(); err != nil {
+				return err
+			}
+			return reloadCaddy()
+		},
+	}}
+}
+
+// checkSockets verifies that every enabled site has a PHP-FPM socket the
+// current user (and so Caddy) can use.
+func checkSockets(sites []*site.Config) []finding {
+	var out []finding
+	for _, sc := range sites {
+		// Sites of older versions have theirs in $XDG_RUNTIME_DIR.
+		if sc.Socket != "" && filepath.Dir(sc.Socket) != config.RuntimeDir() {
+			out = append(out, finding{
+				msg:  fmt.Sprintf("site %d: socket in %s, which is gone at boot and after logout", sc.Port, filepath.Dir(sc.Socket)),
+				hint: "locwp doctor --fix",
+				fix:  func() error { return moveSocket(sc) },
+			})
+		}
+	}
+	dirs := map[string]bool{}
+	for _, sc := range sites {
+		if sc.Socket == "" || dirs[filepath.Dir(sc.Socket)] {
+			continue
+		}
+		dir := filepath.Dir(sc.Socket)
+		dirs[dir] = true
+		if fi, err := os.Stat(dir); err == nil && fi.Mode().Perm() != 0700 {
+			out = append(out, finding{
+				msg:  fmt.Sprintf("runtime dir %s has mode %v, want 0700", dir, fi.Mode().Perm()),
+				hint: "chmod 700 " + dir,
+				fix:  func() error { return config.EnsureRuntimeDir(dir) },
+			})
+		}
+	}
+	for _, sc := range sites {
+		if !site.CaddyConfEnabled(sc.Port) {
+			continue
+		}
+		sock := sc.SocketPath()
+		restart := func() error {
+			if err := writeFPMPools(sc); err != nil {
+				return err
+			}
+			return restartPHP(sc.PHP)
+		}
+		fi, err := os.Stat(sock)
+		switch {
+		case err != nil:
+			out = append(out, finding{
+				msg:  fmt.Sprintf("site %d: %s missing", sc.Port, sock),
+				hint: phpRestartCmd(sc.PHP),
+				fix:  restart,
+			})
+		case fi.Mode()&os.ModeSocket == 0:
+			out = append(out, finding{
+				msg:  fmt.Sprintf("site %d: %s is not a socket", sc.Port, sock),
+				hint: "rm " + sock + " && " + phpRestartCmd(sc.PHP),
+				fix: func() error {
+					if err := os.Remove(sock); err != nil {
+						return err
+					}
+					return restart()
+				},
+			})
+		case !ownedByCurrentUser(fi) || fi.Mode().Perm()&0600 != 0600:
+			out = append(out, finding{
+				msg:  fmt.Sprintf("site %d: %s has mode %v, not usable by %s", sc.Port, sock, fi.Mode().Perm(), os.Getenv("USER")),
+				hint: phpRestartCmd(sc.PHP),
+				fix:  restart,
+			})
+		default:
+			out = append(out, finding{ok: true, msg: fmt.Sprintf("site %d: %s", sc.Port, sock)})
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, finding{ok: true, msg: "no running sites"})
+	}
+	return out
+}
+
+func ownedByCurrentUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return !ok || int(st.Uid) == os.Getuid()
+}
+
+// checkPorts reports sites sharing a port and stopped sites whose port is
+// taken by another process, which would make `start` fail.
+func checkPorts(sites []*site.Config) []finding {
+	var out []finding
+	seen := map[int]string{}
+	for _, sc := range sites {
+		if other, ok := seen[sc.Port]; ok {
+			out = append(out, finding{
+				msg:  fmt.Sprintf("port %d used by both %s and %s", sc.Port, other, sc.SiteDir),
+				hint: "delete one of the sites",
+			})
+		}
+		seen[sc.Port] = sc.SiteDir
+
+		if site.CaddyConfEnabled(sc.Port) {
+			continue
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:"+sc.PortStr())
+		if err != nil {
+			out = append(out, finding{
+				msg:  fmt.Sprintf("site %d is stopped but port %d is in use by another process", sc.Port, sc.Port),
+				hint: fmt.Sprintf("lsof -nP -iTCP:%d -sTCP:LISTEN", sc.Port),
+			})
+			continue
+		}
+		ln.Close()
+	}
+	if len(out) == 0 {
+		out = append(out, finding{ok: true, msg: "no port conflicts"})
+	}
+	return out
+}
+
+// moveSocket moves a site's socket to the runtime dir under LOCWP_HOME
+// and regenerates the files that name it.
+func moveSocket(sc *site.Config) error {
+	sock, err := config.SocketPath(sc.Port)
+	if err != nil {
+		return err
+	}
+	sc.Socket = sock
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		return err
+	}
+	if err := writeFPMPools(sc); err != nil {
+		return err
+	}
+	conf := site.CaddyConfPath(sc.Port)
+	if !site.CaddyConfEnabled(sc.Port) {
+		conf += ".disabled"
+	}
+	if err := template.WriteCaddyConf(conf, sc); err != nil {
+		return err
+	}
+	if err := writeWorkflows(sc); err != nil {
+		return err
+	}
+	if err := restartPHP(sc.PHP); err != nil {
+		return err
+	}
+	return reloadCaddy()
+}
+
+// checkOrphans finds Caddy confs, FPM pools and sockets left behind by
+// removed sites.
+func checkOrphans() []finding {
+	orphans := cleanup.Orphans(pkgmgr.Default())
+	if len(orphans) == 0 {
+		return []finding{{ok: true, msg: "no orphan Caddy confs, FPM pools or sockets"}}
+	}
+	var out []finding
+	for _, a := range orphans {
+		out = append(out, finding{
+			msg:  "orphan " + a.Path,
+			hint: "locwp gc",
+			fix:  func() error { return cleanup.Remove(a) },
+		})
+	}
+	return out
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&flagDoctorFix, "fix", false, "Apply automatic fixes where possible")
+	rootCmd.AddCommand(doctorCmd)
+}
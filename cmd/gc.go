@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/cleanup"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+)
+
+var flagGCDryRun bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove Caddy confs, FPM pools and sockets left by deleted sites",
+	Long: `Remove Caddy confs, FPM pools and sockets left by deleted sites.
+
+Files in caddy/sites, the local php dir, every PHP version's FPM pool
+directory and the socket directories are matched against the sites of the
+active profile. A site whose config.json is unreadable still counts as
+existing, so its files are kept.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr := pkgmgr.Default()
+		orphans := cleanup.Orphans(mgr)
+		res := gcResult{Orphans: orphans, DryRun: flagGCDryRun}
+		if res.Orphans == nil {
+			res.Orphans = []cleanup.Artifact{}
+		}
+		if len(orphans) == 0 {
+			fmt.Println("No orphans found.")
+			return emit(res)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PORT\tKIND\tPATH")
+		for _, a := range orphans {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", a.Port, a.Kind, a.Path)
+		}
+		w.Flush()
+		if flagGCDryRun {
+			fmt.Printf("%d orphan(s). Run `locwp gc` without --dry-run to remove them.\n", len(orphans))
+			return emit(res)
+		}
+
+		reloadPHP := map[string]bool{}
+		reload := false
+		for _, a := range orphans {
+			if err := cleanup.Remove(a); err != nil {
+				fmt.Printf("  [!!] %s: %v\n", a.Path, err)
+				res.Failed = append(res.Failed, a.Path)
+				continue
+			}
+			res.Removed = append(res.Removed, a.Path)
+			if a.PHP != "" {
+				reloadPHP[a.PHP] = true
+			}
+			if a.Kind == cleanup.KindCaddyConf {
+				reload = true
+			}
+		}
+		// Let the services drop what they may still have loaded, so the
+		// ports are freed.
+		for v := range reloadPHP {
+			_ = restartPHP(v)
+		}
+		if reload {
+			_ = reloadCaddy()
+		}
+
+		fmt.Printf("Removed %d orphan(s).\n", len(res.Removed))
+		if len(res.Failed) > 0 {
+			return emitFailure(res, fmt.Errorf("%d orphan(s) could not be removed", len(res.Failed)))
+		}
+		return emit(res)
+	},
+}
+
+// gcResult is the structured result of `gc`.
+type gcResult struct {
+	Orphans []cleanup.Artifact `json:"orphans"`
+	DryRun  bool               `json:"dry_run,omitempty"`
+	Removed []string           `json:"removed,omitempty"`
+	Failed  []string           `json:"failed,omitempty"`
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&flagGCDryRun, "dry-run", false, "Only report orphans")
+	rootCmd.AddCommand(gcCmd)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/migrate"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagImportPHP        string
+	flagImportAdminUser  string
+	flagImportAdminPass  string
+	flagImportAdminEmail string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Create a site from a WordPress migration archive",
+	Long: `Create a site from a WordPress migration archive.
+
+Supported formats: All-in-One WP Migration (.wpress), Duplicator package
+archives, and plain zips containing wp-content/ and a SQL dump. The dump is
+imported into the new site's SQLite database, URLs are rewritten to the
+local site URL, and the admin password is reset.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		format, err := migrate.Detect(archive)
+		if err != nil {
+			return err
+		}
+
+		sc, err := newSiteConfig(flagImportPHP)
+		if err != nil {
+			return err
+		}
+		sc.WPVer = "latest"
+		sc.WPRoot = filepath.Join(sc.SiteDir, "wordpress")
+		sc.AdminUser = flagImportAdminUser
+		sc.AdminEmail = flagImportAdminEmail
+
+		if err := createSite(sc); err != nil {
+			return err
+		}
+		if err := storeAdminPassword(sc, flagImportAdminPass); err != nil {
+			return err
+		}
+		fmt.Printf("Site configured (%s, PHP %s), importing %s archive\n", sc.URL(), sc.PHP, format)
+
+		// A fresh install provides WordPress core and the SQLite drop-in.
+		if err := provision(sc); err != nil {
+			return err
+		}
+
+		workDir := filepath.Join(sc.SiteDir, "import")
+		defer os.RemoveAll(workDir)
+
+		fmt.Println("  ... Extracting files")
+		pkg, err := migrate.Extract(archive, format, sc.WPRoot, workDir)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("  ... Importing database")
+		if err := importDatabase(sc, pkg, workDir); err != nil {
+			return err
+		}
+
+		fmt.Println("  ... Rewriting URLs")
+		if err := rewriteSiteURL(sc); err != nil {
+			return err
+		}
+
+		fmt.Println("  ... Resetting admin password")
+		if err := resetAdmin(sc); err != nil {
+			return err
+		}
+
+		_ = runWP(sc, "rewrite", "flush")
+		fmt.Printf("Site imported at %s\n", sc.URL())
+		return emit(newSiteResult(sc))
+	},
+}
+
+// importDatabase loads the dump into the site's SQLite database and points
+// wp-config.php at the dump's table prefix.
+func importDatabase(sc *site.Config, pkg *migrate.Package, workDir string) error {
+	dump, err := os.ReadFile(pkg.SQLFile)
+	if err != nil {
+		return err
+	}
+	stmts := migrate.SplitSQL(string(dump))
+	script, statements, err := migrate.WriteImporter(workDir, stmts)
+	if err != nil {
+		return err
+	}
+	if err := runWP(sc, "eval-file", script, statements, "--skip-plugins", "--skip-themes"); err != nil {
+		return fmt.Errorf("import database: %w", err)
+	}
+	if prefix := migrate.TablePrefix(stmts); prefix != migrate.DefaultTablePrefix {
+		if err := runWP(sc, "config", "set", "table_prefix", prefix, "--type=variable"); err != nil {
+			return fmt.Errorf("set table prefix: %w", err)
+		}
+	}
+	return nil
+}
+
+// rewriteSiteURL replaces the imported site's URL with the local one.
+func rewriteSiteURL(sc *site.Config) error {
+	oldURL, err := wpOutput(sc, "option", "get", "siteurl", "--skip-plugins", "--skip-themes")
+	if err != nil {
+		return fmt.Errorf("read imported siteurl: %w", err)
+	}
+	return rewriteURL(sc, oldURL)
+}
+
+// resetAdmin sets the admin password from the secrets store, creating the
+// admin user if the imported database doesn't have one.
+func resetAdmin(sc *site.Config) error {
+	pass, err := site.AdminPassword(sc)
+	if err != nil {
+		return err
+	}
+	if _, err := wpOutput(sc, "user", "get", sc.AdminUser, "--field=ID", "--skip-plugins", "--skip-themes"); err == nil {
+		return wpInput(sc, pass, "user", "update", sc.AdminUser, "--prompt=user_pass", "--skip-email", "--skip-plugins", "--skip-themes")
+	}
+	return wpInput(sc, pass, "user", "create", sc.AdminUser, sc.AdminEmail, "--role=administrator", "--prompt=user_pass", "--skip-plugins", "--skip-themes")
+}
+
+func init() {
+	importCmd.Flags().StringVar(&flagImportPHP, "php", config.DefaultPHP, "PHP version")
+	importCmd.Flags().StringVar(&flagImportAdminUser, "user", "admin", "WordPress admin username")
+	importCmd.Flags().StringVar(&flagImportAdminPass, "pass", "", "WordPress admin password (default: random)")
+	importCmd.Flags().StringVar(&flagImportAdminEmail, "email", "admin@loc.wp", "WordPress admin email")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/bytesize"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+// infoResult is everything `info` knows about a site.
+type infoResult struct {
+	siteResult
+	PHPBin     string       `json:"php_bin"`
+	Status     string       `json:"status"`
+	Reason     string       `json:"reason,omitempty"`
+	HTTPStatus int          `json:"http_status,omitempty"`
+	ResponseMS int64        `json:"response_time_ms,omitempty"`
+	DBType     string       `json:"db_type"`
+	DBSize     int64        `json:"db_size,omitempty"`
+	DiskUsage  int64        `json:"disk_usage"`
+	Socket     string       `json:"fpm_socket"`
+	FPMPools   []string     `json:"fpm_pools"`
+	CaddyConf  string       `json:"caddy_conf"`
+	WPCLIConf  string       `json:"wp_cli_config"`
+	WPCLIAlias string       `json:"wp_cli_alias"`
+	LogsDir    string       `json:"logs_dir"`
+	Workflows  []string     `json:"workflows"`
+	Blueprint  string       `json:"blueprint,omitempty"`
+	Mounts     []site.Mount `json:"mounts,omitempty"`
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <port>",
+	Short: "Show everything about a site",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		info := siteInfo(sc)
+		if outputFormat.Structured() {
+			return emit(info)
+		}
+		printInfo(info)
+		return nil
+	},
+}
+
+// siteInfo assembles a site's config, generated files and live probes.
+func siteInfo(sc *site.Config) infoResult {
+	h := site.Check(sc)
+	info := infoResult{
+		siteResult: newSiteResult(sc),
+		PHPBin:     pkgmgr.Default().PHPBin(sc.PHP),
+		Status:     h.State,
+		Reason:     h.Reason,
+		HTTPStatus: h.HTTPStatus,
+		ResponseMS: h.ResponseTime.Milliseconds(),
+		DBType:     sc.DBType,
+		Socket:     sc.SocketPath(),
+		LogsDir:    filepath.Join(sc.SiteDir, "logs"),
+		WPCLIConf:  template.WPCLIConfigPath(sc),
+		WPCLIAlias: "@" + template.WPCLIAlias(sc.Port),
+		Blueprint:  sc.Blueprint,
+		Mounts:     sc.Mounts,
+		FPMPools:   []string{},
+		Workflows:  []string{},
+	}
+	if info.DBType == "" {
+		info.DBType = site.DBSQLite
+	}
+	if fi, err := os.Stat(sc.DBPath()); err == nil && sc.DBType != site.DBMySQL {
+		info.DBSize = fi.Size()
+	}
+
+	// Adopted sites keep WordPress outside the site dir; count both.
+	info.DiskUsage, _ = site.DiskUsage(sc.SiteDir)
+	if rel, err := filepath.Rel(sc.SiteDir, sc.WPRoot); err != nil || strings.HasPrefix(rel, "..") {
+		n, _ := site.DiskUsage(sc.WPRoot)
+		info.DiskUsage += n
+	}
+
+	for _, p := range []string{
+		filepath.Join(config.BaseDir(), "php", sc.PortStr()+".conf"),
+		template.FPMPoolPath(sc),
+	} {
+		if _, err := os.Stat(p); err == nil {
+			info.FPMPools = append(info.FPMPools, p)
+		}
+	}
+
+	info.CaddyConf = site.CaddyConfPath(sc.Port)
+	if _, err := os.Stat(info.CaddyConf + ".disabled"); err == nil {
+		info.CaddyConf += ".disabled"
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(sc.SiteDir, ".pawl", "workflows", "*.json")); files != nil {
+		info.Workflows = files
+	}
+	return info
+}
+
+func printInfo(info infoResult) {
+	status := info.Status
+	if info.Reason != "" && info.Status != site.HealthStopped {
+		status += " (" + info.Reason + ")"
+	} else if info.HTTPStatus != 0 {
+		status += fmt.Sprintf(" (HTTP %d, %s)", info.HTTPStatus, time.Duration(info.ResponseMS)*time.Millisecond)
+	}
+
+	db := info.DBType
+	if info.DBPath != "" {
+		db += " " + info.DBPath
+		if info.DBSize > 0 {
+			db += " (" + bytesize.Format(info.DBSize) + ")"
+		}
+	}
+
+	workflows := make([]string, len(info.Workflows))
+	for i, w := range info.Workflows {
+		workflows[i] = strings.TrimSuffix(filepath.Base(w), ".json")
+	}
+
+	login := ""
+	if info.AdminUser != "" {
+		login = info.AdminUser + " / " + info.AdminPass
+	}
+
+	fmt.Printf("Site %d\n", info.Port)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(k, v string) { fmt.Fprintf(w, "  %s:\t%s\n", k, orDash(v)) }
+	row("URL", info.URL)
+	row("Admin", info.AdminURL)
+	row("Login", login)
+	row("Status", status)
+	row("Tags", strings.Join(info.Tags, ", "))
+	row("PHP", info.PHP+" ("+info.PHPBin+")")
+	row("WordPress", info.WPVersion)
+	row("Database", db)
+	row("Disk usage", bytesize.Format(info.DiskUsage))
+	row("Site dir", info.SiteDir)
+	row("WordPress root", info.WPRoot)
+	row("FPM socket", info.Socket)
+	row("FPM pools", strings.Join(info.FPMPools, ", "))
+	row("Caddy conf", info.CaddyConf)
+	row("WP-CLI", info.WPCLIAlias+" ("+info.WPCLIConf+")")
+	row("Logs", info.LogsDir)
+	row("Workflows", strings.Join(workflows, ", "))
+	row("Blueprint", info.Blueprint)
+	w.Flush()
+
+	if len(info.Mounts) > 0 {
+		fmt.Println("  Mounts:")
+		for _, m := range info.Mounts {
+			fmt.Printf("    %-10s %s -> %s\n", m.Type, m.Name(), m.Source)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yansircc/locwp/internal/config"
 	"github.com/yansircc/locwp/internal/site"
 )
 
+var flagListTags []string
+
 var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
@@ -21,7 +25,7 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			if os.IsNotExist(err) {
 				fmt.Println("No sites yet. Run `locwp add` to create one.")
-				return nil
+				return emit([]listRow{})
 			}
 			return err
 		}
@@ -35,24 +39,122 @@ var listCmd = &cobra.Command{
 		}
 		if len(dirs) == 0 {
 			fmt.Println("No sites yet. Run `locwp add` to create one.")
-			return nil
+			return emit([]listRow{})
 		}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "PORT\tURL\tPHP\tSTATUS\tPATH")
+		// Load every config first so the health probes can run concurrently.
+		var sites []*site.Config
+		var broken []string
 		for _, e := range dirs {
 			sc, err := site.Load(filepath.Join(sitesDir, e.Name()))
 			if err != nil {
-				fmt.Fprintf(w, "%s\t-\t-\terror\t-\n", e.Name())
+				broken = append(broken, e.Name())
 				continue
 			}
-			status := site.Status(sc)
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", sc.Port, sc.URL(), sc.PHP, status, sc.WPRoot)
+			sites = append(sites, sc)
+		}
+		if len(flagListTags) > 0 {
+			sites = site.WithAnyTag(sites, flagListTags)
+			broken = nil
+		}
+		health := site.CheckAll(sites, listWorkers)
+
+		if outputFormat.Structured() {
+			rows := []listRow{}
+			for i, sc := range sites {
+				rows = append(rows, newListRow(sc, health[i]))
+			}
+			for _, name := range broken {
+				rows = append(rows, listRow{Status: "error", SiteDir: filepath.Join(sitesDir, name)})
+			}
+			return emit(rows)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PORT\tURL\tPHP\tWP\tSTATUS\tTIME\tMOUNTS\tTAGS\tPATH")
+		for i, sc := range sites {
+			h := health[i]
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", sc.Port, sc.URL(), sc.PHP, orDash(h.WPVersion), statusSummary(h), responseTime(h), mountSummary(sc), orDash(strings.Join(sc.Tags, ",")), sc.WPRoot)
+		}
+		for _, name := range broken {
+			fmt.Fprintf(w, "%s\t-\t-\t-\terror\t-\t-\t-\t-\n", name)
 		}
 		return w.Flush()
 	},
 }
 
+// listRow is the structured form of one `list` line.
+type listRow struct {
+	Port       int          `json:"port,omitempty"`
+	URL        string       `json:"url,omitempty"`
+	PHP        string       `json:"php,omitempty"`
+	WPVersion  string       `json:"wp_version,omitempty"`
+	Status     string       `json:"status"`
+	Reason     string       `json:"reason,omitempty"`
+	HTTPStatus int          `json:"http_status,omitempty"`
+	ResponseMS int64        `json:"response_time_ms,omitempty"`
+	Mounts     []site.Mount `json:"mounts,omitempty"`
+	Tags       []string     `json:"tags,omitempty"`
+	SiteDir    string       `json:"site_dir"`
+	WPRoot     string       `json:"wp_root,omitempty"`
+}
+
+func newListRow(sc *site.Config, h site.Health) listRow {
+	return listRow{
+		Port:       sc.Port,
+		URL:        sc.URL(),
+		PHP:        sc.PHP,
+		WPVersion:  h.WPVersion,
+		Status:     h.State,
+		Reason:     h.Reason,
+		HTTPStatus: h.HTTPStatus,
+		ResponseMS: h.ResponseTime.Milliseconds(),
+		Mounts:     sc.Mounts,
+		Tags:       sc.Tags,
+		SiteDir:    sc.SiteDir,
+		WPRoot:     sc.WPRoot,
+	}
+}
+
+// listWorkers bounds concurrent health probes in `list`.
+const listWorkers = 16
+
 func init() {
+	listCmd.Flags().StringSliceVar(&flagListTags, "tag", nil, "Only list sites with this tag (repeatable)")
 	rootCmd.AddCommand(listCmd)
 }
+
+// mountSummary lists a site's mounted directory names, or "-" if none.
+func mountSummary(sc *site.Config) string {
+	if len(sc.Mounts) == 0 {
+		return "-"
+	}
+	names := make([]string, len(sc.Mounts))
+	for i, m := range sc.Mounts {
+		names[i] = m.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// statusSummary renders a health state with its reason, if any.
+func statusSummary(h site.Health) string {
+	if h.Reason == "" || h.State == site.HealthStopped {
+		return h.State
+	}
+	return fmt.Sprintf("%s (%s)", h.State, h.Reason)
+}
+
+// responseTime renders the home page response time, or "-" if not probed.
+func responseTime(h site.Health) string {
+	if h.ResponseTime == 0 {
+		return "-"
+	}
+	return h.ResponseTime.Round(time.Millisecond).String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+var (
+	flagLoginUser  string
+	flagLoginPrint bool
+	flagLoginTTL   time.Duration
+)
+
+// loginResult is the structured result of `login`.
+type loginResult struct {
+	Port    int       `json:"port"`
+	User    string    `json:"user"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login <port>",
+	Short: "Open a one-time auto-login link to wp-admin",
+	Long: `Create a short-lived, single-use link that logs straight into /wp-admin/
+and open it in the browser. Use --print to only print the link.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		user := flagLoginUser
+		if user == "" {
+			user = sc.AdminUser
+		}
+		if user == "" {
+			return fmt.Errorf("site %d has no admin user; pass --user", sc.Port)
+		}
+
+		if err := ensureAutologin(sc); err != nil {
+			return fmt.Errorf("install auto-login plugin: %w", err)
+		}
+
+		token, err := site.NewLoginToken(sc, user, flagLoginTTL)
+		if err != nil {
+			return fmt.Errorf("create login token: %w", err)
+		}
+		link := sc.URL() + "/?" + url.Values{site.LoginParam: {token}}.Encode()
+
+		// Opening the link would spend the token a script asked for.
+		if flagLoginPrint || outputFormat.Structured() {
+			fmt.Println(link)
+		} else if err := openBrowser(link); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open a browser (%v); use this link:\n", err)
+			fmt.Println(link)
+		} else {
+			fmt.Printf("Opened wp-admin for %s (link valid for %s, single use)\n", user, flagLoginTTL)
+		}
+		return emit(loginResult{Port: sc.Port, User: user, URL: link, Expires: time.Now().Add(flagLoginTTL).UTC().Truncate(time.Second)})
+	},
+}
+
+// ensureAutologin installs the plugin redeeming login links, which sites
+// provisioned before auto-login existed don't have. Adopted sites get it
+// through their PHP-FPM pool, so their files stay untouched.
+func ensureAutologin(sc *site.Config) error {
+	if !sc.Adopted {
+		return template.WriteAutologinPlugin(filepath.Join(sc.WPRoot, "wp-content", "mu-plugins", template.AutologinPluginName), sc)
+	}
+	if err := template.WriteAutologinPlugin(template.AutologinPrependPath(sc), sc); err != nil {
+		return err
+	}
+	pool, _ := os.ReadFile(template.FPMPoolPath(sc))
+	if strings.Contains(string(pool), template.AutologinPrependPath(sc)) {
+		return nil
+	}
+	if err := writeFPMPools(sc); err != nil {
+		return err
+	}
+	return restartPHP(sc.PHP)
+}
+
+// openBrowser opens link with the platform's default handler.
+func openBrowser(link string) error {
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	if !exec.CommandExists(opener) {
+		return fmt.Errorf("%s not found", opener)
+	}
+	_, err := exec.Output(opener, link)
+	return err
+}
+
+func init() {
+	loginCmd.Flags().StringVar(&flagLoginUser, "user", "", "User to log in as (default: the site's admin user)")
+	loginCmd.Flags().BoolVar(&flagLoginPrint, "print", false, "Print the link instead of opening it")
+	loginCmd.Flags().DurationVar(&flagLoginTTL, "ttl", time.Minute, "How long the link stays valid")
+	rootCmd.AddCommand(loginCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/logs"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagLogsFollow  bool
+	flagLogsSources []string
+	flagLogsSince   time.Duration
+	flagLogsGrep    string
+	flagLogsLines   int
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <port>...",
+	Short: "Show Caddy access, PHP error and WordPress debug logs",
+	Long: `Show a site's logs as one stream ordered by time.
+
+Sources: access (Caddy access log), php (PHP-FPM error log) and wp-debug
+(wp-content/debug.log). Several sites can be given to merge or follow
+them together.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, s := range flagLogsSources {
+			if !slices.Contains(logs.Sources, s) {
+				return fmt.Errorf("invalid --source %q (want access, php or wp-debug)", s)
+			}
+		}
+
+		var sources []logs.Source
+		for _, arg := range args {
+			sc, err := loadSiteArg(arg)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, logSources(sc, flagLogsSources)...)
+		}
+
+		var filter logs.Filter
+		if flagLogsSince > 0 {
+			filter.Since = time.Now().Add(-flagLogsSince)
+		}
+		if flagLogsGrep != "" {
+			re, err := regexp.Compile(flagLogsGrep)
+			if err != nil {
+				return fmt.Errorf("invalid --grep: %w", err)
+			}
+			filter.Grep = re
+		}
+
+		entries, offsets, err := logs.Read(sources, filter)
+		if err != nil {
+			return err
+		}
+		if flagLogsLines > 0 && len(entries) > flagLogsLines {
+			entries = entries[len(entries)-flagLogsLines:]
+		}
+		for _, e := range entries {
+			fmt.Println(e)
+		}
+
+		if !flagLogsFollow {
+			return nil
+		}
+		stop := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		go func() {
+			<-sig
+			close(stop)
+		}()
+		return logs.Follow(sources, offsets, filter, 500*time.Millisecond, stop, func(e logs.Entry) {
+			fmt.Println(e)
+		})
+	},
+}
+
+// logSources returns the log files of a site, limited to names if given.
+func logSources(sc *site.Config, names []string) []logs.Source {
+	all := []logs.Source{
+		{Site: sc.PortStr(), Name: logs.SourceAccess, Path: filepath.Join(sc.SiteDir, "logs", "access.log")},
+		{Site: sc.PortStr(), Name: logs.SourcePHP, Path: filepath.Join(sc.SiteDir, "logs", "php-error.log")},
+		{Site: sc.PortStr(), Name: logs.SourceWPDebug, Path: filepath.Join(sc.WPRoot, "wp-content", "debug.log")},
+	}
+	if len(names) == 0 {
+		return all
+	}
+	var out []logs.Source
+	for _, s := range all {
+		if slices.Contains(names, s.Name) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&flagLogsFollow, "follow", "f", false, "Keep printing new log lines")
+	logsCmd.Flags().StringSliceVar(&flagLogsSources, "source", nil, "Log sources to show: access, php, wp-debug (default all)")
+	logsCmd.Flags().DurationVar(&flagLogsSince, "since", 0, "Only show entries newer than this (e.g. 10m, 2h)")
+	logsCmd.Flags().StringVar(&flagLogsGrep, "grep", "", "Only show entries matching this regular expression")
+	logsCmd.Flags().IntVarP(&flagLogsLines, "lines", "n", 100, "Number of past entries to show (0 for all)")
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/mail"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagMailHTML  bool
+	flagMailClear bool
+)
+
+var mailCmd = &cobra.Command{
+	Use:   "mail <port> [id]",
+	Short: "List or show mail sent by a site",
+	Long: `List or show mail sent by a site.
+
+Every site's PHP sendmail_path points at locwp, which relays messages to a
+local SMTP sink (` + mail.SMTPAddr + `) and stores them per site. Nothing
+is ever delivered to real recipients. Run "locwp mail serve" for the sink
+and web inbox at http://` + mail.WebAddr + `; it is started automatically
+with sites.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		dir := mail.Dir(sc.SiteDir)
+
+		if flagMailClear {
+			if err := mail.Clear(dir); err != nil {
+				return err
+			}
+			fmt.Printf("Mailbox for site %d cleared\n", sc.Port)
+			return nil
+		}
+
+		if len(args) == 2 {
+			msg, err := mail.Load(dir, args[1])
+			if err != nil {
+				return fmt.Errorf("message %s not found: %w", args[1], err)
+			}
+			fmt.Printf("From:    %s\nTo:      %s\nDate:    %s\nSubject: %s\n\n", msg.From, msg.To, msg.Date.Format("2006-01-02 15:04:05"), msg.Subject)
+			if flagMailHTML || msg.Text == "" {
+				fmt.Println(msg.HTML)
+			} else {
+				fmt.Println(msg.Text)
+			}
+			return nil
+		}
+
+		list, err := mail.List(dir)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Printf("No mail for site %d.\n", sc.Port)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDATE\tFROM\tTO\tSUBJECT")
+		for _, m := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.ID, m.Date.Format("2006-01-02 15:04"), m.From, m.To, m.Subject)
+		}
+		return w.Flush()
+	},
+}
+
+var mailServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the SMTP sink and web inbox",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := config.CurrentProfile()
+		if err != nil {
+			return err
+		}
+		smtpLn, err := net.Listen("tcp", p.SMTPAddr)
+		if err != nil {
+			if errors.Is(err, syscall.EADDRINUSE) {
+				fmt.Printf("Mail sink already running on %s\n", p.SMTPAddr)
+				return nil
+			}
+			return err
+		}
+		webLn, err := net.Listen("tcp", p.WebAddr)
+		if err != nil {
+			smtpLn.Close()
+			return err
+		}
+
+		fmt.Printf("SMTP sink on %s, web inbox on http://%s\n", p.SMTPAddr, p.WebAddr)
+		errc := make(chan error, 2)
+		go func() { errc <- mail.ServeSMTP(smtpLn, deliverMail) }()
+		go func() { errc <- http.Serve(webLn, mail.WebHandler(mailboxes)) }()
+		return <-errc
+	},
+}
+
+var mailSendmailCmd = &cobra.Command{
+	Use:                "sendmail <port>",
+	Short:              "sendmail-compatible entry point used by PHP",
+	Hidden:             true,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// PHP appends sendmail flags such as -t -i -f<from>; only the
+		// message on stdin matters.
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		p, err := config.CurrentProfile()
+		if err != nil {
+			return err
+		}
+		fallback := filepath.Join(config.BaseDir(), "sites", args[0], "mail")
+		return mail.Sendmail(raw, args[0], p.SMTPAddr, fallback)
+	},
+}
+
+// deliverMail stores a message from the SMTP sink in the mailbox of the
+// site it was tagged with, or the shared unassigned mailbox.
+func deliverMail(from string, to []string, data []byte) error {
+	dir := unassignedMailDir()
+	if port := mail.SiteOf(data); port != "" {
+		siteDir := filepath.Join(config.BaseDir(), "sites", filepath.Base(port))
+		if _, err := os.Stat(siteDir); err == nil {
+			dir = mail.Dir(siteDir)
+		}
+	}
+	_, err := mail.Save(dir, data)
+	return err
+}
+
+func unassignedMailDir() string {
+	return filepath.Join(config.BaseDir(), "mail")
+}
+
+// mailboxes lists every site's mailbox for the web inbox.
+func mailboxes() []mail.Mailbox {
+	sites, _ := site.LoadAll()
+	var boxes []mail.Mailbox
+	for _, sc := range sites {
+		boxes = append(boxes, mail.Mailbox{Name: sc.PortStr(), Dir: mail.Dir(sc.SiteDir)})
+	}
+	return append(boxes, mail.Mailbox{Name: "unassigned", Dir: unassignedMailDir()})
+}
+
+func init() {
+	mailCmd.Flags().BoolVar(&flagMailHTML, "html", false, "Print the HTML body instead of plain text")
+	mailCmd.Flags().BoolVar(&flagMailClear, "clear", false, "Delete all stored messages for the site")
+	mailCmd.AddCommand(mailServeCmd)
+	mailCmd.AddCommand(mailSendmailCmd)
+	rootCmd.AddCommand(mailCmd)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+var (
+	flagMountAs   string
+	flagUnmountAs string
+)
+
+var mountCmd = &cobra.Command{
+	Use:   "mount <port> <local-path>",
+	Short: "Link a local plugin or theme directory into a site",
+	Long: `Link a local plugin, theme or mu-plugin directory into a site.
+
+The directory is symlinked into wp-content and recorded in the site's
+config, so the link is restored whenever the site is started.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		if !site.ValidMountType(flagMountAs) {
+			return fmt.Errorf("invalid --as %q (want plugin, theme or mu-plugin)", flagMountAs)
+		}
+		src, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+		m := site.Mount{Source: src, Type: flagMountAs}
+
+		for _, existing := range sc.Mounts {
+			if sc.MountTarget(existing) == sc.MountTarget(m) {
+				return fmt.Errorf("%s is already mounted at %s", existing.Source, sc.MountTarget(m))
+			}
+		}
+		sc.Mounts = append(sc.Mounts, m)
+		if err := site.ApplyMounts(sc); err != nil {
+			return err
+		}
+		if err := saveMounts(sc); err != nil {
+			return err
+		}
+
+		fmt.Printf("Mounted %s as %s %q on site %d\n", src, m.Type, m.Name(), sc.Port)
+		return nil
+	},
+}
+
+var unmountCmd = &cobra.Command{
+	Use:   "unmount <port> <local-path|name>",
+	Short: "Remove a mounted directory from a site",
+	Long: `Remove a mounted directory from a site.
+
+A plugin and a theme can share a name; pass --as to pick one when a name
+matches more than one mount.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		if flagUnmountAs != "" && !site.ValidMountType(flagUnmountAs) {
+			return fmt.Errorf("invalid --as %q (want plugin, theme or mu-plugin)", flagUnmountAs)
+		}
+		abs, _ := filepath.Abs(args[1])
+		var matches []int
+		for i, m := range sc.Mounts {
+			if m.Source != abs && m.Name() != args[1] {
+				continue
+			}
+			if flagUnmountAs == "" || m.Type == flagUnmountAs {
+				matches = append(matches, i)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return fmt.Errorf("no mount %q on site %d", args[1], sc.Port)
+		case len(matches) > 1:
+			return fmt.Errorf("%q matches %d mounts on site %d; pass --as plugin, theme or mu-plugin", args[1], len(matches), sc.Port)
+		}
+
+		i := matches[0]
+		m := sc.Mounts[i]
+		if err := site.RemoveMount(sc, m); err != nil {
+			return err
+		}
+		sc.Mounts = append(sc.Mounts[:i], sc.Mounts[i+1:]...)
+		if err := saveMounts(sc); err != nil {
+			return err
+		}
+		fmt.Printf("Unmounted %s from site %d (source left untouched)\n", m.Source, sc.Port)
+		return nil
+	},
+}
+
+// saveMounts persists a site's mounts and refreshes its FPM pool, whose
+// settings depend on whether the site has mounts. PHP-FPM is only
+// restarted when the pool changed.
+func saveMounts(sc *site.Config) error {
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		return err
+	}
+	before, _ := os.ReadFile(template.FPMPoolPath(sc))
+	if err := writeFPMPools(sc); err != nil {
+		return err
+	}
+	if after, _ := os.ReadFile(template.FPMPoolPath(sc)); !bytes.Equal(before, after) {
+		_ = restartPHP(sc.PHP)
+	}
+	return nil
+}
+
+func init() {
+	mountCmd.Flags().StringVar(&flagMountAs, "as", site.MountPlugin, "Mount type: plugin, theme or mu-plugin")
+	unmountCmd.Flags().StringVar(&flagUnmountAs, "as", "", "Only match mounts of this type: plugin, theme or mu-plugin")
+	rootCmd.AddCommand(mountCmd)
+	rootCmd.AddCommand(unmountCmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+	"github.com/yansircc/locwp/internal/tools"
+)
+
+var phpCmd = &cobra.Command{
+	Use:   "php <port> -- <php args...>",
+	Short: "Run the site's PHP binary in the site's environment",
+	Long: `Run the site's PHP binary in the site's environment.
+
+PHP runs in the site's WordPress root, with the site's variables set as
+for ` + "`locwp shell`" + `. Relative paths are taken from the WordPress root.
+locwp exits with PHP's exit status.`,
+	Example: `  locwp php 10001 -- -v
+  locwp php 10001 -- vendor/bin/phpunit
+  locwp php 10001 -- -r 'echo PHP_VERSION;'`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "-h" || args[0] == "--help" {
+			return cmd.Help()
+		}
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		phpArgs := args[1:]
+		if len(phpArgs) > 0 && phpArgs[0] == "--" {
+			phpArgs = phpArgs[1:]
+		}
+
+		php, env, err := siteEnv(sc)
+		if err != nil {
+			return err
+		}
+		return passExit(cmd, exec.RunWithEnv(sc.WPRoot, env, php, phpArgs...))
+	},
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell <port>",
+	Short: "Open a shell in the site's environment",
+	Long: `Open a shell in the site's environment.
+
+The shell ($SHELL, or /bin/sh) starts in the site's WordPress root with:
+
+  PATH            the site's php and wp shims, then its PHP's directory
+  WP_ROOT         the WordPress root
+  WP_URL          the site URL
+  WP_DB_PATH      the SQLite database file
+  LOCWP_PORT      the site's port
+  LOCWP_SITE_DIR  the site directory
+  WP_CLI_PHP      the site's PHP binary, for the WP-CLI launcher
+  WP_CLI_PHP_ARGS PHP options for WP-CLI
+
+so php, composer and wp all use the site's PHP version.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		_, env, err := siteEnv(sc)
+		if err != nil {
+			return err
+		}
+		sh := os.Getenv("SHELL")
+		if sh == "" {
+			sh = "/bin/sh"
+		}
+
+		fmt.Printf("Shell for site %d (PHP %s) in %s. Exit to return.\n", sc.Port, sc.PHP, sc.WPRoot)
+		return passExit(cmd, exec.RunWithEnv(sc.WPRoot, env, sh))
+	},
+}
+
+// siteEnv writes the site's php and wp shims and returns its PHP binary
+// and the environment to run commands for it in.
+func siteEnv(sc *site.Config) (string, []string, error) {
+	php, err := sitePHP(sc)
+	if err != nil {
+		return "", nil, err
+	}
+	wp, _ := exec.LookPath(tools.WPBin())
+	if err := template.WriteSiteBin(sc, php, wp); err != nil {
+		return "", nil, err
+	}
+	return php, template.SiteEnv(os.Environ(), sc, php), nil
+}
+
+// sitePHP returns the PHP binary of the site's PHP version.
+func sitePHP(sc *site.Config) (string, error) {
+	php := pkgmgr.Default().PHPBin(sc.PHP)
+	if !fileExists(php) {
+		return "", fmt.Errorf("PHP %s not found at %s; run `locwp setup`", sc.PHP, php)
+	}
+	return php, nil
+}
+
+func init() {
+	rootCmd.AddCommand(phpCmd)
+	rootCmd.AddCommand(shellCmd)
+}
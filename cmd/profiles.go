@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
+)
+
+// profileRow is the structured form of one `profiles` line.
+type profileRow struct {
+	config.Profile
+	Dir   string `json:"dir"`
+	Sites int    `json:"sites"`
+}
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List profiles",
+	Long: `List the default profile and every named profile created with --profile.
+Each profile has its own sites, Caddy instance, port range and mail sink.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := config.RootDir()
+		def := config.DefaultProfile()
+		def.Name = "default"
+		rows := []profileRow{{Profile: def, Dir: root}}
+		for _, p := range config.Profiles() {
+			rows = append(rows, profileRow{Profile: p, Dir: filepath.Join(root, "profiles", p.Name)})
+		}
+		for i := range rows {
+			entries, _ := os.ReadDir(filepath.Join(rows[i].Dir, "sites"))
+			rows[i].Sites = len(entries)
+		}
+		if outputFormat.Structured() {
+			return emit(rows)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tPORTS\tCADDY ADMIN\tMAIL\tSITES\tDIR")
+		for _, r := range rows {
+			name := r.Name
+			if name == config.ProfileName() || (name == "default" && config.ProfileName() == "") {
+				name += " *"
+			}
+			fmt.Fprintf(w, "%s\t%d+\t%s\t%s\t%d\t%s\n", name, r.StartPort, orDash(r.CaddyAdmin), r.SMTPAddr, r.Sites, r.Dir)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+}
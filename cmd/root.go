@@ -1,15 +1,224 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
 	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagOutput  string
+	flagJSON    bool
+	flagProfile string
+
+	// outputFormat is the validated --output format.
+	outputFormat = output.Table
+	// resultOut receives structured results. In JSON/YAML mode os.Stdout is
+	// pointed at stderr so progress lines and pawl/wp output can't corrupt
+	// the document; resultOut keeps the real stdout.
+	resultOut io.Writer = os.Stdout
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "locwp",
 	Short: "Local WordPress site manager",
 	Long:  "Create and manage local WordPress development sites using native PHP, MariaDB, and Caddy.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := useProfile(flagProfile); err != nil {
+			return err
+		}
+		if flagJSON {
+			flagOutput = string(output.JSON)
+		}
+		f, err := output.ParseFormat(flagOutput)
+		if err != nil {
+			return err
+		}
+		outputFormat = f
+		if f.Structured() {
+			cmd.Root().SilenceErrors = true
+			cmd.Root().SilenceUsage = true
+			resultOut = os.Stdout
+			os.Stdout = os.Stderr
+		}
+		return nil
+	},
 }
 
+// useProfile activates a named profile given by --profile or
+// LOCWP_PROFILE. The choice is exported so workflows and other locwp
+// processes started from here run in the same profile.
+func useProfile(name string) error {
+	if name == "" {
+		name = config.ProfileName()
+	}
+	if name == "" {
+		return nil
+	}
+	if err := config.ValidateProfileName(name); err != nil {
+		return output.WithCode(output.CodeInvalidArgument, err)
+	}
+	os.Setenv(config.ProfileEnv, name)
+	_, err := config.CurrentProfile()
+	return err
+}
+
+// Execute runs the CLI. Errors are reported here, as a structured object
+// in JSON/YAML mode, so the caller only needs to set the exit status.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	if err == nil {
+		return nil
+	}
+	var status exitStatus
+	if errors.As(err, &status) {
+		return err
+	}
+	var reported reportedError
+	if outputFormat.Structured() && !errors.As(err, &reported) {
+		if werr := output.Write(resultOut, outputFormat, output.NewErrorObject(err)); werr != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return err
+}
+
+// ExitCode returns the status locwp exits with after err.
+func ExitCode(err error) int {
+	var status exitStatus
+	if errors.As(err, &status) {
+		return int(status)
+	}
+	return 1
+}
+
+// exitStatus is the non-zero exit status of a command locwp handed the
+// terminal to, such as `locwp php`. locwp exits with it too, and prints
+// nothing: the command has reported its own failure.
+type exitStatus int
+
+func (s exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// passExit turns the error of a command run in the foreground into its
+// exit status. Failures are the command's own, which it reports, so cobra
+// prints neither usage nor the error.
+func passExit(cmd *cobra.Command, err error) error {
+	cmd.SilenceUsage, cmd.SilenceErrors = true, true
+	if code := exec.ExitCode(err); code > 0 {
+		return exitStatus(code)
+	}
+	return err
+}
+
+// emit writes a command's result in JSON/YAML mode. In table mode the
+// command has already printed its human-readable output and emit is a no-op.
+func emit(v any) error {
+	if !outputFormat.Structured() {
+		return nil
+	}
+	return output.Write(resultOut, outputFormat, v)
+}
+
+// reportedError is a failure whose structured result has already been
+// written by emitFailure, so Execute doesn't write an error object after it.
+type reportedError struct{ error }
+
+func (e reportedError) Unwrap() error { return e.error }
+
+// emitFailure writes the result of a command that partly failed, such as
+// a bulk action, and returns err for the exit status.
+func emitFailure(v any, err error) error {
+	if werr := emit(v); werr != nil {
+		return werr
+	}
+	return reportedError{err}
+}
+
+// siteResult is the structured description of a created site.
+type siteResult struct {
+	Port       int      `json:"port"`
+	URL        string   `json:"url"`
+	AdminURL   string   `json:"admin_url"`
+	PHP        string   `json:"php"`
+	WPVersion  string   `json:"wp_version"`
+	State      string   `json:"state,omitempty"`
+	Adopted    bool     `json:"adopted,omitempty"`
+	SiteDir    string   `json:"site_dir"`
+	WPRoot     string   `json:"wp_root"`
+	DBPath     string   `json:"db_path,omitempty"`
+	AdminUser  string   `json:"admin_user,omitempty"`
+	AdminPass  string   `json:"admin_pass,omitempty"`
+	AdminEmail string   `json:"admin_email,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+func newSiteResult(sc *site.Config) siteResult {
+	r := siteResult{
+		Port:       sc.Port,
+		URL:        sc.URL(),
+		AdminURL:   sc.URL() + "/wp-admin/",
+		PHP:        sc.PHP,
+		WPVersion:  sc.WPVer,
+		State:      sc.State,
+		Adopted:    sc.Adopted,
+		SiteDir:    sc.SiteDir,
+		WPRoot:     sc.WPRoot,
+		AdminUser:  sc.AdminUser,
+		AdminEmail: sc.AdminEmail,
+		Tags:       sc.Tags,
+	}
+	if sc.DBType != site.DBMySQL {
+		r.DBPath = sc.DBPath()
+	}
+	if v, err := site.WPVersion(sc.WPRoot); err == nil {
+		r.WPVersion = v
+	}
+	r.AdminPass, _ = site.AdminPassword(sc)
+	return r
+}
+
+// lifecycleResult is the structured result of start, stop and delete.
+type lifecycleResult struct {
+	Port   int    `json:"port"`
+	Action string `json:"action"`
+	URL    string `json:"url,omitempty"`
+	// Kept is the WordPress root left in place when an adopted site is deleted.
+	Kept string `json:"kept,omitempty"`
+	// Trash is the trash entry a deleted site was moved to.
+	Trash string `json:"trash,omitempty"`
+}
+
+// loadSiteArg parses a port argument and loads that site's config.
+func loadSiteArg(arg string) (*site.Config, error) {
+	port, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, output.WithCode(output.CodeInvalidArgument, fmt.Errorf("invalid port %q: %w", arg, err))
+	}
+	sc, err := site.LoadByPort(port)
+	return sc, output.WithCode(output.CodeNotFound, err)
+}
+
+// runWorkflow runs one of a site's pawl workflows.
+func runWorkflow(sc *site.Config, args ...string) error {
+	err := exec.RunInDir(sc.SiteDir, "pawl", append([]string{"start"}, args...)...)
+	if err != nil {
+		return output.WithCode(output.CodeWorkflowFailed, fmt.Errorf("pawl workflow %s: %w", args[len(args)-1], err))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&flagOutput, "output", "o", string(output.Table), "Output format: table, json or yaml")
+	rootCmd.PersistentFlags().BoolVar(&flagJSON, "json", false, "Shorthand for --output json")
+	rootCmd.PersistentFlags().StringVar(&flagProfile, "profile", "", "Use a named, isolated profile (env: LOCWP_PROFILE)")
 }
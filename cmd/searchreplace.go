@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/searchreplace"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagSRDryRun      bool
+	flagSRSkipColumns string
+)
+
+var searchReplaceCmd = &cobra.Command{
+	Use:   "search-replace <port> <old> <new>",
+	Short: "Replace a string across a site's database",
+	Long: `Replace a string across a site's SQLite database.
+
+PHP-serialized values are rebuilt with correct length prefixes, and JSON
+values are matched in both plain and slash-escaped form, so it is safe to
+use for changing a site's URL.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		var skip []string
+		if flagSRSkipColumns != "" {
+			skip = strings.Split(flagSRSkipColumns, ",")
+		}
+		results, err := searchreplace.Run(sc.DBPath(), args[1], args[2], searchreplace.Options{
+			DryRun:      flagSRDryRun,
+			SkipColumns: skip,
+		})
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TABLE\tROWS\tREPLACEMENTS")
+		total := 0
+		for _, r := range results {
+			if r.Replacements == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\n", r.Table, r.Rows, r.Replacements)
+			total += r.Replacements
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if flagSRDryRun {
+			fmt.Printf("%d replacements to be made (dry run).\n", total)
+		} else {
+			fmt.Printf("Made %d replacements.\n", total)
+		}
+		return nil
+	},
+}
+
+// rewriteURL replaces oldURL with the site's own URL across its database.
+// Used whenever locwp changes the URL a site is served from.
+func rewriteURL(sc *site.Config, oldURL string) error {
+	if oldURL == "" || oldURL == sc.URL() {
+		return nil
+	}
+	_, err := searchreplace.Run(sc.DBPath(), oldURL, sc.URL(), searchreplace.Options{SkipColumns: []string{"guid"}})
+	return err
+}
+
+func init() {
+	searchReplaceCmd.Flags().BoolVar(&flagSRDryRun, "dry-run", false, "Report per-table counts without changing the database")
+	searchReplaceCmd.Flags().StringVar(&flagSRSkipColumns, "skip-columns", "", "Comma-separated column names to leave untouched")
+	rootCmd.AddCommand(searchReplaceCmd)
+}
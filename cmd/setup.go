@@ -3,69 +3,107 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/yansircc/locwp/internal/config"
 	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
 	"github.com/yansircc/locwp/internal/template"
+	"github.com/yansircc/locwp/internal/tools"
 )
 
-var flagSetupPHP string
+var (
+	flagSetupPHP          string
+	flagSetupPkg          string
+	flagSetupTools        bool
+	flagSetupUpgradeTools bool
+	flagSetupMirror       string
+	flagSetupCaddyVersion string
+	flagSetupWPCLIVersion string
+	flagSetupCaddySHA512  string
+	flagSetupWPCLISHA512  string
+)
 
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Install dependencies (PHP, Caddy, WP-CLI)",
+	Long: `Install dependencies (PHP, Caddy, WP-CLI).
+
+The package manager is detected (Homebrew, apt, dnf or pacman) unless set
+with --package-manager or LOCWP_PKG. With "manual", nothing is installed:
+PHP, php-fpm, Caddy and wp must already be on PATH.
+
+With --tools, pinned Caddy and WP-CLI releases are downloaded into
+LOCWP_HOME/bin instead, verified against checksums built into locwp and
+recorded in LOCWP_HOME/bin/tools.lock.json. Later runs keep the locked
+versions until --upgrade-tools. A version locwp doesn't pin needs the
+SHA-512 published with the release, given with --caddy-sha512 or
+--wp-cli-sha512 or confirmed when asked. Every site's workflows are then
+rewritten to use the installed tools.
+
+Packages setup installs are recorded in LOCWP_HOME/bin/packages.json;
+` + "`locwp uninstall`" + ` removes those and leaves packages you already had.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		phpFormula := template.PHPFormulaName(flagSetupPHP)
+		if flagSetupPkg != "" {
+			if _, err := pkgmgr.ByName(flagSetupPkg); err != nil {
+				return output.WithCode(output.CodeInvalidArgument, err)
+			}
+			// Exported so workflows and child processes agree.
+			os.Setenv("LOCWP_PKG", flagSetupPkg)
+		}
+		mgr := pkgmgr.Default()
+		fmt.Printf("Using %s\n", mgr.Name())
+
+		managed, err := setupTools()
+		if err != nil {
+			return err
+		}
 
 		deps := []struct {
-			name    string
-			check   string
-			install string
+			name      string
+			installed bool
+			pkgs      []string
 		}{
-			{phpFormula, "", "brew install " + phpFormula},
-			{"caddy", "caddy", "brew install caddy"},
-			{"wp-cli", "wp", "brew install wp-cli"},
+			{"php " + flagSetupPHP, fileExists(mgr.PHPBin(flagSetupPHP)), mgr.PHPPackages(flagSetupPHP)},
+			{"caddy", managed || exec.CommandExists("caddy"), []string{mgr.CaddyPackage()}},
+			{"wp-cli", managed || exec.CommandExists("wp"), []string{mgr.WPCLIPackage()}},
 		}
 
 		for _, d := range deps {
-			if d.check != "" && exec.CommandExists(d.check) {
+			if d.installed {
 				fmt.Printf("  [ok] %s already installed\n", d.name)
 				continue
 			}
-			// For PHP, check if the formula is already installed via brew
-			if d.check == "" {
-				installed, _ := exec.Output("brew", "list", "--formula", d.name)
-				if strings.TrimSpace(installed) != "" {
-					fmt.Printf("  [ok] %s already installed\n", d.name)
-					continue
-				}
+			if len(d.pkgs) == 0 || d.pkgs[0] == "" {
+				return fmt.Errorf("%s is not installed and %s doesn't package it; install it yourself", d.name, mgr.Name())
 			}
 			fmt.Printf("  ... Installing %s...\n", d.name)
-			if err := exec.Run("bash", "-c", d.install); err != nil {
+			if err := pkgmgr.Install(mgr, d.pkgs...); err != nil {
 				return fmt.Errorf("failed to install %s: %w", d.name, err)
 			}
+			// Uninstall removes only what setup installed.
+			if err := pkgmgr.RecordInstalled(mgr, d.name, d.pkgs); err != nil {
+				return fmt.Errorf("record installed packages: %w", err)
+			}
 			fmt.Printf("  [ok] %s installed\n", d.name)
 		}
-
-		// Ensure php is linked (php@x.y is keg-only)
-		if !exec.CommandExists("php") {
-			fmt.Printf("  ... Linking %s...\n", phpFormula)
-			_ = exec.Run("brew", "link", "--force", "--overwrite", phpFormula)
+		if err := mgr.PostInstall(flagSetupPHP); err != nil {
+			return err
 		}
 
 		// Configure PHP limits for WordPress
 		fmt.Println("\nConfiguring PHP...")
-		if err := template.WritePHPConf(flagSetupPHP); err != nil {
-			return fmt.Errorf("failed to configure PHP: %w", err)
+		for _, dir := range mgr.PHPConfDirs(flagSetupPHP) {
+			if err := template.WritePHPConf(dir); err != nil {
+				return fmt.Errorf("failed to configure PHP: %w", err)
+			}
 		}
 		fmt.Println("  [ok] PHP limits configured")
 
-		// Start user-level services (no sudo)
 		fmt.Println("\nStarting services...")
-		_ = exec.Run("brew", "services", "restart", phpFormula)
+		_ = restartPHP(flagSetupPHP)
 
 		// Configure Caddy
 		fmt.Println("\nConfiguring Caddy...")
@@ -74,16 +112,26 @@ var setupCmd = &cobra.Command{
 			return fmt.Errorf("failed to create caddy sites dir: %w", err)
 		}
 
-		// Write main Caddyfile that imports per-site configs
-		caddyfilePath := filepath.Join(template.HomebrewPrefix(), "etc", "Caddyfile")
-		caddyfileContent := fmt.Sprintf("{\n\tauto_https off\n}\n\nimport %s/*.caddy\n", caddySitesDir)
-		if err := os.WriteFile(caddyfilePath, []byte(caddyfileContent), 0644); err != nil {
+		// Import per-site configs into the main Caddyfile
+		prev, _ := template.RecordedCaddyMode()
+		if err := template.WriteCaddyfile(); err != nil {
 			return fmt.Errorf("failed to write Caddyfile: %w", err)
 		}
-		fmt.Println("  [ok] Caddyfile configured")
+		fmt.Printf("  [ok] %s configured\n", caddyfilePath())
+		// Hand the site ports over from whatever served them before.
+		if prev.Shared != "" && (template.OwnsCaddyfile() || prev.Shared != caddyfilePath()) {
+			_, _ = exec.CombinedOutput("sh", "-c", mgr.ServiceCmd("restart", mgr.CaddyService()))
+			fmt.Printf("  [ok] locwp's block removed from %s\n", prev.Shared)
+		}
+		if !template.OwnsCaddyfile() {
+			_, _ = exec.CombinedOutput("sh", "-c", template.CaddyStopCmd())
+		}
+		if !template.OwnsCaddyfile() && fileExists(template.CaddyfileBackupPath()) {
+			fmt.Printf("  [ok] Original kept at %s\n", template.CaddyfileBackupPath())
+		}
 
-		// Start Caddy (user-level service, high ports only — no sudo)
-		_ = exec.Run("brew", "services", "restart", "caddy")
+		// Start Caddy (high ports only)
+		_ = reloadCaddy()
 		fmt.Println("  [ok] Caddy started")
 
 		fmt.Println("\nSetup complete.")
@@ -91,7 +139,134 @@ var setupCmd = &cobra.Command{
 	},
 }
 
+// setupTools installs the locked (or, with --upgrade-tools, pinned) Caddy
+// and WP-CLI into LOCWP_HOME/bin when requested or already in use, and
+// reports whether they are managed there.
+func setupTools() (bool, error) {
+	lock, err := tools.ReadLock()
+	if err != nil {
+		return false, err
+	}
+	if !flagSetupTools && !flagSetupUpgradeTools && len(lock) == 0 {
+		return false, nil
+	}
+	versions := tools.Versions(lock, flagSetupUpgradeTools)
+	if flagSetupUpgradeTools {
+		if flagSetupCaddyVersion != "" {
+			versions[tools.Caddy] = flagSetupCaddyVersion
+		}
+		if flagSetupWPCLIVersion != "" {
+			versions[tools.WPCLI] = flagSetupWPCLIVersion
+		}
+	}
+	mirror := flagSetupMirror
+	if mirror == "" {
+		mirror = os.Getenv(tools.MirrorEnv)
+	}
+
+	sums := map[string]string{tools.Caddy: flagSetupCaddySHA512, tools.WPCLI: flagSetupWPCLISHA512}
+	var confirmSum func(asset, sum string) bool
+	if interactive() {
+		confirmSum = func(asset, sum string) bool {
+			fmt.Printf("  [!!] locwp has no checksum for %s. Its SHA-512 is:\n       %s\n", asset, sum)
+			return confirm("Does it match the checksum published with the release?", false)
+		}
+	}
+
+	fmt.Println("\nInstalling tools into " + tools.Dir() + "...")
+	lock, err = tools.Ensure(versions, sums, mirror, confirmSum)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range []string{tools.Caddy, tools.WPCLI} {
+		fmt.Printf("  [ok] %s %s\n", name, lock[name].Version)
+	}
+
+	// Workflows name the tools by path, captured when they were written.
+	if flagSetupTools || flagSetupUpgradeTools {
+		n, err := rewriteWorkflows()
+		if err != nil {
+			return false, fmt.Errorf("rewrite site workflows: %w", err)
+		}
+		if n > 0 {
+			fmt.Printf("  [ok] Workflows of %d site(s) updated\n", n)
+		}
+	}
+	return true, nil
+}
+
+// rewriteWorkflows regenerates the workflows of every site in every
+// profile, and returns how many sites it updated.
+func rewriteWorkflows() (int, error) {
+	active := config.ProfileName()
+	defer os.Setenv(config.ProfileEnv, active)
+
+	names := []string{""}
+	for _, p := range config.Profiles() {
+		names = append(names, p.Name)
+	}
+	n := 0
+	for _, name := range names {
+		os.Setenv(config.ProfileEnv, name)
+		sites, err := site.LoadAll()
+		if err != nil {
+			return n, err
+		}
+		for _, sc := range sites {
+			if err := writeWorkflows(sc); err != nil {
+				return n, fmt.Errorf("site %d: %w", sc.Port, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// restartPHP restarts the PHP-FPM service for a PHP version so it picks up
+// new pools and settings.
+func restartPHP(version string) error {
+	return exec.Run("sh", "-c", phpRestartCmd(version))
+}
+
+// phpRestartCmd is the shell command restarting PHP-FPM for a version.
+func phpRestartCmd(version string) string {
+	mgr := pkgmgr.Default()
+	return mgr.ServiceCmd("restart", mgr.PHPService(version))
+}
+
+// installHint returns the command installing pkgs, or a manual
+// instruction when the package manager can't.
+func installHint(mgr pkgmgr.Manager, pkgs []string) string {
+	if cmd := mgr.InstallCmd(pkgs...); cmd != "" {
+		return cmd
+	}
+	return "install PHP with pdo_sqlite and put it on PATH"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// caddyfilePath returns the active profile's main Caddyfile.
+func caddyfilePath() string {
+	return template.CaddyfilePath()
+}
+
+// reloadCaddy (re)starts the active profile's Caddy.
+func reloadCaddy() error {
+	return exec.Run("sh", "-c", template.CaddyReloadCmd())
+}
+
 func init() {
 	setupCmd.Flags().StringVar(&flagSetupPHP, "php", config.DefaultPHP, "PHP version to install (e.g. 8.1, 8.2, 8.3)")
+	setupCmd.Flags().StringVar(&flagSetupPkg, "package-manager", "", "Package manager: brew, apt, dnf, pacman or manual (default: detected)")
+	setupCmd.Flags().BoolVar(&flagSetupTools, "tools", false, "Install pinned Caddy and WP-CLI into LOCWP_HOME/bin")
+	setupCmd.Flags().BoolVar(&flagSetupUpgradeTools, "upgrade-tools", false, "Move the installed tools to the pinned (or given) versions")
+	setupCmd.Flags().StringVar(&flagSetupMirror, "mirror", "", "Directory with release files to install tools from, for offline use")
+	setupCmd.Flags().StringVar(&flagSetupCaddyVersion, "caddy-version", "", "Caddy version for --upgrade-tools")
+	setupCmd.Flags().StringVar(&flagSetupWPCLIVersion, "wp-cli-version", "", "WP-CLI version for --upgrade-tools")
+	setupCmd.Flags().StringVar(&flagSetupCaddySHA512, "caddy-sha512", "", "SHA-512 of the Caddy release for --caddy-version, from caddy_<v>_checksums.txt")
+	setupCmd.Flags().StringVar(&flagSetupWPCLISHA512, "wp-cli-sha512", "", "SHA-512 of wp-cli-<v>.phar for --wp-cli-version, from its .sha512 file")
 	rootCmd.AddCommand(setupCmd)
 }
@@ -2,36 +2,61 @@ package cmd
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/spf13/cobra"
-	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/pkgmgr"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
 )
 
+var startSelector siteSelector
+
 var startCmd = &cobra.Command{
-	Use:   "start <port>",
-	Short: "Start a WordPress site",
-	Args:  cobra.ExactArgs(1),
+	Use:   "start <port>... | --all | --tag <tag>",
+	Short: "Start WordPress sites",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid port %q: %w", args[0], err)
-		}
-		sc, err := site.LoadByPort(port)
+		sites, err := startSelector.sites(cmd, args)
 		if err != nil {
 			return err
 		}
+		return runLifecycle(&startSelector, args, sites, startSite, startServices)
+	},
+}
 
-		if err := exec.RunInDir(sc.SiteDir, "pawl", "start", "--reset", "start"); err != nil {
-			return err
-		}
+func startSite(sc *site.Config, quiet bool) (lifecycleResult, error) {
+	res := lifecycleResult{Port: sc.Port, Action: "started", URL: sc.URL()}
 
+	// Restore mounted plugin/theme symlinks
+	if err := site.ApplyMounts(sc); err != nil {
+		return res, err
+	}
+
+	if err := runSiteWorkflow(sc, quiet, "--reset", "start"); err != nil {
+		return res, err
+	}
+
+	if !quiet {
 		fmt.Printf("Site started at %s (PHP %s)\n", sc.URL(), sc.PHP)
-		return nil
-	},
+	}
+	return res, nil
+}
+
+// startServices starts PHP-FPM for every PHP version in the batch and
+// reloads Caddy once.
+func startServices(sites []*site.Config) error {
+	pm := pkgmgr.Default()
+	for _, v := range sitePHPVersions(sites) {
+		if err := serviceCmd(pm.ServiceCmd("start", pm.PHPService(v))); err != nil {
+			return fmt.Errorf("start PHP %s: %w", v, err)
+		}
+	}
+	if err := serviceCmd(template.CaddyReloadCmd()); err != nil {
+		return fmt.Errorf("reload Caddy: %w", err)
+	}
+	return nil
 }
 
 func init() {
+	startSelector.register(startCmd)
 	rootCmd.AddCommand(startCmd)
 }
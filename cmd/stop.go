@@ -2,36 +2,46 @@ package cmd
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/spf13/cobra"
-	"github.com/yansircc/locwp/internal/exec"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
 )
 
+var stopSelector siteSelector
+
 var stopCmd = &cobra.Command{
-	Use:   "stop <port>",
-	Short: "Stop a WordPress site",
-	Args:  cobra.ExactArgs(1),
+	Use:   "stop <port>... | --all | --tag <tag>",
+	Short: "Stop WordPress sites",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid port %q: %w", args[0], err)
-		}
-		sc, err := site.LoadByPort(port)
+		sites, err := stopSelector.sites(cmd, args)
 		if err != nil {
 			return err
 		}
+		return runLifecycle(&stopSelector, args, sites, stopSite, stopServices)
+	},
+}
 
-		if err := exec.RunInDir(sc.SiteDir, "pawl", "start", "--reset", "stop"); err != nil {
-			return err
-		}
+func stopSite(sc *site.Config, quiet bool) (lifecycleResult, error) {
+	res := lifecycleResult{Port: sc.Port, Action: "stopped"}
+	if err := runSiteWorkflow(sc, quiet, "--reset", "stop"); err != nil {
+		return res, err
+	}
 
+	if !quiet {
 		fmt.Printf("Site %d stopped\n", sc.Port)
-		return nil
-	},
+	}
+	return res, nil
+}
+
+// stopServices reloads Caddy once for the batch. As in the stop workflow,
+// a failed reload doesn't fail the stop.
+func stopServices([]*site.Config) error {
+	_ = serviceCmd(template.CaddyReloadCmd())
+	return nil
 }
 
 func init() {
+	stopSelector.register(stopCmd)
 	rootCmd.AddCommand(stopCmd)
 }
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var flagTagRemove bool
+
+var tagCmd = &cobra.Command{
+	Use:   "tag <port> [tag...]",
+	Short: "Show, add or remove a site's tags",
+	Long: `Show, add or remove a site's tags.
+
+Tags select sites for start, stop, delete and list with --tag.`,
+	Example: `  locwp tag 10001 client-a demo
+  locwp tag 10001 --remove demo
+  locwp start --tag client-a`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sc, err := loadSiteArg(args[0])
+		if err != nil {
+			return err
+		}
+		tags := args[1:]
+		for _, t := range tags {
+			if err := site.ValidateTag(t); err != nil {
+				return output.WithCode(output.CodeInvalidArgument, err)
+			}
+		}
+
+		if len(tags) > 0 {
+			if flagTagRemove {
+				sc.RemoveTags(tags...)
+			} else {
+				sc.AddTags(tags...)
+			}
+			if err := site.Save(sc.SiteDir, sc); err != nil {
+				return err
+			}
+			syncWPCLIAliases()
+		}
+
+		fmt.Printf("Site %d tags: %s\n", sc.Port, orDash(strings.Join(sc.Tags, ", ")))
+		res := tagResult{Port: sc.Port, Tags: sc.Tags}
+		if res.Tags == nil {
+			res.Tags = []string{}
+		}
+		return emit(res)
+	},
+}
+
+// tagResult is the structured result of `tag`.
+type tagResult struct {
+	Port int      `json:"port"`
+	Tags []string `json:"tags"`
+}
+
+func init() {
+	tagCmd.Flags().BoolVarP(&flagTagRemove, "remove", "r", false, "Remove the given tags instead of adding them")
+	rootCmd.AddCommand(tagCmd)
+}
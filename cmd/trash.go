@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+var (
+	flagTrashEmptyYes     bool
+	flagTrashEmptyExpired bool
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List deleted sites",
+	Long: `List deleted sites.
+
+` + "`locwp delete`" + ` moves a site here instead of removing it. A trashed site keeps
+its port reserved for new sites until it expires; restore it with
+` + "`locwp trash restore <port>`" + ` or remove it for good with ` + "`locwp trash empty`" + `.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := site.ListTrash()
+		if err != nil {
+			return err
+		}
+		if entries == nil {
+			entries = []site.TrashEntry{}
+		}
+		if outputFormat.Structured() {
+			return emit(entries)
+		}
+		if len(entries) == 0 {
+			fmt.Println("Trash is empty.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPORT\tDELETED\tRESERVED UNTIL")
+		for _, e := range entries {
+			until := e.ReservedUntil().Local().Format("2006-01-02 15:04")
+			if time.Now().After(e.ReservedUntil()) {
+				until = "expired"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.ID, e.Port, e.DeletedAt.Local().Format("2006-01-02 15:04"), until)
+		}
+		return w.Flush()
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <port|id>",
+	Short: "Restore a deleted site and start it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  restoreTrash,
+}
+
+// undeleteCmd is `trash restore` at the top level, next to delete.
+var undeleteCmd = &cobra.Command{
+	Use:   "undelete <port|id>",
+	Short: "Restore a deleted site and start it (same as trash restore)",
+	Args:  cobra.ExactArgs(1),
+	RunE:  restoreTrash,
+}
+
+func restoreTrash(cmd *cobra.Command, args []string) error {
+	e, err := site.FindTrash(args[0])
+	if err != nil {
+		return output.WithCode(output.CodeNotFound, err)
+	}
+	if _, err := os.Stat(e.SiteDir); err == nil {
+		return output.WithCode(output.CodeAlreadyExists, fmt.Errorf("cannot restore site %d: port is in use by another site", e.Port))
+	}
+	sc, err := site.RestoreFromTrash(e)
+	if err != nil {
+		return err
+	}
+
+	// delete's destroy workflow removed the Caddy conf and FPM pool.
+	if err := writeSiteFiles(sc); err != nil {
+		return err
+	}
+	if err := site.ApplyMounts(sc); err != nil {
+		return err
+	}
+	if err := runWorkflow(sc, "--reset", "start"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Site %d restored at %s\n", sc.Port, sc.URL())
+	return emit(lifecycleResult{Port: sc.Port, Action: "restored", URL: sc.URL()})
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty [port|id...]",
+	Short: "Permanently delete trashed sites",
+	Long: `Permanently delete trashed sites.
+
+With no arguments every trashed site is deleted, after confirmation.
+--expired only deletes the ones whose port reservation has run out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var entries []site.TrashEntry
+		if len(args) > 0 {
+			for _, ref := range args {
+				e, err := site.FindTrash(ref)
+				if err != nil {
+					return output.WithCode(output.CodeNotFound, err)
+				}
+				entries = append(entries, e)
+			}
+		} else {
+			all, err := site.ListTrash()
+			if err != nil {
+				return err
+			}
+			for _, e := range all {
+				if !flagTrashEmptyExpired || time.Now().After(e.ReservedUntil()) {
+					entries = append(entries, e)
+				}
+			}
+		}
+
+		res := trashEmptyResult{Purged: []string{}}
+		if len(entries) == 0 {
+			fmt.Println("Nothing to delete.")
+			return emit(res)
+		}
+		if !flagTrashEmptyYes && len(args) == 0 && !flagTrashEmptyExpired {
+			if err := confirmOrYes(fmt.Sprintf("Permanently delete %d trashed site(s)? This cannot be undone.", len(entries))); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range entries {
+			if err := site.PurgeTrash(e); err != nil {
+				fmt.Printf("  [!!] %s: %v\n", e.ID, err)
+				res.Failed = append(res.Failed, e.ID)
+				continue
+			}
+			fmt.Printf("  [ok] Deleted %s\n", e.ID)
+			res.Purged = append(res.Purged, e.ID)
+		}
+		if len(res.Failed) > 0 {
+			return emitFailure(res, fmt.Errorf("%d trashed site(s) could not be deleted", len(res.Failed)))
+		}
+		return emit(res)
+	},
+}
+
+// trashEmptyResult is the structured result of `trash empty`.
+type trashEmptyResult struct {
+	Purged []string `json:"purged"`
+	Failed []string `json:"failed,omitempty"`
+}
+
+func init() {
+	trashEmptyCmd.Flags().BoolVarP(&flagTrashEmptyYes, "yes", "y", false, "Don't ask for confirmation")
+	trashEmptyCmd.Flags().BoolVar(&flagTrashEmptyExpired, "expired", false, "Only delete sites whose port reservation has expired")
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(undeleteCmd)
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yansircc/locwp/internal/cleanup"
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+var (
+	flagUninstallYes          bool
+	flagUninstallDryRun       bool
+	flagUninstallKeepPackages bool
+	flagUninstallBackup       string
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove all sites and everything locwp installed",
+	Long: `Remove all sites and everything locwp installed.
+
+Covers every profile: site directories, secrets, PHP-FPM pools and
+locwp.ini in every PHP version's config, sockets, locwp's Caddyfile block
+(the original Caddyfile is restored), its WP-CLI aliases, LOCWP_HOME, and the PHP, Caddy and
+WP-CLI packages that ` + "`locwp setup`" + ` installed unless --keep-packages is given.
+Packages you had before setup are kept, and so is Caddy if the Caddyfile
+still serves other sites. Adopted WordPress directories are never touched.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Uninstall covers every profile, and the shared Caddyfile belongs
+		// to the default one.
+		os.Unsetenv(config.ProfileEnv)
+
+		mgr := pkgmgr.Default()
+		inv := cleanup.Collect(mgr)
+		res := uninstallResult{Artifacts: inv.Artifacts, PHPVersions: inv.PHPVersions}
+		if res.Artifacts == nil {
+			res.Artifacts = []cleanup.Artifact{}
+		}
+
+		keepCaddy := template.CaddyfileServesOthers()
+		if !flagUninstallKeepPackages {
+			pkgs, err := uninstallPackages(keepCaddy)
+			if err != nil {
+				return err
+			}
+			res.Packages = pkgs
+		}
+
+		printInventory(inv, res.Packages)
+		if keepCaddy {
+			fmt.Printf("Caddy is kept: %s serves other sites.\n", template.CaddyfilePath())
+		}
+		if flagUninstallDryRun || len(inv.Artifacts) == 0 {
+			if len(inv.Artifacts) == 0 {
+				fmt.Println("Nothing to remove.")
+			}
+			return emit(res)
+		}
+
+		backup := flagUninstallBackup
+		if backup == "" && !flagUninstallYes && len(inv.Sites) > 0 && interactive() {
+			home, _ := os.UserHomeDir()
+			suggested := filepath.Join(home, "locwp-backup-"+time.Now().Format("20060102-150405")+".tar.gz")
+			if confirm(fmt.Sprintf("Back up %d site(s) to %s first?", len(inv.Sites), suggested), true) {
+				backup = suggested
+			}
+		}
+		if backup != "" {
+			fmt.Printf("  ... Backing up to %s\n", backup)
+			if err := cleanup.Backup(backup); err != nil {
+				return err
+			}
+			fmt.Printf("  [ok] Backup written to %s\n", backup)
+			res.Backup = backup
+		}
+
+		if !flagUninstallYes {
+			if !interactive() {
+				return output.WithCode(output.CodeInvalidArgument, errors.New("uninstall needs confirmation: pass --yes to run without a terminal"))
+			}
+			if !confirm("Remove everything listed above?", false) {
+				return errors.New("uninstall cancelled")
+			}
+		}
+
+		stopInstances()
+		for _, sc := range inv.Sites {
+			_ = site.DeleteSecrets(sc)
+		}
+		for _, a := range inv.Artifacts {
+			if err := cleanup.Remove(a); err != nil {
+				fmt.Printf("  [!!] %s: %v\n", a.Path, err)
+				res.Failed = append(res.Failed, a.Path)
+				continue
+			}
+			if a.Kind != cleanup.KindSite {
+				fmt.Printf("  [ok] Removed %s\n", a.Path)
+			}
+		}
+		restartServices(mgr, inv, res.Packages, keepCaddy)
+
+		for _, p := range res.Packages {
+			fmt.Printf("  ... Removing %s\n", strings.Join(p.Packages, " "))
+			pm, err := pkgmgr.ByName(p.Manager)
+			if err == nil {
+				err = pkgmgr.Uninstall(pm, p.Packages...)
+			}
+			if err != nil {
+				fmt.Printf("  [!!] %v\n", err)
+				res.Failed = append(res.Failed, strings.Join(p.Packages, " "))
+			}
+		}
+
+		if len(res.Failed) > 0 {
+			return emitFailure(res, fmt.Errorf("uninstall left %d item(s) behind", len(res.Failed)))
+		}
+		fmt.Println("\nlocwp uninstalled.")
+		return emit(res)
+	},
+}
+
+// uninstallResult is the structured result of `uninstall`.
+type uninstallResult struct {
+	Artifacts   []cleanup.Artifact `json:"artifacts"`
+	PHPVersions []string           `json:"php_versions"`
+	Packages    []pkgmgr.Installed `json:"packages,omitempty"`
+	Backup      string             `json:"backup,omitempty"`
+	Failed      []string           `json:"failed,omitempty"`
+}
+
+// uninstallPackages returns the packages to remove: those setup recorded
+// installing, less Caddy while it is still needed.
+func uninstallPackages(keepCaddy bool) ([]pkgmgr.Installed, error) {
+	installed, err := pkgmgr.ReadInstalled()
+	if err != nil {
+		return nil, err
+	}
+	var remove []pkgmgr.Installed
+	for _, p := range installed {
+		if p.Name == "caddy" && keepCaddy {
+			continue
+		}
+		remove = append(remove, p)
+	}
+	return remove, nil
+}
+
+func printInventory(inv *cleanup.Inventory, packages []pkgmgr.Installed) {
+	if len(inv.Artifacts) == 0 {
+		return
+	}
+	fmt.Println("locwp will remove:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, a := range inv.Artifacts {
+		what := a.Path
+		switch {
+		case a.Kind == cleanup.KindSite && a.Profile != "":
+			what = fmt.Sprintf("%d (profile %s)", a.Port, a.Profile)
+		case a.Kind == cleanup.KindSite:
+			what = fmt.Sprintf("%d", a.Port)
+		case a.Kind == cleanup.KindCaddyfile:
+			what = a.Path + " (locwp block only)"
+		case a.Kind == cleanup.KindWPCLIAlias:
+			what = a.Path + " (locwp aliases only)"
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", a.Kind, what)
+	}
+	for _, p := range packages {
+		fmt.Fprintf(w, "  package\t%s\n", strings.Join(p.Packages, " "))
+	}
+	w.Flush()
+	for _, sc := range inv.Sites {
+		if sc.Adopted {
+			fmt.Printf("Adopted WordPress files are kept: %s\n", sc.WPRoot)
+		}
+	}
+}
+
+// stopInstances stops the Caddy instances and mail sinks locwp started
+// for itself, in every profile.
+func stopInstances() {
+	quiet := func(cmd string) {
+		if cmd != "" {
+			_, _ = exec.CombinedOutput("sh", "-c", cmd)
+		}
+	}
+	quiet(template.CaddyStopCmd())
+	for _, p := range config.Profiles() {
+		os.Setenv(config.ProfileEnv, p.Name)
+		quiet(template.CaddyStopCmd())
+	}
+	os.Unsetenv(config.ProfileEnv)
+	quiet("pkill -f '" + template.LocwpBin() + " mail serve'")
+}
+
+// restartServices makes PHP-FPM drop the removed pools and the system
+// Caddy drop locwp's sites, or stops them when their packages are being
+// removed or nothing else needs them.
+func restartServices(mgr pkgmgr.Manager, inv *cleanup.Inventory, packages []pkgmgr.Installed, keepCaddy bool) {
+	for _, v := range inv.PHPVersions {
+		if !fileExists(mgr.PHPBin(v)) {
+			continue
+		}
+		action := "restart"
+		if slices.ContainsFunc(packages, func(p pkgmgr.Installed) bool { return p.Name == "php "+v }) {
+			action = "stop"
+		}
+		_ = exec.Run("sh", "-c", mgr.ServiceCmd(action, mgr.PHPService(v))+" 2>/dev/null")
+	}
+	for _, a := range inv.Artifacts {
+		if a.Kind != cleanup.KindCaddyfile {
+			continue
+		}
+		action := "stop"
+		if keepCaddy {
+			action = "restart"
+		}
+		_ = exec.Run("sh", "-c", mgr.ServiceCmd(action, mgr.CaddyService())+" 2>/dev/null")
+	}
+}
+
+// interactive reports whether stdin is a terminal to ask questions on.
+func interactive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// /dev/null is a character device too.
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// confirm asks a yes/no question on the terminal; def is the answer for
+// an empty reply.
+func confirm(question string, def bool) bool {
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", question, hint)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func init() {
+	uninstallCmd.Flags().BoolVarP(&flagUninstallYes, "yes", "y", false, "Don't ask for confirmation (and don't offer a backup)")
+	uninstallCmd.Flags().BoolVar(&flagUninstallDryRun, "dry-run", false, "Only list what would be removed")
+	uninstallCmd.Flags().BoolVar(&flagUninstallKeepPackages, "keep-packages", false, "Keep the PHP, Caddy and WP-CLI packages")
+	uninstallCmd.Flags().StringVar(&flagUninstallBackup, "backup", "", "Write all sites to this .tar.gz before removing them")
+	rootCmd.AddCommand(uninstallCmd)
+}
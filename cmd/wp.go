@@ -1,42 +1,236 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
-	"strconv"
+	"io"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/output"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+	"github.com/yansircc/locwp/internal/tools"
 )
 
+var wpSelector siteSelector
+
 var wpCmd = &cobra.Command{
-	Use:                "wp <port> -- <wp-cli args...>",
-	Short:              "Run WP-CLI commands for a site",
+	Use:   "wp <port>... | --all | --tag <tag> -- <wp-cli args...>",
+	Short: "Run WP-CLI commands for a site",
+	Long: `Run WP-CLI commands for a site.
+
+With several ports, --all or --tag the command runs on every site at once
+(--parallel at a time), each output line prefixed with the site's port.
+The command fails if any site fails, listing those sites; --json prints
+every site's exit code and output instead.`,
+	Example: `  locwp wp 10001 -- plugin list
+  locwp wp --all -- core version
+  locwp wp --tag client-a --json -- plugin update --all`,
 	Args:               cobra.MinimumNArgs(1),
 	DisableFlagParsing: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid port %q: %w", args[0], err)
+		// Flags are only parsed before "--"; everything after goes to WP-CLI.
+		pre, wpArgs := args, []string(nil)
+		for i, a := range args {
+			if a == "--" {
+				pre, wpArgs = args[:i], args[i+1:]
+				break
+			}
 		}
-		sc, err := site.LoadByPort(port)
-		if err != nil {
+		// cmd.ParseFlags is a no-op with DisableFlagParsing, so parse by
+		// hand, with the root's persistent flags.
+		cmd.Flags().AddFlagSet(cmd.InheritedFlags())
+		if err := cmd.Flags().Parse(pre); err != nil {
+			return output.WithCode(output.CodeInvalidArgument, err)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help {
+			return cmd.Help()
+		}
+		// The root's setup ran before flag parsing; redo it for --json,
+		// --output and --profile given before "--".
+		if err := rootCmd.PersistentPreRunE(cmd, nil); err != nil {
 			return err
 		}
 
-		// Find "--" separator
-		wpArgs := []string{"--path=" + sc.WPRoot}
-		for i, a := range args[1:] {
-			if a == "--" {
-				wpArgs = append(wpArgs, args[i+2:]...)
-				break
+		ports := cmd.Flags().Args()
+		sites, err := wpSelector.sites(cmd, ports)
+		if err != nil {
+			return err
+		}
+		for _, sc := range sites {
+			// Sites created before wp-cli.yml was generated.
+			if !fileExists(template.WPCLIConfigPath(sc)) {
+				_ = template.WriteWPCLIConfig(sc)
 			}
 		}
+		syncWPCLIAliases()
 
-		return exec.Run("wp", wpArgs...)
+		if !wpSelector.bulk(ports) {
+			// WP-CLI reports its own failures; exit with its status.
+			return passExit(cmd, runWP(sites[0], wpArgs...))
+		}
+		return runWPBulk(sites, wpArgs)
 	},
 }
 
+// wpSiteResult is one site's part of a bulk `wp` run.
+type wpSiteResult struct {
+	Port     int    `json:"port"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+}
+
+// wpBulkResult is the structured result of `wp` on several sites.
+type wpBulkResult struct {
+	Results []wpSiteResult `json:"results"`
+	Failed  []int          `json:"failed,omitempty"`
+}
+
+// runWPBulk runs a WP-CLI command on every site concurrently. Output is
+// streamed with a port prefix, or collected per site for --json.
+func runWPBulk(sites []*site.Config, args []string) error {
+	res := wpBulkResult{Results: make([]wpSiteResult, len(sites))}
+	var mu sync.Mutex
+	forEachSite(sites, wpSelector.parallel, func(i int, sc *site.Config) {
+		var buf bytes.Buffer
+		var w io.Writer = &buf
+		var pw *prefixWriter
+		if !outputFormat.Structured() {
+			pw = &prefixWriter{mu: &mu, w: os.Stdout, prefix: fmt.Sprintf("[%d] ", sc.Port)}
+			w = pw
+		}
+		name, argv, err := wpCommand(sc, args...)
+		if err == nil {
+			err = exec.RunTo(w, name, argv...)
+		}
+		if pw != nil {
+			pw.Flush()
+			if err != nil {
+				pw.writeLine([]byte("[!!] " + err.Error()))
+			}
+		}
+		r := wpSiteResult{Port: sc.Port, ExitCode: exec.ExitCode(err), Output: buf.String()}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		res.Results[i] = r
+	})
+
+	var failed []string
+	for _, r := range res.Results {
+		if r.ExitCode != 0 {
+			res.Failed = append(res.Failed, r.Port)
+			failed = append(failed, fmt.Sprintf("%d", r.Port))
+		}
+	}
+	if len(failed) > 0 {
+		err := fmt.Errorf("wp failed on %d of %d site(s): %s", len(failed), len(sites), strings.Join(failed, ", "))
+		if outputFormat.Structured() {
+			return emitFailure(res, err)
+		}
+		return err
+	}
+	return emit(res)
+}
+
+// prefixWriter writes each complete line with a prefix, holding mu so
+// that lines from sites running at once don't interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.buf[:i])
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes a last line that had no newline.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(p.buf)
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, line)
+}
+
+// wpCommand returns the command line that runs WP-CLI for a site: the
+// site's own PHP version running the WP-CLI phar, pointed at the site's
+// WordPress root and URL.
+func wpCommand(sc *site.Config, args ...string) (string, []string, error) {
+	php, err := sitePHP(sc)
+	if err != nil {
+		return "", nil, err
+	}
+	wp, err := exec.LookPath(tools.WPBin())
+	if err != nil {
+		return "", nil, fmt.Errorf("WP-CLI not found; run `locwp setup`: %w", err)
+	}
+	return php, append([]string{"-d", "memory_limit=" + template.WPMemoryLimit, wp, "--path=" + sc.WPRoot, "--url=" + sc.URL()}, args...), nil
+}
+
+// runWP runs a WP-CLI command against a site.
+func runWP(sc *site.Config, args ...string) error {
+	name, argv, err := wpCommand(sc, args...)
+	if err != nil {
+		return err
+	}
+	return exec.Run(name, argv...)
+}
+
+// wpOutput runs a WP-CLI command against a site and returns its trimmed stdout.
+func wpOutput(sc *site.Config, args ...string) (string, error) {
+	name, argv, err := wpCommand(sc, args...)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Output(name, argv...)
+	return strings.TrimSpace(out), err
+}
+
+// wpInput runs a WP-CLI command against a site with input on stdin, for
+// secrets passed via --prompt rather than the command line.
+func wpInput(sc *site.Config, input string, args ...string) error {
+	name, argv, err := wpCommand(sc, args...)
+	if err != nil {
+		return err
+	}
+	_, err = exec.OutputWithInput(input+"\n", name, argv...)
+	return err
+}
+
+// syncWPCLIAliases rewrites the active profile's aliases in the global
+// WP-CLI config. Failing to is only worth a warning.
+func syncWPCLIAliases() {
+	sites, err := site.LoadAll()
+	if err == nil {
+		err = template.WriteWPCLIAliases(sites)
+	}
+	if err != nil {
+		fmt.Printf("  [!!] WP-CLI aliases in %s: %v\n", template.WPCLIGlobalConfigPath(), err)
+	}
+}
+
 func init() {
+	wpSelector.register(wpCmd)
 	rootCmd.AddCommand(wpCmd)
 }
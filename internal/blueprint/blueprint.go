@@ -0,0 +1,334 @@
+// Package blueprint reads site blueprints, a subset of the WordPress
+// Playground blueprint format applied as extra provision steps.
+package blueprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yansircc/locwp/internal/config"
+)
+
+// Blueprint is a parsed blueprint file.
+type Blueprint struct {
+	PreferredVersions struct {
+		PHP string `json:"php"`
+		WP  string `json:"wp"`
+	} `json:"preferredVersions"`
+	Plugins     []json.RawMessage `json:"plugins"`
+	SiteOptions map[string]any    `json:"siteOptions"`
+	Constants   map[string]any    `json:"constants"`
+	Steps       []Step            `json:"steps"`
+}
+
+// Step is a single Playground blueprint step. Only the fields of the
+// supported step types are decoded.
+type Step struct {
+	Step            string          `json:"step"`
+	PluginData      *Resource       `json:"pluginData"`
+	PluginZipFile   *Resource       `json:"pluginZipFile"`
+	ThemeData       *Resource       `json:"themeData"`
+	ThemeZipFile    *Resource       `json:"themeZipFile"`
+	Options         json.RawMessage `json:"options"`
+	PluginPath      string          `json:"pluginPath"`
+	PluginName      string          `json:"pluginName"`
+	ThemeFolderName string          `json:"themeFolderName"`
+	Consts          map[string]any  `json:"consts"`
+	Command         json.RawMessage `json:"command"`
+	Code            string          `json:"code"`
+}
+
+// Resource points at a plugin or theme package.
+type Resource struct {
+	Resource string `json:"resource"`
+	Slug     string `json:"slug"`
+	URL      string `json:"url"`
+}
+
+// Command is one provisioning action derived from a blueprint: either
+// WP-CLI arguments (already shell-quoted) or PHP code for wp eval-file.
+type Command struct {
+	Name string
+	WP   string
+	PHP  string
+}
+
+// Dir returns the directory holding named blueprints.
+func Dir() string {
+	return filepath.Join(config.BaseDir(), "blueprints")
+}
+
+// Fetch resolves a blueprint reference and returns its contents. ref may
+// be an http(s) URL, a file path, or the name of a blueprint in Dir().
+func Fetch(ref string) ([]byte, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(ref)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch blueprint %s: %s", ref, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	if data, err := os.ReadFile(ref); err == nil {
+		return data, nil
+	}
+	path := filepath.Join(Dir(), strings.TrimSuffix(ref, ".json")+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("blueprint %q not found as a file or in %s", ref, Dir())
+	}
+	return data, nil
+}
+
+// Parse decodes a blueprint and checks that every step is supported.
+func Parse(data []byte) (*Blueprint, error) {
+	var bp Blueprint
+	if err := json.Unmarshal(data, &bp); err != nil {
+		return nil, fmt.Errorf("parse blueprint: %w", err)
+	}
+	if _, err := bp.Commands(); err != nil {
+		return nil, err
+	}
+	return &bp, nil
+}
+
+// Load reads and parses a blueprint file.
+func Load(path string) (*Blueprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Commands converts the blueprint into provisioning commands, in order:
+// top-level plugins, constants and site options, then explicit steps.
+func (bp *Blueprint) Commands() ([]Command, error) {
+	var cmds []Command
+	add := func(name string, c ...Command) {
+		for _, cmd := range c {
+			cmd.Name = fmt.Sprintf("blueprint-%d-%s", len(cmds)+1, name)
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	for _, raw := range bp.Plugins {
+		res, err := pluginShorthand(raw)
+		if err != nil {
+			return nil, err
+		}
+		c, err := install("plugin", res, true)
+		if err != nil {
+			return nil, err
+		}
+		add("installPlugin", c)
+	}
+	if len(bp.Constants) > 0 {
+		add("defineWpConfigConsts", defineConsts(bp.Constants)...)
+	}
+	if len(bp.SiteOptions) > 0 {
+		add("setSiteOptions", setOptions(bp.SiteOptions)...)
+	}
+
+	for i, s := range bp.Steps {
+		c, err := s.commands()
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, s.Step, err)
+		}
+		add(s.Step, c...)
+	}
+	return cmds, nil
+}
+
+func (s Step) commands() ([]Command, error) {
+	switch s.Step {
+	case "installPlugin":
+		res := s.PluginData
+		if res == nil {
+			res = s.PluginZipFile
+		}
+		c, err := install("plugin", res, s.activate())
+		return []Command{c}, err
+	case "installTheme":
+		res := s.ThemeData
+		if res == nil {
+			res = s.ThemeZipFile
+		}
+		c, err := install("theme", res, s.activate())
+		return []Command{c}, err
+	case "activatePlugin":
+		slug := pluginSlug(s.PluginPath)
+		if slug == "" {
+			return nil, fmt.Errorf("missing pluginPath")
+		}
+		return []Command{{WP: "plugin activate " + shellQuote(slug)}}, nil
+	case "activateTheme":
+		if s.ThemeFolderName == "" {
+			return nil, fmt.Errorf("missing themeFolderName")
+		}
+		return []Command{{WP: "theme activate " + shellQuote(s.ThemeFolderName)}}, nil
+	case "setSiteOptions":
+		var opts map[string]any
+		if err := json.Unmarshal(s.Options, &opts); err != nil {
+			return nil, fmt.Errorf("options: %w", err)
+		}
+		return setOptions(opts), nil
+	case "defineWpConfigConsts":
+		return defineConsts(s.Consts), nil
+	case "wp-cli":
+		args, err := wpCLIArgs(s.Command)
+		if err != nil {
+			return nil, err
+		}
+		return []Command{{WP: args}}, nil
+	case "runPHP":
+		if s.Code == "" {
+			return nil, fmt.Errorf("missing code")
+		}
+		return []Command{{PHP: s.Code}}, nil
+	case "login":
+		// Playground-only: locwp sites have their own admin login.
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported step type")
+}
+
+// activate reports the installPlugin/installTheme activate option, which
+// defaults to true as in Playground.
+func (s Step) activate() bool {
+	var opts struct {
+		Activate *bool `json:"activate"`
+	}
+	if len(s.Options) > 0 && json.Unmarshal(s.Options, &opts) == nil && opts.Activate != nil {
+		return *opts.Activate
+	}
+	return true
+}
+
+func install(kind string, res *Resource, activate bool) (Command, error) {
+	if res == nil {
+		return Command{}, fmt.Errorf("missing %s resource", kind)
+	}
+	var src string
+	switch res.Resource {
+	case "wordpress.org/plugins", "wordpress.org/themes":
+		src = res.Slug
+	case "url":
+		src = res.URL
+	default:
+		return Command{}, fmt.Errorf("unsupported resource type %q", res.Resource)
+	}
+	if src == "" {
+		return Command{}, fmt.Errorf("empty %s resource", kind)
+	}
+	cmd := kind + " install " + shellQuote(src)
+	if activate {
+		cmd += " --activate"
+	}
+	return Command{WP: cmd}, nil
+}
+
+// pluginShorthand decodes an entry of the top-level "plugins" list: a
+// wordpress.org slug or a full resource object.
+func pluginShorthand(raw json.RawMessage) (*Resource, error) {
+	var slug string
+	if json.Unmarshal(raw, &slug) == nil {
+		return &Resource{Resource: "wordpress.org/plugins", Slug: slug}, nil
+	}
+	var res Resource
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("plugins: %w", err)
+	}
+	return &res, nil
+}
+
+// pluginSlug turns a Playground plugin path such as
+// "/wordpress/wp-content/plugins/hello-dolly/hello.php" into a WP-CLI slug.
+func pluginSlug(path string) string {
+	path = strings.TrimPrefix(path, "/wordpress/wp-content/plugins/")
+	path = strings.Trim(path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimSuffix(path, ".php")
+}
+
+func defineConsts(consts map[string]any) []Command {
+	var cmds []Command
+	for _, k := range sortedKeys(consts) {
+		v := consts[k]
+		if s, ok := v.(string); ok {
+			cmds = append(cmds, Command{WP: "config set " + shellQuote(k) + " " + shellQuote(s) + " --type=constant"})
+			continue
+		}
+		raw, _ := json.Marshal(v)
+		cmds = append(cmds, Command{WP: "config set " + shellQuote(k) + " " + shellQuote(string(raw)) + " --type=constant --raw"})
+	}
+	return cmds
+}
+
+func setOptions(opts map[string]any) []Command {
+	var cmds []Command
+	for _, k := range sortedKeys(opts) {
+		v := opts[k]
+		if s, ok := v.(string); ok {
+			cmds = append(cmds, Command{WP: "option update " + shellQuote(k) + " " + shellQuote(s)})
+			continue
+		}
+		raw, _ := json.Marshal(v)
+		cmds = append(cmds, Command{WP: "option update " + shellQuote(k) + " " + shellQuote(string(raw)) + " --format=json"})
+	}
+	return cmds
+}
+
+// wpCLIArgs accepts a wp-cli step command as a string ("wp plugin list")
+// or an argument array, and returns the arguments after "wp".
+func wpCLIArgs(raw json.RawMessage) (string, error) {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			return "", fmt.Errorf("empty command")
+		}
+		return strings.TrimSpace(strings.TrimPrefix(str, "wp ")), nil
+	}
+	var args []string
+	if err := json.Unmarshal(raw, &args); err != nil || len(args) == 0 {
+		return "", fmt.Errorf("command must be a string or array of strings")
+	}
+	if args[0] == "wp" {
+		args = args[1:]
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package blueprint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testBlueprint = `{
+	"$schema": "https://playground.wordpress.net/blueprint-schema.json",
+	"preferredVersions": {"php": "8.2", "wp": "6.5"},
+	"plugins": ["query-monitor", {"resource": "url", "url": "https://example.com/p.zip"}],
+	"constants": {"WP_DEBUG": true, "WP_ENVIRONMENT_TYPE": "local"},
+	"siteOptions": {"blogname": "Acme's Site"},
+	"steps": [
+		{"step": "login", "username": "admin"},
+		{"step": "installTheme", "themeData": {"resource": "wordpress.org/themes", "slug": "astra"}, "options": {"activate": false}},
+		{"step": "activatePlugin", "pluginPath": "/wordpress/wp-content/plugins/hello-dolly/hello.php"},
+		{"step": "setSiteOptions", "options": {"posts_per_page": 20}},
+		{"step": "wp-cli", "command": "wp rewrite structure '/%postname%/'"},
+		{"step": "wp-cli", "command": ["wp", "post", "create", "--post_title=Hello World"]},
+		{"step": "runPHP", "code": "<?php require_once '/wordpress/wp-load.php';"}
+	]
+}`
+
+func TestCommands(t *testing.T) {
+	bp, err := Parse([]byte(testBlueprint))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if bp.PreferredVersions.PHP != "8.2" || bp.PreferredVersions.WP != "6.5" {
+		t.Errorf("PreferredVersions = %+v", bp.PreferredVersions)
+	}
+
+	cmds, err := bp.Commands()
+	if err != nil {
+		t.Fatalf("Commands() error: %v", err)
+	}
+	want := []string{
+		"plugin install query-monitor --activate",
+		"plugin install https://example.com/p.zip --activate",
+		"config set WP_DEBUG true --type=constant --raw",
+		"config set WP_ENVIRONMENT_TYPE local --type=constant",
+		`option update blogname 'Acme'\''s Site'`,
+		"theme install astra",
+		"plugin activate hello-dolly",
+		"option update posts_per_page 20 --format=json",
+		"rewrite structure '/%postname%/'",
+		"post create '--post_title=Hello World'",
+		"",
+	}
+	if len(cmds) != len(want) {
+		t.Fatalf("Commands() returned %d commands, want %d: %+v", len(cmds), len(want), cmds)
+	}
+	for i, w := range want {
+		if cmds[i].WP != w {
+			t.Errorf("cmds[%d].WP = %q, want %q", i, cmds[i].WP, w)
+		}
+	}
+	last := cmds[len(cmds)-1]
+	if !strings.Contains(last.PHP, "wp-load.php") || last.Name != "blueprint-11-runPHP" {
+		t.Errorf("runPHP command = %+v", last)
+	}
+}
+
+func TestParse_UnsupportedStep(t *testing.T) {
+	_, err := Parse([]byte(`{"steps": [{"step": "enableMultisite"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "enableMultisite") {
+		t.Errorf("Parse() error = %v, want unsupported step error", err)
+	}
+}
+
+func TestFetch_Named(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	os.MkdirAll(Dir(), 0755)
+	os.WriteFile(filepath.Join(Dir(), "agency.json"), []byte(`{"plugins": ["akismet"]}`), 0644)
+
+	for _, ref := range []string{"agency", "agency.json", filepath.Join(Dir(), "agency.json")} {
+		data, err := Fetch(ref)
+		if err != nil {
+			t.Errorf("Fetch(%q) error: %v", ref, err)
+			continue
+		}
+		if !strings.Contains(string(data), "akismet") {
+			t.Errorf("Fetch(%q) = %s", ref, data)
+		}
+	}
+	if _, err := Fetch("missing"); err == nil {
+		t.Error("Fetch() of unknown blueprint should error")
+	}
+}
@@ -0,0 +1,21 @@
+// Package bytesize formats byte counts for humans.
+package bytesize
+
+import "fmt"
+
+// units are the binary prefixes, up to the largest an int64 can reach.
+const units = "KMGTPE"
+
+// Format formats a byte count with a binary unit: 512B, 2.0KB, 1.5GB.
+func Format(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < len(units)-1; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), units[exp])
+}
@@ -0,0 +1,21 @@
+package bytesize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:           "512B",
+		2048:          "2.0KB",
+		3 << 20:       "3.0MB",
+		3 << 29:       "1.5GB",
+		1 << 50:       "1.0PB",
+		math.MaxInt64: "8.0EB",
+	} {
+		if got := Format(n); got != want {
+			t.Errorf("Format(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package cleanup inventories what locwp has created on this machine —
+// sites of every profile and the files it put outside LOCWP_HOME — so
+// that it can all be removed again, and finds files left behind by sites
+// that no longer exist.
+package cleanup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/template"
+)
+
+// Artifact kinds.
+const (
+	KindSite       = "site"
+	KindFPMPool    = "fpm-pool"
+	KindPHPConf    = "php-conf"
+	KindSocket     = "socket"
+	KindRuntimeDir = "runtime-dir"
+	KindCaddyfile  = "caddyfile"
+	KindWPCLIAlias = "wp-cli-aliases"
+	KindHome       = "home"
+)
+
+// Artifact is one thing locwp created.
+type Artifact struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Profile string `json:"profile,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	// PHP is the version whose PHP-FPM reads a pool file.
+	PHP string `json:"php,omitempty"`
+}
+
+// Inventory lists everything locwp created, across all profiles.
+type Inventory struct {
+	Sites       []*site.Config `json:"-"`
+	Artifacts   []Artifact     `json:"artifacts"`
+	PHPVersions []string       `json:"php_versions"`
+}
+
+// Collect builds the inventory for the default profile's package manager.
+// It must run with no profile active, as uninstall does.
+func Collect(mgr pkgmgr.Manager) *Inventory {
+	inv := &Inventory{}
+	seen := map[string]bool{}
+	add := func(a Artifact) {
+		if !seen[a.Path] {
+			seen[a.Path] = true
+			inv.Artifacts = append(inv.Artifacts, a)
+		}
+	}
+
+	versions := map[string]bool{config.DefaultPHP: true}
+	for _, p := range profileDirs() {
+		dirs, _ := filepath.Glob(filepath.Join(p.dir, "sites", "*", "config.json"))
+		for _, cfg := range dirs {
+			sc, err := site.Load(filepath.Dir(cfg))
+			if err != nil {
+				continue
+			}
+			inv.Sites = append(inv.Sites, sc)
+			versions[sc.PHP] = true
+			add(Artifact{Kind: KindSite, Path: sc.SiteDir, Profile: p.name, Port: sc.Port})
+		}
+		// Trashed sites go with LOCWP_HOME, but still hold secrets.
+		trashed, _ := filepath.Glob(filepath.Join(p.dir, "trash", "*", "config.json"))
+		for _, cfg := range trashed {
+			if sc, err := site.Load(filepath.Dir(cfg)); err == nil {
+				inv.Sites = append(inv.Sites, sc)
+			}
+		}
+	}
+	for v := range versions {
+		inv.PHPVersions = append(inv.PHPVersions, v)
+	}
+	sort.Strings(inv.PHPVersions)
+
+	for _, v := range inv.PHPVersions {
+		pools, _ := filepath.Glob(filepath.Join(mgr.FPMPoolDir(v), "locwp-*.conf"))
+		for _, f := range pools {
+			// Another LOCWP_HOME's pools are its own to remove.
+			if poolUnder(f, config.RootDir()) {
+				add(Artifact{Kind: KindFPMPool, Path: f, PHP: v})
+			}
+		}
+		for _, dir := range mgr.PHPConfDirs(v) {
+			ini := filepath.Join(dir, "locwp.ini")
+			if _, err := os.Stat(ini); err == nil {
+				add(Artifact{Kind: KindPHPConf, Path: ini})
+			}
+		}
+	}
+
+	for _, sc := range inv.Sites {
+		if sock := sc.SocketPath(); ownedByUser(sock) {
+			add(Artifact{Kind: KindSocket, Path: sock, Port: sc.Port})
+		}
+		if sc.Socket != "" {
+			if _, err := os.Stat(filepath.Dir(sc.Socket)); err == nil {
+				add(Artifact{Kind: KindRuntimeDir, Path: filepath.Dir(sc.Socket)})
+			}
+		}
+	}
+	for _, f := range legacySockets() {
+		add(Artifact{Kind: KindSocket, Path: f})
+	}
+
+	// The mode may have changed since setup, so look wherever a block
+	// could be.
+	for _, path := range template.SharedCaddyfiles() {
+		if template.HasCaddyImport(path) {
+			add(Artifact{Kind: KindCaddyfile, Path: path})
+		}
+	}
+
+	if template.HasWPCLIAliases() {
+		add(Artifact{Kind: KindWPCLIAlias, Path: template.WPCLIGlobalConfigPath()})
+	}
+
+	if _, err := os.Stat(config.RootDir()); err == nil {
+		add(Artifact{Kind: KindHome, Path: config.RootDir()})
+	}
+	return inv
+}
+
+// Remove deletes an artifact. Site directories go with LOCWP_HOME (an
+// adopted site's WordPress root lives elsewhere and is never touched),
+// and a shared Caddyfile or the global WP-CLI config gets locwp's blocks
+// taken out rather than being deleted.
+func Remove(a Artifact) error {
+	switch a.Kind {
+	case KindSite:
+		return nil
+	case KindCaddyfile:
+		return template.RestoreSharedCaddyfile(a.Path)
+	case KindWPCLIAlias:
+		return template.RemoveWPCLIAliases()
+	}
+	return os.RemoveAll(a.Path)
+}
+
+type profileDir struct {
+	name, dir string
+}
+
+// profileDirs returns the default profile and every named profile's
+// directory.
+func profileDirs() []profileDir {
+	out := []profileDir{{dir: config.RootDir()}}
+	dirs, _ := filepath.Glob(filepath.Join(config.RootDir(), "profiles", "*"))
+	for _, d := range dirs {
+		if fi, err := os.Stat(d); err == nil && fi.IsDir() {
+			out = append(out, profileDir{name: filepath.Base(d), dir: d})
+		}
+	}
+	return out
+}
+
+// Backup writes LOCWP_HOME — every profile's sites, databases, configs
+// and mail — to a gzipped tarball at dst. Sockets and the managed tools in
+// bin/ are left out; adopted WordPress roots live elsewhere and are kept
+// by uninstall anyway.
+func Backup(dst string) error {
+	root := config.RootDir()
+	if rel, err := filepath.Rel(root, dst); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("backup %s must be outside %s", dst, root)
+	}
+	// The archive holds secrets.json and every site's database, and must
+	// not replace an earlier backup.
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	bin := filepath.Join(root, "bin")
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == bin {
+			return filepath.SkipDir
+		}
+		if !fi.Mode().IsRegular() && !fi.IsDir() && fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(root), path)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("back up %s: %w", root, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,209 @@
+package cleanup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+func writeSite(t *testing.T, base string, sc *site.Config) {
+	t.Helper()
+	sc.SiteDir = filepath.Join(base, "sites", sc.PortStr())
+	if err := os.MkdirAll(sc.SiteDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Save(sc.SiteDir, sc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// poolConf returns the parts of a pool config that tie it to a site dir.
+func poolConf(siteDir string) []byte {
+	return []byte("[pool]\nlisten = /tmp/x.sock\nphp_admin_value[error_log] = " + siteDir + "/logs/php-error.log\n")
+}
+
+func TestCollect(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	t.Setenv("LOCWP_PKG", "manual")
+	t.Setenv("PATH", "")
+	mgr := pkgmgr.Manual{}
+
+	run := filepath.Join(home, "run")
+	os.MkdirAll(run, 0700)
+	os.WriteFile(filepath.Join(run, "10001.sock"), nil, 0600)
+	writeSite(t, home, &site.Config{Port: 10001, PHP: "8.2", Socket: filepath.Join(run, "10001.sock")})
+	writeSite(t, filepath.Join(home, "profiles", "work"), &site.Config{Port: 11001, PHP: "8.3"})
+
+	pools := mgr.FPMPoolDir("8.3")
+	os.MkdirAll(pools, 0755)
+	os.WriteFile(filepath.Join(pools, "locwp-10001.conf"), poolConf(filepath.Join(home, "sites", "10001")), 0644)
+	os.WriteFile(filepath.Join(pools, "locwp-work-11001.conf"), poolConf(filepath.Join(home, "profiles", "work", "sites", "11001")), 0644)
+	// Another LOCWP_HOME's pool in the shared pool.d.
+	os.WriteFile(filepath.Join(pools, "locwp-10002.conf"), poolConf("/home/other/.locwp/sites/10002"), 0644)
+	os.WriteFile(filepath.Join(pools, "www.conf"), nil, 0644)
+	conf := mgr.PHPConfDirs("8.3")[0]
+	os.MkdirAll(conf, 0755)
+	os.WriteFile(filepath.Join(conf, "locwp.ini"), nil, 0644)
+	wpcli := filepath.Join(t.TempDir(), "config.yml")
+	t.Setenv("WP_CLI_CONFIG_PATH", wpcli)
+	os.WriteFile(wpcli, []byte("color: false\n\n# BEGIN locwp aliases (managed by locwp, do not edit)\n@10001:\n  path: /x\n# END locwp aliases\n"), 0644)
+
+	inv := Collect(mgr)
+	if len(inv.Sites) != 2 {
+		t.Fatalf("Sites = %d, want 2", len(inv.Sites))
+	}
+	if !sort.StringsAreSorted(inv.PHPVersions) || len(inv.PHPVersions) != 2 {
+		t.Errorf("PHPVersions = %v", inv.PHPVersions)
+	}
+
+	kinds := map[string][]string{}
+	for _, a := range inv.Artifacts {
+		kinds[a.Kind] = append(kinds[a.Kind], a.Path)
+		if a.Kind == KindSite && a.Port == 11001 && a.Profile != "work" {
+			t.Errorf("site 11001 profile = %q", a.Profile)
+		}
+	}
+	if len(kinds[KindFPMPool]) != 2 {
+		t.Errorf("pools = %v, want both profiles' pools and not www.conf or another home's", kinds[KindFPMPool])
+	}
+	if len(kinds[KindPHPConf]) != 1 || len(kinds[KindSocket]) != 1 || len(kinds[KindRuntimeDir]) != 1 || len(kinds[KindWPCLIAlias]) != 1 {
+		t.Errorf("artifacts = %v", kinds)
+	}
+	if last := inv.Artifacts[len(inv.Artifacts)-1]; last.Kind != KindHome || last.Path != home {
+		t.Errorf("last artifact = %+v, want LOCWP_HOME", last)
+	}
+
+	for _, a := range inv.Artifacts {
+		if err := Remove(a); err != nil {
+			t.Fatalf("Remove(%+v) error: %v", a, err)
+		}
+	}
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Error("LOCWP_HOME not removed")
+	}
+	if data, _ := os.ReadFile(wpcli); string(data) != "color: false\n" {
+		t.Errorf("WP-CLI config after Remove = %q, want the user's settings only", data)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	writeSite(t, home, &site.Config{Port: 10001, PHP: "8.3"})
+	os.MkdirAll(filepath.Join(home, "bin"), 0755)
+	os.WriteFile(filepath.Join(home, "bin", "caddy"), []byte("big"), 0755)
+
+	if err := Backup(filepath.Join(home, "backup.tar.gz")); err == nil {
+		t.Error("Backup() inside LOCWP_HOME should fail")
+	}
+
+	dst := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := Backup(dst); err != nil {
+		t.Fatalf("Backup() error: %v", err)
+	}
+	if fi, _ := os.Stat(dst); fi.Mode().Perm() != 0600 {
+		t.Errorf("backup mode = %v, want 0600", fi.Mode().Perm())
+	}
+	if err := Backup(dst); err == nil {
+		t.Error("Backup() should not overwrite an existing file")
+	}
+	f, _ := os.Open(dst)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[hdr.Name] = true
+	}
+	base := filepath.Base(home)
+	if !names[base+"/sites/10001/config.json"] {
+		t.Errorf("backup is missing the site config: %v", names)
+	}
+	if names[base+"/bin/caddy"] {
+		t.Error("backup should skip bin/")
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	mgr := pkgmgr.Manual{}
+
+	writeSite(t, home, &site.Config{Port: 10001, PHP: "8.2"})
+	// A site with a corrupt config still owns its files.
+	os.MkdirAll(filepath.Join(home, "sites", "10003"), 0755)
+	os.WriteFile(filepath.Join(home, "sites", "10003", "config.json"), []byte(`{"php": "8.1", oops`), 0644)
+
+	files := map[string]bool{
+		filepath.Join(home, "caddy", "sites", "10001.caddy"):          false,
+		filepath.Join(home, "caddy", "sites", "10002.caddy.disabled"): true,
+		filepath.Join(home, "caddy", "sites", "10003.caddy"):          false,
+		filepath.Join(home, "php", "10002.conf"):                      true,
+		filepath.Join(mgr.FPMPoolDir("8.2"), "locwp-10001.conf"):      false,
+		filepath.Join(mgr.FPMPoolDir("8.2"), "locwp-10002.conf"):      true,
+		filepath.Join(mgr.FPMPoolDir("8.2"), "locwp-work-10002.conf"): false,
+		filepath.Join(home, "run", "10001.sock"):                      false,
+		filepath.Join(home, "run", "10002.sock"):                      true,
+		filepath.Join(mgr.FPMPoolDir("8.2"), "locwp-10004.conf"):      false,
+	}
+	for f := range files {
+		os.MkdirAll(filepath.Dir(f), 0755)
+		os.WriteFile(f, poolConf(filepath.Join(home, "sites", "10002")), 0644)
+	}
+	// A pool of another LOCWP_HOME sharing the system pool.d.
+	os.WriteFile(filepath.Join(mgr.FPMPoolDir("8.2"), "locwp-10004.conf"), poolConf("/home/other/.locwp/sites/10004"), 0644)
+
+	got := map[string]bool{}
+	for _, a := range Orphans(mgr) {
+		got[a.Path] = true
+		if a.Port != 10002 {
+			t.Errorf("orphan %s has port %d", a.Path, a.Port)
+		}
+	}
+	for f, orphan := range files {
+		if got[f] != orphan {
+			t.Errorf("%s: orphan = %v, want %v", f, got[f], orphan)
+		}
+	}
+}
+
+func TestPHPField(t *testing.T) {
+	if got := phpField([]byte(`{"port": "not a number", "note": "\"php\": \"7.4\"", "php": "8.1"}`)); got != "8.1" {
+		t.Errorf("phpField() = %q", got)
+	}
+	for _, data := range []string{`{}`, `{"port": 1, "php": "8.1", broken`} {
+		if got := phpField([]byte(data)); got != "" {
+			t.Errorf("phpField(%s) = %q", data, got)
+		}
+	}
+}
+
+func TestOwnedByUser(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "locwp-10001.sock")
+	os.WriteFile(f, nil, 0600)
+	if !ownedByUser(f) {
+		t.Error("ownedByUser() = false for own file")
+	}
+	if ownedByUser(f + ".missing") {
+		t.Error("ownedByUser() = true for missing file")
+	}
+}
@@ -0,0 +1,163 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+)
+
+// KindCaddyConf is a per-site Caddy config, enabled or .disabled.
+const KindCaddyConf = "caddy-conf"
+
+// knownPHP are the PHP versions whose pool directories are searched for
+// orphans even when no remaining site uses them.
+var knownPHP = []string{"8.1", "8.2", "8.3", "8.4"}
+
+// Orphans finds the active profile's Caddy confs, FPM pools and sockets
+// that belong to no site. A site exists as long as its directory under
+// sites/ does, even if its config.json can't be read, so a broken config
+// never gets its files collected.
+func Orphans(mgr pkgmgr.Manager) []Artifact {
+	ports := map[int]bool{}
+	versions := append([]string{config.DefaultPHP}, knownPHP...)
+	entries, _ := os.ReadDir(filepath.Join(config.BaseDir(), "sites"))
+	for _, e := range entries {
+		port, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		ports[port] = true
+		if data, err := os.ReadFile(filepath.Join(config.BaseDir(), "sites", e.Name(), "config.json")); err == nil {
+			if v := phpField(data); v != "" {
+				versions = append(versions, v)
+			}
+		}
+	}
+
+	var out []Artifact
+	seen := map[string]bool{}
+	add := func(kind, path, prefix, php string) {
+		port, ok := portOf(filepath.Base(path), prefix)
+		if !ok || ports[port] || seen[path] {
+			return
+		}
+		seen[path] = true
+		out = append(out, Artifact{Kind: kind, Path: path, Port: port, PHP: php})
+	}
+
+	confs, _ := filepath.Glob(filepath.Join(config.CaddySitesDir(), "*.caddy*"))
+	for _, f := range confs {
+		add(KindCaddyConf, f, "", "")
+	}
+	local, _ := filepath.Glob(filepath.Join(config.BaseDir(), "php", "*.conf"))
+	for _, f := range local {
+		add(KindFPMPool, f, "", "")
+	}
+	// Pools are named locwp-<port>, or locwp-<profile>-<port> in a named
+	// profile; only this profile's pools are considered, and of those only
+	// the ones of this LOCWP_HOME.
+	prefix := strings.TrimSuffix(config.PoolName(0), "0")
+	for _, v := range versions {
+		pools, _ := filepath.Glob(filepath.Join(mgr.FPMPoolDir(v), prefix+"*.conf"))
+		for _, f := range pools {
+			if poolUnder(f, config.BaseDir()) {
+				add(KindFPMPool, f, prefix, v)
+			}
+		}
+	}
+	socks, _ := filepath.Glob(filepath.Join(config.RuntimeDir(), "*.sock"))
+	for _, f := range socks {
+		add(KindSocket, f, "", "")
+	}
+	// Sockets in /tmp predate profiles and so belong to the default one.
+	if config.ProfileName() == "" {
+		for _, f := range legacySockets() {
+			add(KindSocket, f, "locwp-", "")
+		}
+	}
+	return out
+}
+
+// portOf extracts the site port from an artifact's file name, which is
+// prefix followed by the port and an extension: 10001.caddy.disabled,
+// locwp-work-10001.conf, locwp-10001.sock. Names of another profile's
+// pools don't parse and are skipped.
+func portOf(name, prefix string) (int, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	name = strings.SplitN(strings.TrimPrefix(name, prefix), ".", 2)[0]
+	port, err := strconv.Atoi(name)
+	return port, err == nil
+}
+
+// phpField reads the php version from a site config without requiring
+// the rest of it to match site.Config. A config that isn't JSON yields
+// "", and the known versions are searched instead.
+func phpField(data []byte) string {
+	var c struct {
+		PHP string `json:"php"`
+	}
+	if json.Unmarshal(data, &c) != nil {
+		return ""
+	}
+	return c.PHP
+}
+
+// poolUnder reports whether the pool config at path belongs to a site
+// under dir: its error log or socket is there. A system pool.d is shared
+// by every LOCWP_HOME on the machine.
+func poolUnder(path, dir string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "php_admin_value[error_log]", "listen":
+			if within(strings.TrimSpace(v), dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// within reports whether path is inside dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// legacySockets returns the current user's sockets in /tmp. /tmp is
+// shared, and other users' locwp sockets are theirs to clean up.
+func legacySockets() []string {
+	files, _ := filepath.Glob("/tmp/locwp-*.sock")
+	var out []string
+	for _, f := range files {
+		if ownedByUser(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// ownedByUser reports whether path exists and belongs to the current user.
+func ownedByUser(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
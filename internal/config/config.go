@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
 const dirName = ".locwp"
@@ -14,14 +17,25 @@ const DefaultPHP = "8.3"
 // StartPort is the first port allocated to sites.
 const StartPort = 10001
 
-// BaseDir returns the locwp data directory, creating it if needed.
-// Honors LOCWP_HOME env var, defaults to ~/.locwp.
-func BaseDir() string {
+// RootDir returns the top-level locwp directory that holds the default
+// profile and all named profiles. Honors LOCWP_HOME, defaults to ~/.locwp.
+func RootDir() string {
 	dir := os.Getenv("LOCWP_HOME")
 	if dir == "" {
 		home, _ := os.UserHomeDir()
 		dir = filepath.Join(home, dirName)
 	}
+	return dir
+}
+
+// BaseDir returns the data directory of the active profile, creating it
+// if needed: RootDir for the default profile, RootDir/profiles/<name>
+// for a named one.
+func BaseDir() string {
+	dir := RootDir()
+	if name := ProfileName(); name != "" {
+		dir = filepath.Join(dir, "profiles", name)
+	}
 	os.MkdirAll(dir, 0755)
 	return dir
 }
@@ -31,26 +45,70 @@ func CaddySitesDir() string {
 	return filepath.Join(BaseDir(), "caddy", "sites")
 }
 
-// NextPort scans all site configs and returns the next available port.
+// RuntimeDir returns the private directory for PHP-FPM sockets,
+// BaseDir/run. $XDG_RUNTIME_DIR isn't used: it doesn't exist at boot or
+// after logout, and a system php-fpm with a pool whose socket directory
+// is missing fails to start at all.
+func RuntimeDir() string {
+	return filepath.Join(BaseDir(), "run")
+}
+
+// MaxSocketPath is the longest Unix socket path macOS accepts: sun_path
+// holds 104 bytes including the terminating NUL.
+const MaxSocketPath = 103
+
+// SocketPath returns the PHP-FPM socket for a new site, or an error if
+// LOCWP_HOME is too deep for it to fit in a Unix socket address.
+func SocketPath(port int) (string, error) {
+	path := filepath.Join(RuntimeDir(), strconv.Itoa(port)+".sock")
+	if len(path) > MaxSocketPath {
+		return "", fmt.Errorf("socket path %s is longer than %d bytes; use a shorter LOCWP_HOME", path, MaxSocketPath)
+	}
+	return path, nil
+}
+
+// EnsureRuntimeDir creates dir, a runtime directory, readable only by the
+// current user.
+func EnsureRuntimeDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.Chmod(dir, 0700)
+}
+
+// TrashRetention is how long a deleted site stays in the trash with its
+// port reserved.
+const TrashRetention = 7 * 24 * time.Hour
+
+// TrashDir returns where deleted sites are kept.
+func TrashDir() string {
+	return filepath.Join(BaseDir(), "trash")
+}
+
+// NextPort scans all site configs, and the ports still reserved by sites
+// in the trash, and returns the next available port.
 func NextPort(baseDir string) int {
 	sitesDir := filepath.Join(baseDir, "sites")
-	entries, err := os.ReadDir(sitesDir)
-	if err != nil {
-		return StartPort
+	maxPort := profileStartPort(baseDir) - 1
+	var cfg struct {
+		Port      int       `json:"port"`
+		DeletedAt time.Time `json:"deleted_at"`
 	}
-	maxPort := StartPort - 1
-	for _, e := range entries {
-		if !e.IsDir() {
+	files, _ := filepath.Glob(filepath.Join(sitesDir, "*", "config.json"))
+	trashed, _ := filepath.Glob(filepath.Join(baseDir, "trash", "*", "trash.json"))
+	for _, f := range append(files, trashed...) {
+		data, err := os.ReadFile(f)
+		if err != nil {
 			continue
 		}
-		data, err := os.ReadFile(filepath.Join(sitesDir, e.Name(), "config.json"))
-		if err != nil {
+		cfg.Port, cfg.DeletedAt = 0, time.Time{}
+		if json.Unmarshal(data, &cfg) != nil {
 			continue
 		}
-		var cfg struct {
-			Port int `json:"port"`
+		if !cfg.DeletedAt.IsZero() && time.Since(cfg.DeletedAt) > TrashRetention {
+			continue
 		}
-		if json.Unmarshal(data, &cfg) == nil && cfg.Port > maxPort {
+		if cfg.Port > maxPort {
 			maxPort = cfg.Port
 		}
 	}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestBaseDir_Default(t *testing.T) {
@@ -69,6 +71,23 @@ func TestNextPort_MultipleSites(t *testing.T) {
 	}
 }
 
+func TestNextPort_ReservedByTrash(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name string, port int, deletedAt time.Time) {
+		dir := filepath.Join(tmp, "trash", name)
+		os.MkdirAll(dir, 0755)
+		data, _ := json.Marshal(map[string]interface{}{"port": port, "deleted_at": deletedAt})
+		os.WriteFile(filepath.Join(dir, "trash.json"), data, 0644)
+	}
+	write("10004-recent", 10004, time.Now().Add(-time.Hour))
+	write("10009-expired", 10009, time.Now().Add(-TrashRetention-time.Hour))
+
+	port := NextPort(tmp)
+	if port != 10005 {
+		t.Errorf("NextPort() = %d, want 10005 (10004 reserved, 10009 expired)", port)
+	}
+}
+
 func TestCaddySitesDir(t *testing.T) {
 	tmp := t.TempDir()
 	t.Setenv("LOCWP_HOME", tmp)
@@ -78,3 +97,85 @@ func TestCaddySitesDir(t *testing.T) {
 		t.Errorf("CaddySitesDir() = %q, want %q", dir, want)
 	}
 }
+
+func TestRuntimeDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	// Gone at boot and after logout, so not used even when set.
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if got, want := RuntimeDir(), filepath.Join(home, "run"); got != want {
+		t.Errorf("RuntimeDir() = %q, want %q", got, want)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	got, err := SocketPath(10001)
+	if err != nil || got != filepath.Join(home, "run", "10001.sock") {
+		t.Errorf("SocketPath() = %q, %v", got, err)
+	}
+
+	t.Setenv("LOCWP_HOME", filepath.Join(home, strings.Repeat("d", MaxSocketPath)))
+	if _, err := SocketPath(10001); err == nil {
+		t.Error("SocketPath() should reject a path longer than a socket address")
+	}
+}
+
+func TestEnsureRuntimeDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	os.MkdirAll(dir, 0755)
+	if err := EnsureRuntimeDir(dir); err != nil {
+		t.Fatalf("EnsureRuntimeDir() error: %v", err)
+	}
+	fi, _ := os.Stat(dir)
+	if fi.Mode().Perm() != 0700 {
+		t.Errorf("mode = %v, want 0700", fi.Mode().Perm())
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("LOCWP_HOME", root)
+	t.Setenv(ProfileEnv, "")
+
+	def, err := CurrentProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.StartPort != StartPort || BaseDir() != root || PoolName(10001) != "locwp-10001" {
+		t.Errorf("default profile = %+v, BaseDir %q, pool %q", def, BaseDir(), PoolName(10001))
+	}
+
+	t.Setenv(ProfileEnv, "work")
+	work, err := CurrentProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if BaseDir() != filepath.Join(root, "profiles", "work") {
+		t.Errorf("BaseDir() = %q", BaseDir())
+	}
+	if work.Slot != 1 || work.StartPort != 11001 || work.SMTPAddr == def.SMTPAddr {
+		t.Errorf("work profile = %+v", work)
+	}
+	if got := NextPort(BaseDir()); got != 11001 {
+		t.Errorf("NextPort() = %d, want 11001", got)
+	}
+	if got := PoolName(11001); got != "locwp-work-11001" {
+		t.Errorf("PoolName() = %q", got)
+	}
+
+	// The slot is stable once allocated, and a second profile gets the next.
+	if again, _ := CurrentProfile(); again != work {
+		t.Errorf("CurrentProfile() changed: %+v -> %+v", work, again)
+	}
+	t.Setenv(ProfileEnv, "ci")
+	if ci, _ := CurrentProfile(); ci.Slot != 2 || ci.StartPort != 12001 {
+		t.Errorf("ci profile = %+v, want slot 2", ci)
+	}
+
+	t.Setenv(ProfileEnv, "../etc")
+	if _, err := CurrentProfile(); err == nil {
+		t.Error("CurrentProfile() should reject an invalid name")
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// ProfileEnv names the active profile. The --profile flag sets it so that
+// pawl steps and other locwp processes started by locwp inherit it.
+const ProfileEnv = "LOCWP_PROFILE"
+
+// Addresses used by the default profile.
+const (
+	defaultSMTPAddr = "127.0.0.1:1025"
+	defaultWebAddr  = "127.0.0.1:8025"
+	// profilePortStride separates the port ranges of profiles.
+	profilePortStride = 1000
+)
+
+// Profile is an isolated locwp environment with its own sites, Caddy
+// instance, port range and mail sink. The default profile has no name and
+// uses the Homebrew Caddy service.
+type Profile struct {
+	Name string `json:"name"`
+	// Slot numbers named profiles from 1 and offsets all their ports.
+	Slot       int    `json:"slot"`
+	StartPort  int    `json:"start_port"`
+	CaddyAdmin string `json:"caddy_admin,omitempty"`
+	SMTPAddr   string `json:"smtp_addr"`
+	WebAddr    string `json:"web_addr"`
+}
+
+var profileNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateProfileName checks that name is usable as a profile name.
+func ValidateProfileName(name string) error {
+	if name == "default" || !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// ProfileName returns the active profile's name, or "" for the default.
+func ProfileName() string {
+	return os.Getenv(ProfileEnv)
+}
+
+// DefaultProfile returns the unnamed profile that lives directly in RootDir.
+func DefaultProfile() Profile {
+	return Profile{StartPort: StartPort, SMTPAddr: defaultSMTPAddr, WebAddr: defaultWebAddr}
+}
+
+// CurrentProfile returns the active profile, allocating a slot and
+// saving profile.json the first time a named profile is used.
+func CurrentProfile() (Profile, error) {
+	name := ProfileName()
+	if name == "" {
+		return DefaultProfile(), nil
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return Profile{}, err
+	}
+
+	path := filepath.Join(BaseDir(), "profile.json")
+	if p, err := loadProfile(path); err == nil {
+		return p, nil
+	} else if !os.IsNotExist(err) {
+		return Profile{}, err
+	}
+
+	slot := 1
+	for _, p := range Profiles() {
+		if p.Slot >= slot {
+			slot = p.Slot + 1
+		}
+	}
+	p := Profile{
+		Name:       name,
+		Slot:       slot,
+		StartPort:  StartPort + slot*profilePortStride,
+		CaddyAdmin: "localhost:" + strconv.Itoa(2019+slot),
+		SMTPAddr:   "127.0.0.1:" + strconv.Itoa(1025+slot),
+		WebAddr:    "127.0.0.1:" + strconv.Itoa(8025+slot),
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return Profile{}, err
+	}
+	return p, os.WriteFile(path, data, 0644)
+}
+
+// Profiles returns all named profiles that have been used.
+func Profiles() []Profile {
+	paths, _ := filepath.Glob(filepath.Join(RootDir(), "profiles", "*", "profile.json"))
+	var out []Profile
+	for _, path := range paths {
+		if p, err := loadProfile(path); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func loadProfile(path string) (Profile, error) {
+	var p Profile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// profileStartPort returns the first port of the profile stored in
+// baseDir, or StartPort for the default profile.
+func profileStartPort(baseDir string) int {
+	if p, err := loadProfile(filepath.Join(baseDir, "profile.json")); err == nil && p.StartPort > 0 {
+		return p.StartPort
+	}
+	return StartPort
+}
+
+// PoolName returns the PHP-FPM pool name for a site. Pool files of all
+// profiles share each PHP version's pool.d, so named profiles prefix theirs.
+func PoolName(port int) string {
+	if name := ProfileName(); name != "" {
+		return fmt.Sprintf("locwp-%s-%d", name, port)
+	}
+	return fmt.Sprintf("locwp-%d", port)
+}
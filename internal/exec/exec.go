@@ -1,8 +1,11 @@
 package exec
 
 import (
+	"errors"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // CommandExists checks if a command is available in PATH.
@@ -11,6 +14,11 @@ func CommandExists(name string) bool {
 	return err == nil
 }
 
+// LookPath returns the path of a command found in PATH.
+func LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
 // Run executes a command with stdout/stderr connected to the terminal.
 func Run(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
@@ -30,8 +38,65 @@ func RunInDir(dir string, name string, args ...string) error {
 	return cmd.Run()
 }
 
+// RunWithEnv executes a command in dir with the given environment and
+// stdin/stdout/stderr connected to the terminal.
+func RunWithEnv(dir string, env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// RunTo executes a command with stdout and stderr written to w and no
+// stdin, for commands run alongside others.
+func RunTo(w io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
+
+// ExitCode returns the exit status of a command that failed with err: 0
+// for nil, and -1 if it didn't get to exit.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	}
+	return -1
+}
+
 // Output executes a command and returns its stdout as a string.
 func Output(name string, args ...string) (string, error) {
 	out, err := exec.Command(name, args...).Output()
 	return string(out), err
 }
+
+// CombinedOutput executes a command and returns its stdout and stderr.
+func CombinedOutput(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	return string(out), err
+}
+
+// CombinedOutputInDir executes a command in a specific directory and
+// returns its stdout and stderr.
+func CombinedOutputInDir(dir string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// OutputWithInput executes a command with input on stdin and returns its stdout.
+func OutputWithInput(input string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -1,6 +1,9 @@
 package exec
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestCommandExists(t *testing.T) {
 	if !CommandExists("go") {
@@ -10,3 +13,24 @@ func TestCommandExists(t *testing.T) {
 		t.Error("CommandExists(\"nonexistent-binary-xyz-123\") = true, want false")
 	}
 }
+
+func TestRunToAndExitCode(t *testing.T) {
+	var buf strings.Builder
+	if err := RunTo(&buf, "sh", "-c", "echo out; echo err >&2"); err != nil {
+		t.Fatalf("RunTo() error: %v", err)
+	}
+	if buf.String() != "out\nerr\n" {
+		t.Errorf("RunTo() output = %q, want stdout and stderr", buf.String())
+	}
+
+	err := RunTo(&buf, "sh", "-c", "exit 3")
+	if got := ExitCode(err); got != 3 {
+		t.Errorf("ExitCode() = %d, want 3", got)
+	}
+	if got := ExitCode(nil); got != 0 {
+		t.Errorf("ExitCode(nil) = %d, want 0", got)
+	}
+	if got := ExitCode(RunTo(&buf, "nonexistent-binary-xyz-123")); got != -1 {
+		t.Errorf("ExitCode() for a missing binary = %d, want -1", got)
+	}
+}
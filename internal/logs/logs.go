@@ -0,0 +1,235 @@
+// Package logs reads and follows a site's Caddy access log, PHP-FPM error
+// log and WordPress debug.log as one timestamped stream.
+package logs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yansircc/locwp/internal/bytesize"
+)
+
+// Source names.
+const (
+	SourceAccess  = "access"
+	SourcePHP     = "php"
+	SourceWPDebug = "wp-debug"
+)
+
+// Sources lists every source name in display order.
+var Sources = []string{SourceAccess, SourcePHP, SourceWPDebug}
+
+// Source is one log file of one site.
+type Source struct {
+	Site string
+	Name string
+	Path string
+}
+
+// Entry is a single parsed log record.
+type Entry struct {
+	Time   time.Time
+	Site   string
+	Source string
+	Text   string
+}
+
+// String formats an entry as one or more output lines.
+func (e Entry) String() string {
+	return fmt.Sprintf("%s [%s %s] %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Site, e.Source, e.Text)
+}
+
+// Filter selects entries.
+type Filter struct {
+	Since time.Time
+	Grep  *regexp.Regexp
+}
+
+// Match reports whether an entry passes the filter.
+func (f Filter) Match(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	return f.Grep == nil || f.Grep.MatchString(e.Text)
+}
+
+// Read parses all sources and returns matching entries interleaved by
+// time, along with the size of each file so Follow can continue from there.
+func Read(sources []Source, f Filter) ([]Entry, map[string]int64, error) {
+	var all []Entry
+	offsets := map[string]int64{}
+	for _, src := range sources {
+		file, err := os.Open(src.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		entries, n, err := parse(src, file)
+		file.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		offsets[src.Path] = n
+		for _, e := range entries {
+			if f.Match(e) {
+				all = append(all, e)
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+	return all, offsets, nil
+}
+
+// Follow polls the sources for appended lines and calls emit for each new
+// matching entry until stop is closed. offsets are the starting positions
+// returned by Read; files missing from it are followed from their start.
+func Follow(sources []Source, offsets map[string]int64, f Filter, interval time.Duration, stop <-chan struct{}, emit func(Entry)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, src := range sources {
+			file, err := os.Open(src.Path)
+			if err != nil {
+				continue
+			}
+			fi, err := file.Stat()
+			if err == nil && fi.Size() < offsets[src.Path] {
+				offsets[src.Path] = 0 // truncated or rotated
+			}
+			file.Seek(offsets[src.Path], io.SeekStart)
+			entries, n, err := parse(src, file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			offsets[src.Path] += n
+			for _, e := range entries {
+				if f.Match(e) {
+					emit(e)
+				}
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// parse reads complete lines from r and returns the entries and the number
+// of bytes consumed. A trailing partial line is left for the next read.
+func parse(src Source, r io.Reader) ([]Entry, int64, error) {
+	var (
+		entries []Entry
+		n       int64
+	)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return entries, n, nil
+			}
+			return nil, 0, err
+		}
+		n += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		e, ok := ParseLine(src.Name, line)
+		if !ok {
+			// Continuation of a multi-line PHP message (stack traces).
+			if len(entries) > 0 {
+				entries[len(entries)-1].Text += "\n    " + line
+				continue
+			}
+			e = Entry{Time: time.Now(), Text: line}
+		}
+		e.Site, e.Source = src.Site, src.Name
+		entries = append(entries, e)
+	}
+}
+
+// ParseLine parses one line of the given source. ok is false when the
+// line does not start a new record.
+func ParseLine(source, line string) (e Entry, ok bool) {
+	if source == SourceAccess {
+		return parseAccess(line)
+	}
+	return parsePHP(line)
+}
+
+type caddyAccess struct {
+	TS      float64 `json:"ts"`
+	Request struct {
+		RemoteIP string `json:"remote_ip"`
+		Method   string `json:"method"`
+		URI      string `json:"uri"`
+	} `json:"request"`
+	Duration float64 `json:"duration"`
+	Size     int64   `json:"size"`
+	Status   int     `json:"status"`
+}
+
+func parseAccess(line string) (Entry, bool) {
+	var a caddyAccess
+	if err := json.Unmarshal([]byte(line), &a); err != nil || a.TS == 0 {
+		return Entry{Text: line}, false
+	}
+	sec := int64(a.TS)
+	return Entry{
+		Time: time.Unix(sec, int64((a.TS-float64(sec))*1e9)),
+		Text: fmt.Sprintf("%s %s %d %s %dms %s", a.Request.Method, a.Request.URI, a.Status, bytesize.Format(a.Size), int64(a.Duration*1000), a.Request.RemoteIP),
+	}, true
+}
+
+// PHP and WordPress log lines start with "[19-Oct-2026 10:00:00 UTC]"; the
+// zone is whatever date.timezone is set to, often an IANA name.
+var phpLineRe = regexp.MustCompile(`^\[(\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}:\d{2}) ([^\]]+)\] (.*)$`)
+
+func parsePHP(line string) (Entry, bool) {
+	m := phpLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{Text: line}, false
+	}
+	t, err := time.ParseInLocation("02-Jan-2006 15:04:05", m[1], zone(m[2]))
+	if err != nil {
+		return Entry{Text: line}, false
+	}
+	return Entry{Time: t, Text: m[3]}, true
+}
+
+// zones caches time.LoadLocation, which reads the zoneinfo database on
+// every call; a log uses one or two zones across thousands of lines.
+var (
+	zonesMu sync.Mutex
+	zones   = map[string]*time.Location{}
+)
+
+// zone returns the location named in a PHP log line, or UTC for an
+// unknown name.
+func zone(name string) *time.Location {
+	zonesMu.Lock()
+	defer zonesMu.Unlock()
+	if loc, ok := zones[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	zones[name] = loc
+	return loc
+}
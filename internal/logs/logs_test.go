@@ -0,0 +1,137 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const accessLine = `{"level":"info","ts":1760868000.5,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"127.0.0.1","method":"GET","host":"localhost:10001","uri":"/wp-admin/"},"duration":0.0421,"size":2048,"status":502}`
+
+func TestParseLine_Access(t *testing.T) {
+	e, ok := ParseLine(SourceAccess, accessLine)
+	if !ok {
+		t.Fatal("ParseLine() did not parse Caddy JSON")
+	}
+	if e.Time.Unix() != 1760868000 {
+		t.Errorf("Time = %v", e.Time)
+	}
+	want := "GET /wp-admin/ 502 2.0KB 42ms 127.0.0.1"
+	if e.Text != want {
+		t.Errorf("Text = %q, want %q", e.Text, want)
+	}
+}
+
+func TestParseLine_PHP(t *testing.T) {
+	e, ok := ParseLine(SourcePHP, "[19-Oct-2025 10:00:00 America/New_York] PHP Fatal error:  boom in /x.php:3")
+	if !ok {
+		t.Fatal("ParseLine() did not parse PHP log line")
+	}
+	if got := e.Time.UTC().Format(time.RFC3339); got != "2025-10-19T14:00:00Z" {
+		t.Errorf("Time = %s, want zone-aware 2025-10-19T14:00:00Z", got)
+	}
+	if e.Text != "PHP Fatal error:  boom in /x.php:3" {
+		t.Errorf("Text = %q", e.Text)
+	}
+	if _, ok := ParseLine(SourceWPDebug, "Stack trace:"); ok {
+		t.Error("continuation line should not start a record")
+	}
+}
+
+func writeLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(strings.Join(lines, "\n") + "\n")
+	f.Close()
+}
+
+func TestRead_InterleavesAndFilters(t *testing.T) {
+	dir := t.TempDir()
+	access := filepath.Join(dir, "access.log")
+	php := filepath.Join(dir, "php-error.log")
+	writeLog(t, access, accessLine)
+	writeLog(t, php,
+		"[19-Oct-2025 09:59:59 UTC] PHP Warning:  early",
+		"[19-Oct-2025 10:00:01 UTC] PHP Fatal error:  late",
+		"Stack trace:",
+		"#0 {main}")
+	sources := []Source{
+		{Site: "10001", Name: SourceAccess, Path: access},
+		{Site: "10001", Name: SourcePHP, Path: php},
+		{Site: "10001", Name: SourceWPDebug, Path: filepath.Join(dir, "missing.log")},
+	}
+
+	entries, offsets, err := Read(sources, Filter{})
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Read() returned %d entries, want 3", len(entries))
+	}
+	order := []string{SourcePHP, SourceAccess, SourcePHP}
+	for i, src := range order {
+		if entries[i].Source != src {
+			t.Errorf("entries[%d].Source = %q, want %q", i, entries[i].Source, src)
+		}
+	}
+	if !strings.Contains(entries[2].Text, "#0 {main}") {
+		t.Errorf("stack trace not attached: %q", entries[2].Text)
+	}
+	if offsets[php] == 0 || offsets[access] == 0 {
+		t.Errorf("offsets = %v", offsets)
+	}
+
+	entries, _, _ = Read(sources, Filter{Grep: regexp.MustCompile("Fatal")})
+	if len(entries) != 1 {
+		t.Errorf("grep filter returned %d entries, want 1", len(entries))
+	}
+	since := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	entries, _, _ = Read(sources, Filter{Since: since})
+	if len(entries) != 2 {
+		t.Errorf("since filter returned %d entries, want 2", len(entries))
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	php := filepath.Join(dir, "php-error.log")
+	writeLog(t, php, "[19-Oct-2025 10:00:00 UTC] old")
+	sources := []Source{{Site: "10001", Name: SourcePHP, Path: php}}
+	_, offsets, _ := Read(sources, Filter{})
+
+	got := make(chan Entry, 4)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Follow(sources, offsets, Filter{}, 10*time.Millisecond, stop, func(e Entry) { got <- e })
+	}()
+
+	writeLog(t, php, "[19-Oct-2025 10:00:05 UTC] new")
+	select {
+	case e := <-got:
+		if e.Text != "new" {
+			t.Errorf("followed entry = %q, want \"new\"", e.Text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Follow() did not emit the appended line")
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("Follow() error: %v", err)
+	}
+}
+
+func TestZone(t *testing.T) {
+	if zone("Not/AZone") != time.UTC {
+		t.Error("zone() should fall back to UTC")
+	}
+	if a, b := zone("Europe/Paris"), zone("Europe/Paris"); a != b {
+		t.Error("zone() should cache locations")
+	}
+}
@@ -0,0 +1,141 @@
+package mail
+
+import (
+	"net"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+const testMessage = "From: WordPress <wordpress@localhost>\r\n" +
+	"To: admin@loc.wp\r\n" +
+	"Subject: =?UTF-8?B?UGFzc3dvcmQgcmVzZXQ=?=\r\n" +
+	"Content-Type: multipart/alternative; boundary=b1\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Reset link: http://localhost:10001/wp-login.php?key=3Dabc\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/html; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"PGI+UmVzZXQ8L2I+\r\n" +
+	"--b1--\r\n"
+
+func TestSaveListLoad(t *testing.T) {
+	dir := t.TempDir()
+	id, err := Save(dir, []byte(testMessage))
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	list, err := List(dir)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List() = %v, %v; want 1 message", list, err)
+	}
+	if list[0].Subject != "Password reset" {
+		t.Errorf("Subject = %q, want decoded \"Password reset\"", list[0].Subject)
+	}
+
+	msg, err := Load(dir, id)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !strings.Contains(msg.Text, "key=abc") {
+		t.Errorf("Text = %q, want quoted-printable decoded", msg.Text)
+	}
+	if msg.HTML != "<b>Reset</b>" {
+		t.Errorf("HTML = %q, want base64 decoded", msg.HTML)
+	}
+
+	if _, err := Load(dir, "../etc/passwd"); err == nil {
+		t.Error("Load() should reject path traversal")
+	}
+
+	if err := Clear(dir); err != nil {
+		t.Fatalf("Clear() error: %v", err)
+	}
+	if list, _ := List(dir); len(list) != 0 {
+		t.Errorf("List() after Clear() = %d messages", len(list))
+	}
+}
+
+func TestServeSMTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	got := make(chan []byte, 1)
+	var gotTo []string
+	go ServeSMTP(ln, func(from string, to []string, data []byte) error {
+		gotTo = to
+		got <- data
+		return nil
+	})
+
+	body := SiteHeader + ": 10001\r\nSubject: hi\r\n\r\n.leading dot\r\nbye\r\n"
+	if err := smtp.SendMail(ln.Addr().String(), nil, "wp@localhost", []string{"a@b.c", "d@e.f"}, []byte(body)); err != nil {
+		t.Fatalf("SendMail() error: %v", err)
+	}
+	data := <-got
+	if string(data) != body {
+		t.Errorf("delivered data = %q, want %q", data, body)
+	}
+	if len(gotTo) != 2 {
+		t.Errorf("recipients = %v, want 2", gotTo)
+	}
+	if SiteOf(data) != "10001" {
+		t.Errorf("SiteOf() = %q, want \"10001\"", SiteOf(data))
+	}
+}
+
+func TestSendmail_Fallback(t *testing.T) {
+	// With no sink listening on SMTPAddr the message lands in the fallback dir.
+	if ln, err := net.Listen("tcp", SMTPAddr); err == nil {
+		ln.Close()
+	} else {
+		t.Skip("a mail sink is already running on " + SMTPAddr)
+	}
+	dir := t.TempDir()
+	if err := Sendmail([]byte("To: a@b.c\nSubject: direct\n\nbody\n"), "10001", SMTPAddr, dir); err != nil {
+		t.Fatalf("Sendmail() error: %v", err)
+	}
+	list, _ := List(dir)
+	if len(list) != 1 || list[0].Subject != "direct" {
+		t.Fatalf("fallback mailbox = %+v", list)
+	}
+	msg, _ := Load(dir, list[0].ID)
+	if SiteOf(msg.Raw) != "10001" {
+		t.Error("fallback message missing site header")
+	}
+}
+
+func TestWebHandler(t *testing.T) {
+	dir := t.TempDir()
+	id, _ := Save(dir, []byte(testMessage))
+	h := WebHandler(func() []Mailbox { return []Mailbox{{Name: "10001", Dir: dir}} })
+
+	for path, want := range map[string]string{
+		"/":                     "10001",
+		"/10001/":               "Password reset",
+		"/10001/" + id:          "srcdoc=",
+		"/10001/" + id + "/raw": "Content-Transfer-Encoding",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s = %d, body missing %q", path, rec.Code, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/99999/", nil))
+	if rec.Code != 404 {
+		t.Errorf("GET unknown mailbox = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"bytes"
+	"net/mail"
+	"net/smtp"
+)
+
+// Sendmail handles a message piped to PHP's sendmail_path. The message is
+// tagged with the sending site's port and relayed to the SMTP sink at
+// smtpAddr; when the sink isn't running it is written straight to
+// fallbackDir so no mail is ever lost.
+func Sendmail(raw []byte, port, smtpAddr, fallbackDir string) error {
+	tagged := append([]byte(SiteHeader+": "+port+"\r\n"), raw...)
+
+	from := "wordpress@localhost"
+	var to []string
+	if msg, err := mail.ReadMessage(bytes.NewReader(tagged)); err == nil {
+		if addrs, err := msg.Header.AddressList("From"); err == nil && len(addrs) > 0 {
+			from = addrs[0].Address
+		}
+		for _, h := range []string{"To", "Cc", "Bcc"} {
+			addrs, _ := msg.Header.AddressList(h)
+			for _, a := range addrs {
+				to = append(to, a.Address)
+			}
+		}
+	}
+	if len(to) == 0 {
+		to = []string{"unknown@localhost"}
+	}
+
+	if err := smtp.SendMail(smtpAddr, nil, from, to, tagged); err == nil {
+		return nil
+	}
+	_, err := Save(fallbackDir, tagged)
+	return err
+}
+
+// SiteOf returns the site port a message was tagged with by Sendmail.
+func SiteOf(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	return msg.Header.Get(SiteHeader)
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Listen addresses of the default profile's mail sink. Both bind to
+// localhost only; named profiles use their own (see config.Profile).
+const (
+	SMTPAddr = "127.0.0.1:1025"
+	WebAddr  = "127.0.0.1:8025"
+)
+
+// maxMessageSize caps a single DATA payload.
+const maxMessageSize = 32 << 20
+
+// DeliverFunc receives every message accepted by the SMTP server.
+type DeliverFunc func(from string, to []string, data []byte) error
+
+// ServeSMTP accepts SMTP connections on l and hands each message to
+// deliver. It implements just enough of RFC 5321 for PHP mailers and
+// net/smtp: no auth, no TLS, every recipient accepted.
+func ServeSMTP(l net.Listener, deliver DeliverFunc) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go smtpSession(conn, deliver)
+	}
+}
+
+func smtpSession(conn net.Conn, deliver DeliverFunc) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		w.Flush()
+	}
+
+	var (
+		from string
+		to   []string
+	)
+	reply("220 localhost locwp mail sink ready")
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 localhost")
+		case "EHLO":
+			reply("250-localhost\r\n250-8BITMIME\r\n250 SIZE %d", maxMessageSize)
+		case "MAIL":
+			from = addrArg(arg)
+			to = nil
+			reply("250 OK")
+		case "RCPT":
+			to = append(to, addrArg(arg))
+			reply("250 OK")
+		case "DATA":
+			if len(to) == 0 {
+				reply("503 RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				reply("552 %v", err)
+				return
+			}
+			if err := deliver(from, to, data); err != nil {
+				reply("554 %v", err)
+			} else {
+				reply("250 OK")
+			}
+			from, to = "", nil
+		case "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// readData reads a DATA payload up to the terminating "." line and undoes
+// dot-stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "." {
+			return buf.Bytes(), nil
+		}
+		if strings.HasPrefix(line, ".") {
+			line = line[1:]
+		}
+		if buf.Len()+len(line) > maxMessageSize {
+			return nil, errors.New("message too large")
+		}
+		buf.WriteString(line)
+	}
+}
+
+// addrArg extracts the address from "FROM:<a@b>" / "TO:<a@b> SIZE=1".
+func addrArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr = strings.TrimSpace(addr)
+	if i := strings.IndexByte(addr, '>'); i >= 0 {
+		addr = addr[:i]
+	}
+	return strings.TrimPrefix(addr, "<")
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SiteHeader tags a message with the port of the site that sent it.
+const SiteHeader = "X-Locwp-Site"
+
+// Summary is the listing view of a stored message.
+type Summary struct {
+	ID      string    `json:"id"`
+	Date    time.Time `json:"date"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+}
+
+// Message is a stored message with its decoded bodies.
+type Message struct {
+	Summary
+	Text string `json:"text,omitempty"`
+	HTML string `json:"html,omitempty"`
+	Raw  []byte `json:"-"`
+}
+
+// Dir returns the mailbox directory inside a site directory.
+func Dir(siteDir string) string {
+	return filepath.Join(siteDir, "mail")
+}
+
+// Save stores a raw RFC 5322 message in a mailbox and returns its ID.
+func Save(dir string, raw []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	return id, os.WriteFile(filepath.Join(dir, id+".eml"), raw, 0644)
+}
+
+// List returns summaries of all messages in a mailbox, newest first.
+func List(dir string) ([]Summary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Summary
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".eml")
+		if !ok {
+			continue
+		}
+		m, err := Load(dir, id)
+		if err != nil {
+			continue
+		}
+		out = append(out, m.Summary)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+// Load reads and decodes one message.
+func Load(dir, id string) (*Message, error) {
+	if strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid message id %q", id)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, id+".eml"))
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	dec := new(mime.WordDecoder)
+	header := func(k string) string {
+		v := msg.Header.Get(k)
+		if d, err := dec.DecodeHeader(v); err == nil {
+			return d
+		}
+		return v
+	}
+	m := &Message{
+		Summary: Summary{
+			ID:      id,
+			From:    header("From"),
+			To:      header("To"),
+			Subject: header("Subject"),
+		},
+		Raw: raw,
+	}
+	if d, err := msg.Header.Date(); err == nil {
+		m.Date = d
+	} else if ns, err := strconv.ParseInt(id, 10, 64); err == nil {
+		m.Date = time.Unix(0, ns)
+	}
+	m.Text, m.HTML = bodies(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	return m, nil
+}
+
+// Clear deletes all messages in a mailbox.
+func Clear(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".eml") {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bodies extracts the first text/plain and text/html parts of a message.
+func bodies(contentType, encoding string, body io.Reader) (text, html string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return text, html
+			}
+			t, h := bodies(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if text == "" {
+				text = t
+			}
+			if html == "" {
+				html = h
+			}
+		}
+	}
+
+	data, _ := io.ReadAll(decode(encoding, body))
+	switch mediaType {
+	case "text/html":
+		return "", string(data)
+	case "text/plain":
+		return string(data), ""
+	}
+	return "", ""
+}
+
+func decode(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return r
+}
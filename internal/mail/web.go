@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Mailbox is a named message directory shown in the web inbox.
+type Mailbox struct {
+	Name string
+	Dir  string
+}
+
+var webTmpl = template.Must(template.New("inbox").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>locwp mail</title>
+<style>
+body{font:14px system-ui,sans-serif;margin:0;display:flex;height:100vh}
+nav{width:160px;background:#f4f4f5;padding:12px;overflow:auto}
+nav a{display:block;padding:4px 6px;color:#18181b;text-decoration:none;border-radius:4px}
+nav a.on{background:#e4e4e7}
+main{flex:1;overflow:auto;padding:12px}
+table{border-collapse:collapse;width:100%}
+td,th{text-align:left;padding:6px;border-bottom:1px solid #e4e4e7}
+iframe{width:100%;height:70vh;border:1px solid #e4e4e7}
+pre{white-space:pre-wrap}
+</style></head><body>
+<nav>{{range .Boxes}}<a href="/{{.Name}}/"{{if eq .Name $.Box}} class="on"{{end}}>{{.Name}}</a>{{end}}</nav>
+<main>
+{{if .Msg}}
+<p><a href="/{{.Box}}/">&larr; back</a> &middot; <a href="/{{.Box}}/{{.Msg.ID}}/raw">raw</a></p>
+<h2>{{.Msg.Subject}}</h2>
+<p>From: {{.Msg.From}}<br>To: {{.Msg.To}}<br>Date: {{.Msg.Date.Format "2006-01-02 15:04:05"}}</p>
+{{if .Msg.HTML}}<iframe sandbox srcdoc="{{.Msg.HTML}}"></iframe>{{else}}<pre>{{.Msg.Text}}</pre>{{end}}
+{{else if .Box}}
+<table><tr><th>Date</th><th>From</th><th>To</th><th>Subject</th></tr>
+{{range .List}}<tr><td>{{.Date.Format "2006-01-02 15:04"}}</td><td>{{.From}}</td><td>{{.To}}</td><td><a href="/{{$.Box}}/{{.ID}}">{{.Subject}}</a></td></tr>
+{{else}}<tr><td colspan="4">No messages.</td></tr>{{end}}
+</table>
+{{else}}<p>Select a site.</p>{{end}}
+</main></body></html>`))
+
+// WebHandler serves a small inbox UI. boxes is called on every request so
+// newly added sites appear without a restart. Routes: / , /<box>/ ,
+// /<box>/<id> and /<box>/<id>/raw.
+func WebHandler(boxes func() []Mailbox) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		all := boxes()
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		data := struct {
+			Boxes []Mailbox
+			Box   string
+			List  []Summary
+			Msg   *Message
+		}{Boxes: all}
+
+		if parts[0] == "" {
+			webTmpl.Execute(w, data)
+			return
+		}
+		var dir string
+		for _, b := range all {
+			if b.Name == parts[0] {
+				dir = b.Dir
+			}
+		}
+		if dir == "" {
+			http.NotFound(w, r)
+			return
+		}
+		data.Box = parts[0]
+
+		if len(parts) == 1 {
+			list, err := List(dir)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.List = list
+			webTmpl.Execute(w, data)
+			return
+		}
+
+		msg, err := Load(dir, parts[1])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if len(parts) == 3 && parts[2] == "raw" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(msg.Raw)
+			return
+		}
+		data.Msg = msg
+		webTmpl.Execute(w, data)
+	})
+}
@@ -0,0 +1,262 @@
+package migrate
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format identifies a WordPress migration archive type.
+type Format string
+
+const (
+	FormatWPress     Format = "wpress"     // All-in-One WP Migration export
+	FormatDuplicator Format = "duplicator" // Duplicator package archive
+	FormatZip        Format = "zip"        // plain wp-content + SQL dump zip
+)
+
+// Package is the result of extracting a migration archive.
+type Package struct {
+	Format  Format
+	SQLFile string // extracted database dump, inside the work directory
+}
+
+// wpressPrefix is the placeholder All-in-One WP Migration uses for the
+// table prefix in its database.sql.
+const wpressPrefix = "SERVMASK_PREFIX_"
+
+// DefaultTablePrefix is the table prefix used for freshly provisioned sites.
+const DefaultTablePrefix = "wp_"
+
+// Detect inspects an archive and reports its format.
+func Detect(archive string) (Format, error) {
+	if strings.EqualFold(filepath.Ext(archive), ".wpress") {
+		return FormatWPress, nil
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return "", fmt.Errorf("%s: unrecognized archive (expected .wpress or .zip): %w", archive, err)
+	}
+	defer zr.Close()
+
+	var hasContent, hasSQL bool
+	for _, f := range zr.File {
+		if isDuplicatorSQL(f.Name) || strings.HasPrefix(f.Name, "dup-installer/") {
+			return FormatDuplicator, nil
+		}
+		if _, ok := contentPath(f.Name); ok {
+			hasContent = true
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".sql") {
+			hasSQL = true
+		}
+	}
+	if hasContent && hasSQL {
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("%s: zip has no wp-content directory and SQL dump", archive)
+}
+
+// Extract unpacks a migration archive. wp-content files are written into
+// wpRoot/wp-content, the database dump into workDir. Files that would
+// clobber the site's SQLite integration are skipped.
+func Extract(archive string, format Format, wpRoot, workDir string) (*Package, error) {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, err
+	}
+	pkg := &Package{Format: format}
+	contentDir := filepath.Join(wpRoot, "wp-content")
+
+	var err error
+	switch format {
+	case FormatWPress:
+		err = extractWPress(archive, contentDir, workDir, pkg)
+	case FormatDuplicator, FormatZip:
+		err = extractZip(archive, contentDir, workDir, pkg)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pkg.SQLFile == "" {
+		return nil, fmt.Errorf("%s: no database dump found", archive)
+	}
+	return pkg, nil
+}
+
+// wpress archives are a sequence of fixed-size headers, each followed by
+// the file body. A header of all zero bytes marks the end.
+const (
+	wpressHeaderSize = 4377
+	wpressNameLen    = 255
+	wpressSizeLen    = 14
+	wpressMTimeLen   = 12
+)
+
+func extractWPress(archive, contentDir, workDir string, pkg *Package) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, wpressHeaderSize)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read wpress header: %w", err)
+		}
+		if bytes.Count(header, []byte{0}) == wpressHeaderSize {
+			return nil
+		}
+		name := cString(header[:wpressNameLen])
+		sizeField := cString(header[wpressNameLen : wpressNameLen+wpressSizeLen])
+		prefix := cString(header[wpressNameLen+wpressSizeLen+wpressMTimeLen:])
+		size, err := strconv.ParseInt(sizeField, 10, 64)
+		if err != nil {
+			return fmt.Errorf("wpress entry %q: bad size %q", name, sizeField)
+		}
+		rel := path.Join(prefix, name)
+		body := io.LimitReader(f, size)
+
+		switch rel {
+		case "database.sql":
+			pkg.SQLFile = filepath.Join(workDir, "database.sql")
+			data, err := io.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			data = bytes.ReplaceAll(data, []byte(wpressPrefix), []byte(DefaultTablePrefix))
+			if err := os.WriteFile(pkg.SQLFile, data, 0644); err != nil {
+				return err
+			}
+		case "package.json", "multisite.json", "blogs.json":
+			if err := writeFile(filepath.Join(workDir, rel), body); err != nil {
+				return err
+			}
+		default:
+			if skipContent(rel) {
+				break
+			}
+			dst, err := safeJoin(contentDir, rel)
+			if err != nil {
+				return err
+			}
+			if err := writeFile(dst, body); err != nil {
+				return err
+			}
+		}
+		// Skip whatever the handler left unread.
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archive, contentDir, workDir string, pkg *Package) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		var dst string
+		if rel, ok := contentPath(f.Name); ok {
+			if skipContent(rel) {
+				continue
+			}
+			if dst, err = safeJoin(contentDir, rel); err != nil {
+				return err
+			}
+		} else if isDumpFile(f.Name, pkg.Format) && pkg.SQLFile == "" {
+			dst = filepath.Join(workDir, "database.sql")
+			pkg.SQLFile = dst
+		} else {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(dst, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contentPath returns the part of an archive path below wp-content/.
+func contentPath(name string) (string, bool) {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		if p == "wp-content" && i < len(parts)-1 {
+			rel := strings.Join(parts[i+1:], "/")
+			return rel, rel != ""
+		}
+	}
+	return "", false
+}
+
+// skipContent reports whether a wp-content relative path belongs to the
+// site's own SQLite setup and must not be overwritten by the import.
+func skipContent(rel string) bool {
+	return rel == "db.php" ||
+		strings.HasPrefix(rel, "database/") ||
+		strings.HasPrefix(rel, "mu-plugins/sqlite-database-integration/")
+}
+
+func isDuplicatorSQL(name string) bool {
+	return strings.HasPrefix(path.Base(name), "dup-database_") && strings.HasSuffix(name, ".sql")
+}
+
+func isDumpFile(name string, format Format) bool {
+	if format == FormatDuplicator {
+		return isDuplicatorSQL(name) || name == "database.sql"
+	}
+	return strings.EqualFold(path.Ext(name), ".sql")
+}
+
+func safeJoin(dir, rel string) (string, error) {
+	clean := path.Clean(rel)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+		return "", fmt.Errorf("unsafe archive path %q", rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+func writeFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
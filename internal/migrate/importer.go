@@ -0,0 +1,42 @@
+package migrate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// importerPHP runs each statement through $wpdb so the SQLite database
+// integration drop-in translates the MySQL syntax. Invoked with
+// `wp eval-file import.php <statements.json>`.
+const importerPHP = `<?php
+global $wpdb;
+
+$stmts  = json_decode( file_get_contents( $args[0] ), true );
+$failed = 0;
+foreach ( $stmts as $sql ) {
+	if ( false === $wpdb->query( $sql ) ) {
+		$failed++;
+		WP_CLI::warning( substr( $sql, 0, 120 ) . ': ' . $wpdb->last_error );
+	}
+}
+WP_CLI::log( sprintf( 'Imported %d statements (%d failed).', count( $stmts ) - $failed, $failed ) );
+`
+
+// WriteImporter writes the statements and the PHP importer script into
+// workDir and returns their paths.
+func WriteImporter(workDir string, stmts []string) (script, statements string, err error) {
+	data, err := json.Marshal(stmts)
+	if err != nil {
+		return "", "", err
+	}
+	statements = filepath.Join(workDir, "statements.json")
+	if err := os.WriteFile(statements, data, 0644); err != nil {
+		return "", "", err
+	}
+	script = filepath.Join(workDir, "import.php")
+	if err := os.WriteFile(script, []byte(importerPHP), 0644); err != nil {
+		return "", "", err
+	}
+	return script, statements, nil
+}
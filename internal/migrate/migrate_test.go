@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func wpressHeader(name, prefix string, size int) []byte {
+	h := make([]byte, wpressHeaderSize)
+	copy(h, name)
+	copy(h[wpressNameLen:], fmt.Sprint(size))
+	copy(h[wpressNameLen+wpressSizeLen:], "1700000000")
+	copy(h[wpressNameLen+wpressSizeLen+wpressMTimeLen:], prefix)
+	return h
+}
+
+func writeWPress(t *testing.T, path string, entries [][3]string) {
+	t.Helper()
+	var data []byte
+	for _, e := range entries {
+		data = append(data, wpressHeader(e[0], e[1], len(e[2]))...)
+		data = append(data, e[2]...)
+	}
+	data = append(data, make([]byte, wpressHeaderSize)...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+
+	dup := filepath.Join(dir, "dup.zip")
+	writeZip(t, dup, map[string]string{
+		"wp-content/plugins/a.php":            "<?php",
+		"dup-installer/dup-database__abc.sql": "SELECT 1;",
+		"dup-installer/dup-archive__abc.txt":  "{}",
+		"wp-includes/version.php":             "<?php",
+	})
+	plain := filepath.Join(dir, "plain.zip")
+	writeZip(t, plain, map[string]string{
+		"site/wp-content/themes/t/style.css": "/* */",
+		"site/dump.sql":                      "SELECT 1;",
+	})
+	bogus := filepath.Join(dir, "bogus.zip")
+	writeZip(t, bogus, map[string]string{"readme.txt": "hi"})
+
+	tests := []struct {
+		path string
+		want Format
+	}{
+		{filepath.Join(dir, "site.wpress"), FormatWPress},
+		{dup, FormatDuplicator},
+		{plain, FormatZip},
+	}
+	for _, tt := range tests {
+		got, err := Detect(tt.path)
+		if err != nil {
+			t.Errorf("Detect(%s) error: %v", filepath.Base(tt.path), err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Detect(%s) = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+	if _, err := Detect(bogus); err == nil {
+		t.Error("Detect() on zip without wp-content should error")
+	}
+}
+
+func TestExtract_WPress(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "site.wpress")
+	writeWPress(t, archive, [][3]string{
+		{"package.json", ".", `{"SiteURL":"https://example.com"}`},
+		{"database.sql", ".", "CREATE TABLE `SERVMASK_PREFIX_options` (id int);"},
+		{"style.css", "themes/acme", "body{}"},
+		{"db.php", ".", "<?php // foreign drop-in"},
+	})
+	wpRoot := filepath.Join(dir, "wordpress")
+	workDir := filepath.Join(dir, "work")
+
+	pkg, err := Extract(archive, FormatWPress, wpRoot, workDir)
+	if err != nil {
+		t.Fatalf("Extract() error: %v", err)
+	}
+	sql, _ := os.ReadFile(pkg.SQLFile)
+	if !strings.Contains(string(sql), "`wp_options`") {
+		t.Errorf("database.sql prefix placeholder not replaced: %s", sql)
+	}
+	if _, err := os.Stat(filepath.Join(wpRoot, "wp-content", "themes", "acme", "style.css")); err != nil {
+		t.Errorf("theme file not extracted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wpRoot, "wp-content", "db.php")); err == nil {
+		t.Error("db.php drop-in should be skipped")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "package.json")); err != nil {
+		t.Errorf("package.json not kept in work dir: %v", err)
+	}
+}
+
+func TestExtract_Zip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "plain.zip")
+	writeZip(t, archive, map[string]string{
+		"backup/wp-content/uploads/2024/a.jpg":                              "jpg",
+		"backup/wp-content/database/.ht.sqlite":                             "old",
+		"backup/wp-content/mu-plugins/sqlite-database-integration/load.php": "old",
+		"backup/db.sql": "SELECT 1;",
+	})
+	wpRoot := filepath.Join(dir, "wordpress")
+
+	pkg, err := Extract(archive, FormatZip, wpRoot, filepath.Join(dir, "work"))
+	if err != nil {
+		t.Fatalf("Extract() error: %v", err)
+	}
+	if pkg.SQLFile == "" {
+		t.Fatal("SQLFile is empty")
+	}
+	if _, err := os.Stat(filepath.Join(wpRoot, "wp-content", "uploads", "2024", "a.jpg")); err != nil {
+		t.Errorf("upload not extracted: %v", err)
+	}
+	for _, skipped := range []string{"database/.ht.sqlite", "mu-plugins/sqlite-database-integration/load.php"} {
+		if _, err := os.Stat(filepath.Join(wpRoot, "wp-content", skipped)); err == nil {
+			t.Errorf("%s should be skipped", skipped)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	for _, rel := range []string{"../evil.php", "a/../../evil.php", ".."} {
+		if _, err := safeJoin("/srv/wp-content", rel); err == nil {
+			t.Errorf("safeJoin(%q) should error", rel)
+		}
+	}
+	got, err := safeJoin("/srv/wp-content", "plugins/a/../b.php")
+	if err != nil || got != "/srv/wp-content/plugins/b.php" {
+		t.Errorf("safeJoin() = %q, %v", got, err)
+	}
+}
+
+func TestSplitSQL(t *testing.T) {
+	dump := "-- MySQL dump\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"SET FOREIGN_KEY_CHECKS=0;\n" +
+		"LOCK TABLES `wp_posts` WRITE;\n" +
+		"CREATE TABLE `wp_posts` (\n  `ID` bigint # row id\n);\n" +
+		"INSERT INTO `wp_posts` VALUES (1,'a; b','it\\'s','-- not a comment');\n" +
+		"UNLOCK TABLES;\n"
+
+	stmts := SplitSQL(dump)
+	if len(stmts) != 3 {
+		t.Fatalf("SplitSQL() returned %d statements, want 3: %q", len(stmts), stmts)
+	}
+	if stmts[0] != "DROP TABLE IF EXISTS `wp_posts`" {
+		t.Errorf("stmts[0] = %q, want DROP TABLE before CREATE", stmts[0])
+	}
+	if !strings.HasPrefix(stmts[1], "CREATE TABLE `wp_posts`") || strings.Contains(stmts[1], "row id") {
+		t.Errorf("stmts[1] = %q", stmts[1])
+	}
+	want := "INSERT INTO `wp_posts` VALUES (1,'a; b','it\\'s','-- not a comment')"
+	if stmts[2] != want {
+		t.Errorf("stmts[2] = %q, want %q", stmts[2], want)
+	}
+}
+
+// mysqldump wraps its headers in bare "--" lines.
+func TestSplitSQL_MysqldumpHeader(t *testing.T) {
+	dump := `-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)
+--
+-- Host: localhost    Database: wordpress
+-- ------------------------------------------------------
+-- Server version	8.0.36
+
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+
+--
+-- Table structure for table ` + "`xy_options`" + `
+--
+
+DROP TABLE IF EXISTS ` + "`xy_options`" + `;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+CREATE TABLE ` + "`xy_options`" + ` (
+  ` + "`option_id`" + ` bigint unsigned NOT NULL AUTO_INCREMENT,
+  PRIMARY KEY (` + "`option_id`" + `)
+) ENGINE=InnoDB;
+
+--
+-- Dumping data for table ` + "`xy_options`" + `
+--
+
+LOCK TABLES ` + "`xy_options`" + ` WRITE;
+INSERT INTO ` + "`xy_options`" + ` VALUES (1);
+UNLOCK TABLES;
+--`
+
+	stmts := SplitSQL(dump)
+	if len(stmts) != 4 {
+		t.Fatalf("SplitSQL() returned %d statements, want 4: %q", len(stmts), stmts)
+	}
+	if stmts[1] != "DROP TABLE IF EXISTS `xy_options`" || !strings.HasPrefix(stmts[2], "CREATE TABLE `xy_options`") {
+		t.Errorf("CREATE TABLE not detected after a bare -- line: %q", stmts)
+	}
+	if got := TablePrefix(stmts); got != "xy_" {
+		t.Errorf("TablePrefix() = %q, want \"xy_\"", got)
+	}
+}
+
+func TestTablePrefix(t *testing.T) {
+	stmts := SplitSQL("CREATE TABLE `xy_wpforms_options` (a int);CREATE TABLE `xy_options` (a int);")
+	if got := TablePrefix(stmts); got != "xy_" {
+		t.Errorf("TablePrefix() = %q, want \"xy_\"", got)
+	}
+	if got := TablePrefix(nil); got != DefaultTablePrefix {
+		t.Errorf("TablePrefix(nil) = %q, want %q", got, DefaultTablePrefix)
+	}
+}
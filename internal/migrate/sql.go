@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// skippedStatements are session and transaction statements from MySQL
+// dumps that have no meaning for the SQLite translation layer.
+var skippedStatements = []string{"SET ", "LOCK ", "UNLOCK ", "START ", "BEGIN", "COMMIT", "USE ", "CREATE DATABASE"}
+
+var createTableRe = regexp.MustCompile("(?is)^CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?`?([A-Za-z0-9_$]+)`?")
+
+// SplitSQL splits a MySQL dump into individual statements. Comments are
+// dropped, session statements skipped, and every CREATE TABLE is preceded
+// by a DROP TABLE IF EXISTS so an import replaces tables from provisioning.
+func SplitSQL(dump string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+		quote byte // active quote character, 0 when outside quotes
+	)
+	flush := func() {
+		stmt := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if stmt == "" || isSkipped(stmt) {
+			return
+		}
+		if m := createTableRe.FindStringSubmatch(stmt); m != nil {
+			stmts = append(stmts, "DROP TABLE IF EXISTS `"+m[1]+"`")
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	for i := 0; i < len(dump); i++ {
+		c := dump[i]
+		if quote != 0 {
+			cur.WriteByte(c)
+			switch {
+			case c == '\\' && quote != '`' && i+1 < len(dump):
+				i++
+				cur.WriteByte(dump[i])
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			cur.WriteByte(c)
+		case c == '-' && isDashComment(dump[i:]), c == '#':
+			i = skipTo(dump, i, "\n")
+			cur.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(dump[i:], "/*"):
+			i = skipTo(dump, i+2, "*/")
+			cur.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// TablePrefix guesses the table prefix from the dump's options table.
+func TablePrefix(stmts []string) string {
+	prefix := ""
+	for _, s := range stmts {
+		m := createTableRe.FindStringSubmatch(s)
+		if m == nil || !strings.HasSuffix(m[1], "options") {
+			continue
+		}
+		p := strings.TrimSuffix(m[1], "options")
+		if prefix == "" || len(p) < len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return DefaultTablePrefix
+	}
+	return prefix
+}
+
+func isSkipped(stmt string) bool {
+	upper := strings.ToUpper(stmt)
+	for _, s := range skippedStatements {
+		if strings.HasPrefix(upper, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDashComment reports whether s starts a "--" comment, which MySQL
+// requires to be followed by whitespace or the end of the dump;
+// mysqldump writes bare "--" lines around its headers.
+func isDashComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2]))
+}
+
+// skipTo returns the index of the last byte of the next occurrence of end
+// at or after i, or the end of s.
+func skipTo(s string, i int, end string) int {
+	j := strings.Index(s[i:], end)
+	if j < 0 {
+		return len(s) - 1
+	}
+	return i + j + len(end) - 1
+}
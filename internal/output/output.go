@@ -0,0 +1,106 @@
+// Package output renders command results as tables, JSON or YAML, and
+// carries stable error codes for scripts.
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format selects how results are rendered.
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+)
+
+// ParseFormat validates a --output value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Table, JSON, YAML:
+		return f, nil
+	}
+	return "", WithCode(CodeInvalidArgument, fmt.Errorf("unknown output format %q (want table, json or yaml)", s))
+}
+
+// Structured reports whether f is a machine-readable format.
+func (f Format) Structured() bool {
+	return f == JSON || f == YAML
+}
+
+// Write renders v to w as JSON or YAML.
+func Write(w io.Writer, f Format, v any) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch f {
+	case JSON:
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	case YAML:
+		data, err = MarshalYAML(v)
+	default:
+		return fmt.Errorf("format %q is not structured", f)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Stable error codes. Scripts may match on these; do not rename them.
+const (
+	CodeError           = "error"
+	CodeInvalidArgument = "invalid_argument"
+	CodeNotFound        = "not_found"
+	CodeAlreadyExists   = "already_exists"
+	CodeWorkflowFailed  = "workflow_failed"
+)
+
+// Error attaches a stable code to an error.
+type Error struct {
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// WithCode wraps err with a code. A nil err stays nil.
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Code returns the code attached to err, or CodeError.
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeError
+}
+
+// ErrorObject is the structured form of a failed command.
+type ErrorObject struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewErrorObject builds the structured form of err.
+func NewErrorObject(err error) ErrorObject {
+	var o ErrorObject
+	o.Error.Code = Code(err)
+	o.Error.Message = err.Error()
+	return o
+}
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"table", "json", "yaml"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q) error: %v", s, err)
+		}
+	}
+	_, err := ParseFormat("xml")
+	if Code(err) != CodeInvalidArgument {
+		t.Errorf("ParseFormat(xml) code = %q, want %q", Code(err), CodeInvalidArgument)
+	}
+}
+
+func TestCode(t *testing.T) {
+	err := fmt.Errorf("start: %w", WithCode(CodeNotFound, errors.New("site 1 not found")))
+	if got := Code(err); got != CodeNotFound {
+		t.Errorf("Code() = %q, want %q", got, CodeNotFound)
+	}
+	if got := Code(errors.New("boom")); got != CodeError {
+		t.Errorf("Code() = %q, want %q", got, CodeError)
+	}
+	if WithCode(CodeNotFound, nil) != nil {
+		t.Error("WithCode(nil) should be nil")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, JSON, NewErrorObject(WithCode(CodeNotFound, errors.New("gone")))); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"error\": {\n    \"code\": \"not_found\",\n    \"message\": \"gone\"\n  }\n}\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	type mount struct {
+		Source string `json:"source"`
+		Type   string `json:"type"`
+	}
+	type row struct {
+		Port   int      `json:"port"`
+		URL    string   `json:"url"`
+		Pass   string   `json:"pass"`
+		Ready  bool     `json:"ready"`
+		Mounts []mount  `json:"mounts"`
+		Tags   []string `json:"tags"`
+		Note   *string  `json:"note"`
+	}
+	v := []row{{
+		Port:   10001,
+		URL:    "http://localhost:10001",
+		Pass:   "yes",
+		Ready:  true,
+		Mounts: []mount{{"/src/my-plugin", "plugin"}},
+		Tags:   []string{},
+	}}
+	got, err := MarshalYAML(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- port: 10001
+  url: "http://localhost:10001"
+  pass: "yes"
+  ready: true
+  mounts:
+    - source: /src/my-plugin
+      type: plugin
+  tags: []
+  note: null
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMarshalYAML_Scalar(t *testing.T) {
+	for v, want := range map[any]string{
+		"plain":     "plain\n",
+		"":          "\"\"\n",
+		"a: b":      "\"a: b\"\n",
+		"line\ntwo": "\"line\\ntwo\"\n",
+		"8.3":       "\"8.3\"\n",
+		42:          "42\n",
+	} {
+		got, err := MarshalYAML(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("MarshalYAML(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
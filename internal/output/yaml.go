@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// field is one key of a decoded JSON object, kept in document order.
+type field struct {
+	key   string
+	value any
+}
+
+// MarshalYAML renders v as YAML. v is first encoded as JSON, so struct
+// tags and field order are the same in both formats.
+func MarshalYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if isScalar(node) {
+		b.WriteString(scalar(node))
+		b.WriteByte('\n')
+	} else {
+		writeNode(&b, node, 0, false)
+	}
+	return b.Bytes(), nil
+}
+
+// decodeNode reads one JSON value into []field, []any or a scalar.
+func decodeNode(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := []field{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{k.(string), v})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// isScalar reports whether v fits on the same line as its key.
+func isScalar(v any) bool {
+	switch n := v.(type) {
+	case []field:
+		return len(n) == 0
+	case []any:
+		return len(n) == 0
+	}
+	return true
+}
+
+// writeNode writes a non-empty object or array at indent. With inline set,
+// the first line continues the current one (after "- ").
+func writeNode(b *bytes.Buffer, v any, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	first := true
+	line := func() {
+		if !(first && inline) {
+			b.WriteString(pad)
+		}
+		first = false
+	}
+
+	switch n := v.(type) {
+	case []field:
+		for _, f := range n {
+			line()
+			b.WriteString(scalar(f.key))
+			b.WriteByte(':')
+			writeChild(b, f.value, indent+2)
+		}
+	case []any:
+		for _, e := range n {
+			line()
+			b.WriteByte('-')
+			if isScalar(e) {
+				b.WriteString(" " + scalar(e) + "\n")
+				continue
+			}
+			b.WriteByte(' ')
+			writeNode(b, e, indent+2, true)
+		}
+	}
+}
+
+func writeChild(b *bytes.Buffer, v any, indent int) {
+	if isScalar(v) {
+		b.WriteString(" " + scalar(v) + "\n")
+		return
+	}
+	b.WriteByte('\n')
+	writeNode(b, v, indent, false)
+}
+
+// plainSafe matches strings that YAML reads back unchanged without quotes.
+var plainSafe = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_ ./@()+,=-]*$`)
+
+// yamlKeywords would be read back as booleans or null if left unquoted.
+var yamlKeywords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// scalar renders a JSON scalar or empty container as YAML.
+func scalar(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if n {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return n.String()
+	case []field:
+		return "{}"
+	case []any:
+		return "[]"
+	case string:
+		if plainSafe.MatchString(n) && !strings.HasSuffix(n, " ") && !yamlKeywords[strings.ToLower(n)] {
+			return n
+		}
+		// A JSON string is a valid YAML double-quoted scalar.
+		q, _ := json.Marshal(n)
+		return string(q)
+	}
+	return ""
+}
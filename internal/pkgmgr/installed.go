@@ -0,0 +1,70 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yansircc/locwp/internal/config"
+)
+
+// Installed is a group of packages setup installed for one dependency.
+// Packages the user had before aren't recorded, so uninstall leaves them.
+type Installed struct {
+	// Name is the dependency, as setup reports it: "php 8.3", "caddy" or
+	// "wp-cli".
+	Name     string   `json:"name"`
+	Manager  string   `json:"manager"`
+	Packages []string `json:"packages"`
+}
+
+// InstalledPath returns the record of installed packages, next to the
+// tools lockfile.
+func InstalledPath() string {
+	return filepath.Join(config.RootDir(), "bin", "packages.json")
+}
+
+// ReadInstalled loads the record; a missing one is empty.
+func ReadInstalled() ([]Installed, error) {
+	data, err := os.ReadFile(InstalledPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var installed []Installed
+	if err := json.Unmarshal(data, &installed); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", InstalledPath(), err)
+	}
+	return installed, nil
+}
+
+// RecordInstalled adds the packages m installed for the dependency name
+// to the record, replacing an earlier entry for it.
+func RecordInstalled(m Manager, name string, pkgs []string) error {
+	installed, err := ReadInstalled()
+	if err != nil {
+		return err
+	}
+	entry := Installed{Name: name, Manager: m.Name(), Packages: pkgs}
+	replaced := false
+	for i, e := range installed {
+		if e.Name == name {
+			installed[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		installed = append(installed, entry)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(InstalledPath()), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(installed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(InstalledPath(), append(data, '\n'), 0644)
+}
@@ -0,0 +1,256 @@
+package pkgmgr
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/tools"
+)
+
+// Homebrew installs versioned php@X.Y formulae and runs user-level
+// services with `brew services`, so nothing needs sudo.
+type Homebrew struct{}
+
+var (
+	brewPrefixOnce sync.Once
+	brewPrefix     string
+)
+
+// HomebrewPrefix returns the Homebrew prefix: $HOMEBREW_PREFIX as set by
+// `brew shellenv`, else what `brew --prefix` reports, else the default for
+// the platform.
+func HomebrewPrefix() string {
+	if p := os.Getenv("HOMEBREW_PREFIX"); p != "" {
+		return p
+	}
+	brewPrefixOnce.Do(func() {
+		brewPrefix = defaultBrewPrefix()
+		if out, err := exec.Output("brew", "--prefix"); err == nil && strings.TrimSpace(out) != "" {
+			brewPrefix = strings.TrimSpace(out)
+		}
+	})
+	return brewPrefix
+}
+
+// defaultBrewPrefix is where Homebrew installs itself by default.
+func defaultBrewPrefix() string {
+	switch {
+	case runtime.GOOS == "linux":
+		return "/home/linuxbrew/.linuxbrew"
+	case runtime.GOARCH == "arm64":
+		return "/opt/homebrew"
+	}
+	return "/usr/local"
+}
+
+// PHPFormulaName returns the Homebrew formula name for a PHP version.
+func PHPFormulaName(version string) string {
+	return "php@" + defaultVersion(version)
+}
+
+func (Homebrew) Name() string                        { return "brew" }
+func (Homebrew) PHPPackages(version string) []string { return []string{PHPFormulaName(version)} }
+func (Homebrew) CaddyPackage() string                { return "caddy" }
+func (Homebrew) WPCLIPackage() string                { return "wp-cli" }
+
+func (Homebrew) InstallCmd(pkgs ...string) string {
+	return "brew install " + strings.Join(pkgs, " ")
+}
+
+func (Homebrew) UninstallCmd(pkgs ...string) string {
+	return "brew uninstall " + strings.Join(pkgs, " ")
+}
+
+// PostInstall links the keg-only php@X.Y formula so `php` (which wp-cli
+// runs) is on PATH.
+func (Homebrew) PostInstall(version string) error {
+	if !exec.CommandExists("php") {
+		_ = exec.Run("brew", "link", "--force", "--overwrite", PHPFormulaName(version))
+	}
+	return nil
+}
+
+func (Homebrew) PHPBin(version string) string {
+	return filepath.Join(HomebrewPrefix(), "opt", PHPFormulaName(version), "bin", "php")
+}
+
+func (Homebrew) FPMPoolDir(version string) string {
+	return filepath.Join(HomebrewPrefix(), "etc", "php", defaultVersion(version), "php-fpm.d")
+}
+
+func (Homebrew) PHPConfDirs(version string) []string {
+	return []string{filepath.Join(HomebrewPrefix(), "etc", "php", defaultVersion(version), "conf.d")}
+}
+
+func (Homebrew) PHPService(version string) string { return PHPFormulaName(version) }
+func (Homebrew) CaddyService() string             { return "caddy" }
+
+func (Homebrew) ServiceCmd(action, service string) string {
+	return "brew services " + action + " " + service
+}
+
+func (Homebrew) Caddyfile() string {
+	return filepath.Join(HomebrewPrefix(), "etc", "Caddyfile")
+}
+
+// Apt targets Debian and Ubuntu with versioned phpX.Y-* packages (as
+// shipped by the distribution or the ondrej/php repository).
+//
+// On Linux, the distribution's Caddy service runs as the caddy user, which
+// can neither reach the FPM sockets in the user's private runtime dir nor
+// read sites under $HOME, and its Caddyfile is root-owned. The Linux
+// managers therefore have no Caddyfile: locwp runs its own Caddy instance
+// as the user, with the packaged binary.
+type Apt struct{}
+
+func (Apt) Name() string { return "apt" }
+
+func (Apt) PHPPackages(version string) []string {
+	v := "php" + defaultVersion(version)
+	return []string{v + "-fpm", v + "-cli", v + "-sqlite3", v + "-xml", v + "-mbstring", v + "-curl", v + "-zip", v + "-gd"}
+}
+
+func (Apt) CaddyPackage() string { return "caddy" }
+
+// WPCLIPackage is empty: Debian doesn't package WP-CLI.
+func (Apt) WPCLIPackage() string { return "" }
+
+func (Apt) InstallCmd(pkgs ...string) string {
+	return "sudo apt-get install -y " + strings.Join(pkgs, " ")
+}
+
+func (Apt) UninstallCmd(pkgs ...string) string {
+	return "sudo apt-get remove -y " + strings.Join(pkgs, " ")
+}
+
+func (Apt) PostInstall(string) error { return nil }
+
+func (Apt) PHPBin(version string) string {
+	return "/usr/bin/php" + defaultVersion(version)
+}
+
+func (Apt) FPMPoolDir(version string) string {
+	return filepath.Join("/etc/php", defaultVersion(version), "fpm", "pool.d")
+}
+
+func (Apt) PHPConfDirs(version string) []string {
+	dir := filepath.Join("/etc/php", defaultVersion(version))
+	return []string{filepath.Join(dir, "fpm", "conf.d"), filepath.Join(dir, "cli", "conf.d")}
+}
+
+func (Apt) PHPService(version string) string         { return "php" + defaultVersion(version) + "-fpm" }
+func (Apt) CaddyService() string                     { return "caddy" }
+func (Apt) ServiceCmd(action, service string) string { return systemctl(action, service) }
+func (Apt) Caddyfile() string                        { return "" }
+
+// Dnf targets Fedora and RHEL, which ship a single PHP version; the
+// version argument is ignored.
+type Dnf struct{}
+
+func (Dnf) Name() string { return "dnf" }
+
+func (Dnf) PHPPackages(string) []string {
+	return []string{"php-fpm", "php-cli", "php-pdo", "php-xml", "php-mbstring", "php-gd"}
+}
+
+func (Dnf) CaddyPackage() string { return "caddy" }
+func (Dnf) WPCLIPackage() string { return "wp-cli" }
+
+func (Dnf) InstallCmd(pkgs ...string) string {
+	return "sudo dnf install -y " + strings.Join(pkgs, " ")
+}
+
+func (Dnf) UninstallCmd(pkgs ...string) string {
+	return "sudo dnf remove -y " + strings.Join(pkgs, " ")
+}
+
+func (Dnf) PostInstall(string) error                 { return nil }
+func (Dnf) PHPBin(string) string                     { return "/usr/bin/php" }
+func (Dnf) FPMPoolDir(string) string                 { return "/etc/php-fpm.d" }
+func (Dnf) PHPConfDirs(string) []string              { return []string{"/etc/php.d"} }
+func (Dnf) PHPService(string) string                 { return "php-fpm" }
+func (Dnf) CaddyService() string                     { return "caddy" }
+func (Dnf) ServiceCmd(action, service string) string { return systemctl(action, service) }
+func (Dnf) Caddyfile() string                        { return "" }
+
+// Pacman targets Arch Linux, which ships a single PHP version; the
+// version argument is ignored.
+type Pacman struct{}
+
+func (Pacman) Name() string { return "pacman" }
+
+func (Pacman) PHPPackages(string) []string {
+	return []string{"php", "php-fpm", "php-sqlite", "php-gd"}
+}
+
+func (Pacman) CaddyPackage() string { return "caddy" }
+func (Pacman) WPCLIPackage() string { return "wp-cli" }
+
+func (Pacman) InstallCmd(pkgs ...string) string {
+	return "sudo pacman -S --needed --noconfirm " + strings.Join(pkgs, " ")
+}
+
+func (Pacman) UninstallCmd(pkgs ...string) string {
+	return "sudo pacman -R --noconfirm " + strings.Join(pkgs, " ")
+}
+
+func (Pacman) PostInstall(string) error                 { return nil }
+func (Pacman) PHPBin(string) string                     { return "/usr/bin/php" }
+func (Pacman) FPMPoolDir(string) string                 { return "/etc/php/php-fpm.d" }
+func (Pacman) PHPConfDirs(string) []string              { return []string{"/etc/php/conf.d"} }
+func (Pacman) PHPService(string) string                 { return "php-fpm" }
+func (Pacman) CaddyService() string                     { return "caddy" }
+func (Pacman) ServiceCmd(action, service string) string { return systemctl(action, service) }
+func (Pacman) Caddyfile() string                        { return "" }
+
+// Manual uses prebuilt binaries found on PATH and keeps all config under
+// LOCWP_HOME. Point php-fpm at it with `include=<LOCWP_HOME>/php-fpm.d/*.conf`
+// and PHP_INI_SCAN_DIR=<LOCWP_HOME>/php-conf.d.
+type Manual struct{}
+
+func (Manual) Name() string                  { return "manual" }
+func (Manual) PHPPackages(string) []string   { return nil }
+func (Manual) CaddyPackage() string          { return "" }
+func (Manual) WPCLIPackage() string          { return "" }
+func (Manual) InstallCmd(...string) string   { return "" }
+func (Manual) UninstallCmd(...string) string { return "" }
+func (Manual) PostInstall(string) error      { return nil }
+
+// PHPBin prefers a versioned binary (php8.3) over plain php.
+func (Manual) PHPBin(version string) string {
+	for _, name := range []string{"php" + defaultVersion(version), "php"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return "php"
+}
+
+func (Manual) FPMPoolDir(string) string {
+	return filepath.Join(config.BaseDir(), "php-fpm.d")
+}
+
+func (Manual) PHPConfDirs(string) []string {
+	return []string{filepath.Join(config.BaseDir(), "php-conf.d")}
+}
+
+func (Manual) PHPService(string) string { return "php-fpm" }
+func (Manual) CaddyService() string     { return "caddy" }
+
+// ServiceCmd signals a running php-fpm to reload its pools (USR2), and
+// runs Caddy as a standalone instance.
+func (m Manual) ServiceCmd(action, service string) string {
+	if service == "caddy" {
+		return CaddyInstanceCmd(tools.CaddyBin(), m.Caddyfile(), "", filepath.Join(config.BaseDir(), "caddy.pid"))
+	}
+	return "pkill -USR2 -x php-fpm || echo 'php-fpm is not running; start it yourself' >&2"
+}
+
+func (Manual) Caddyfile() string {
+	return filepath.Join(config.BaseDir(), "Caddyfile")
+}
@@ -0,0 +1,122 @@
+// Package pkgmgr abstracts the system package manager that provides PHP,
+// Caddy and WP-CLI: package names, binary and config locations, and how
+// services are started.
+package pkgmgr
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+)
+
+// Manager knows how one package manager names, installs and runs the
+// packages locwp needs.
+type Manager interface {
+	// Name identifies the manager, as accepted by LOCWP_PKG.
+	Name() string
+
+	// PHPPackages returns the packages providing PHP version with FPM and
+	// pdo_sqlite.
+	PHPPackages(version string) []string
+	// CaddyPackage and WPCLIPackage return "" when the manager doesn't
+	// package the tool.
+	CaddyPackage() string
+	WPCLIPackage() string
+	// InstallCmd returns the shell command installing pkgs, or "" if this
+	// manager can't install anything.
+	InstallCmd(pkgs ...string) string
+	// UninstallCmd returns the shell command removing pkgs, or "".
+	UninstallCmd(pkgs ...string) string
+	// PostInstall runs after PHP is installed.
+	PostInstall(version string) error
+
+	PHPBin(version string) string
+	// FPMPoolDir is where per-site pool configs go for a PHP version.
+	FPMPoolDir(version string) string
+	// PHPConfDirs are the conf.d directories PHP-FPM and the CLI scan.
+	PHPConfDirs(version string) []string
+
+	PHPService(version string) string
+	CaddyService() string
+	// ServiceCmd returns the shell command to start or restart a service.
+	ServiceCmd(action, service string) string
+	// Caddyfile is the main Caddyfile read by the Caddy service, or ""
+	// when locwp must run its own Caddy instance as the user instead.
+	Caddyfile() string
+}
+
+// Default returns the manager selected by LOCWP_PKG, or the first one
+// available on this machine, falling back to Manual.
+func Default() Manager {
+	if m, err := ByName(os.Getenv("LOCWP_PKG")); err == nil {
+		return m
+	}
+	switch {
+	case exec.CommandExists("brew"):
+		return Homebrew{}
+	case runtime.GOOS != "linux":
+		return Manual{}
+	case exec.CommandExists("apt-get"):
+		return Apt{}
+	case exec.CommandExists("dnf"):
+		return Dnf{}
+	case exec.CommandExists("pacman"):
+		return Pacman{}
+	}
+	return Manual{}
+}
+
+// ByName returns the manager called name.
+func ByName(name string) (Manager, error) {
+	for _, m := range []Manager{Homebrew{}, Apt{}, Dnf{}, Pacman{}, Manual{}} {
+		if m.Name() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown package manager %q (want brew, apt, dnf, pacman or manual)", name)
+}
+
+// Install installs pkgs with m.
+func Install(m Manager, pkgs ...string) error {
+	cmd := m.InstallCmd(pkgs...)
+	if cmd == "" {
+		return fmt.Errorf("%s cannot install packages; install %s yourself", m.Name(), strings.Join(pkgs, ", "))
+	}
+	return exec.Run("sh", "-c", cmd)
+}
+
+// Uninstall removes pkgs with m.
+func Uninstall(m Manager, pkgs ...string) error {
+	cmd := m.UninstallCmd(pkgs...)
+	if cmd == "" {
+		return fmt.Errorf("%s cannot remove packages", m.Name())
+	}
+	return exec.Run("sh", "-c", cmd)
+}
+
+// CaddyInstanceCmd returns the shell command that reloads a standalone
+// Caddy (the binary bin) on admin, starting it from caddyfile if it isn't
+// running.
+func CaddyInstanceCmd(bin, caddyfile, admin, pidfile string) string {
+	reload := bin + " reload --config " + caddyfile + " --adapter caddyfile"
+	if admin != "" {
+		reload += " --address " + admin
+	}
+	return reload + " || " + bin + " start --config " + caddyfile + " --adapter caddyfile --pidfile " + pidfile
+}
+
+// systemctl returns the command controlling a systemd system service.
+func systemctl(action, service string) string {
+	return "sudo systemctl " + action + " " + service
+}
+
+func defaultVersion(version string) string {
+	if version == "" {
+		return config.DefaultPHP
+	}
+	return version
+}
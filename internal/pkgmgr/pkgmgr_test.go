@@ -0,0 +1,160 @@
+package pkgmgr
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yansircc/locwp/internal/exec"
+)
+
+func TestPHPFormulaName(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"8.3", "php@8.3"},
+		{"8.2", "php@8.2"},
+		{"8.1", "php@8.1"},
+		{"", "php@8.3"}, // default
+	}
+	for _, tt := range tests {
+		got := PHPFormulaName(tt.version)
+		if got != tt.want {
+			t.Errorf("PHPFormulaName(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestHomebrewPrefix(t *testing.T) {
+	t.Setenv("HOMEBREW_PREFIX", "/home/linuxbrew/.linuxbrew")
+	if got := HomebrewPrefix(); got != "/home/linuxbrew/.linuxbrew" {
+		t.Errorf("HomebrewPrefix() = %q, want $HOMEBREW_PREFIX", got)
+	}
+
+	t.Setenv("HOMEBREW_PREFIX", "")
+	want := "/usr/local"
+	switch {
+	case runtime.GOOS == "linux":
+		want = "/home/linuxbrew/.linuxbrew"
+	case runtime.GOARCH == "arm64":
+		want = "/opt/homebrew"
+	}
+	if exec.CommandExists("brew") {
+		t.Skip("brew is installed; HomebrewPrefix() asks it")
+	}
+	if got := HomebrewPrefix(); got != want {
+		t.Errorf("HomebrewPrefix() = %q on %s/%s, want %q", got, runtime.GOOS, runtime.GOARCH, want)
+	}
+}
+
+func TestHomebrew(t *testing.T) {
+	prefix := HomebrewPrefix()
+	m := Homebrew{}
+	if got, want := m.FPMPoolDir("8.2"), filepath.Join(prefix, "etc", "php", "8.2", "php-fpm.d"); got != want {
+		t.Errorf("FPMPoolDir(\"8.2\") = %q, want %q", got, want)
+	}
+	if got, want := m.PHPConfDirs("8.3"), filepath.Join(prefix, "etc", "php", "8.3", "conf.d"); len(got) != 1 || got[0] != want {
+		t.Errorf("PHPConfDirs(\"8.3\") = %q, want [%q]", got, want)
+	}
+	if got, want := m.PHPBin("8.3"), filepath.Join(prefix, "opt", "php@8.3", "bin", "php"); got != want {
+		t.Errorf("PHPBin(\"8.3\") = %q, want %q", got, want)
+	}
+	if got := m.ServiceCmd("restart", m.PHPService("8.3")); got != "brew services restart php@8.3" {
+		t.Errorf("ServiceCmd() = %q", got)
+	}
+}
+
+func TestApt(t *testing.T) {
+	m := Apt{}
+	if got := m.FPMPoolDir("8.2"); got != "/etc/php/8.2/fpm/pool.d" {
+		t.Errorf("FPMPoolDir() = %q", got)
+	}
+	if got := m.PHPBin("8.2"); got != "/usr/bin/php8.2" {
+		t.Errorf("PHPBin() = %q", got)
+	}
+	pkgs := strings.Join(m.PHPPackages("8.2"), " ")
+	if !strings.Contains(pkgs, "php8.2-fpm") || !strings.Contains(pkgs, "php8.2-sqlite3") {
+		t.Errorf("PHPPackages() = %q, want fpm and sqlite3", pkgs)
+	}
+	if got := m.ServiceCmd("restart", m.PHPService("8.2")); got != "sudo systemctl restart php8.2-fpm" {
+		t.Errorf("ServiceCmd() = %q", got)
+	}
+	if len(m.PHPConfDirs("8.2")) != 2 {
+		t.Errorf("PHPConfDirs() = %q, want FPM and CLI conf.d", m.PHPConfDirs("8.2"))
+	}
+	// The caddy user can't reach the user's sockets and sites.
+	if got := m.Caddyfile(); got != "" {
+		t.Errorf("Caddyfile() = %q, want locwp's own instance", got)
+	}
+}
+
+func TestManual(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	m := Manual{}
+	if got := m.FPMPoolDir("8.3"); got != filepath.Join(home, "php-fpm.d") {
+		t.Errorf("FPMPoolDir() = %q", got)
+	}
+	if m.InstallCmd("php") != "" {
+		t.Error("Manual should not install packages")
+	}
+	if err := Install(m, "php"); err == nil {
+		t.Error("Install() with Manual should fail")
+	}
+	if got := m.ServiceCmd("restart", m.CaddyService()); !strings.Contains(got, "--config "+filepath.Join(home, "Caddyfile")) {
+		t.Errorf("caddy ServiceCmd() = %q, want a standalone instance", got)
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"brew", "apt", "dnf", "pacman", "manual"} {
+		m, err := ByName(name)
+		if err != nil || m.Name() != name {
+			t.Errorf("ByName(%q) = %v, %v", name, m, err)
+		}
+	}
+	if _, err := ByName("yum"); err == nil {
+		t.Error("ByName(yum) should fail")
+	}
+
+	t.Setenv("LOCWP_PKG", "pacman")
+	if Default().Name() != "pacman" {
+		t.Errorf("Default() with LOCWP_PKG=pacman = %q", Default().Name())
+	}
+}
+
+func TestCaddyInstanceCmd(t *testing.T) {
+	got := CaddyInstanceCmd("caddy", "/x/Caddyfile", "localhost:2020", "/x/caddy.pid")
+	want := "caddy reload --config /x/Caddyfile --adapter caddyfile --address localhost:2020 || caddy start --config /x/Caddyfile --adapter caddyfile --pidfile /x/caddy.pid"
+	if got != want {
+		t.Errorf("CaddyInstanceCmd() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRecordInstalled(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+
+	if got, err := ReadInstalled(); err != nil || got != nil {
+		t.Fatalf("ReadInstalled() = %v, %v; want nothing recorded", got, err)
+	}
+	if err := RecordInstalled(Apt{}, "caddy", []string{"caddy"}); err != nil {
+		t.Fatalf("RecordInstalled() error: %v", err)
+	}
+	RecordInstalled(Apt{}, "php 8.2", []string{"php8.2-fpm"})
+	RecordInstalled(Apt{}, "php 8.2", []string{"php8.2-fpm", "php8.2-sqlite3"})
+
+	got, err := ReadInstalled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Installed{
+		{Name: "caddy", Manager: "apt", Packages: []string{"caddy"}},
+		{Name: "php 8.2", Manager: "apt", Packages: []string{"php8.2-fpm", "php8.2-sqlite3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadInstalled() = %+v, want %+v", got, want)
+	}
+}
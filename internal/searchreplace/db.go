@@ -0,0 +1,167 @@
+package searchreplace
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// Options controls a database search-replace.
+type Options struct {
+	DryRun      bool
+	SkipColumns []string
+}
+
+// TableResult reports the changes made (or, in a dry run, that would be
+// made) in one table.
+type TableResult struct {
+	Table        string `json:"table"`
+	Rows         int    `json:"rows"`
+	Replacements int    `json:"replacements"`
+}
+
+// update is one rewritten value, applied once every table is scanned.
+type update struct {
+	table, col string
+	rowid      int64
+	value      string
+}
+
+// Run replaces old with new in every text value of a site's SQLite
+// database. Internal tables of SQLite and of the SQLite database
+// integration plugin (prefixed with "_") are skipped. All updates are
+// written in one transaction.
+func Run(dbPath, old, new string, opts Options) ([]TableResult, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("database not found: %w", err)
+	}
+	// WordPress may be writing to the database; wait for its locks.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tables, err := tables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		results []TableResult
+		updates []update
+	)
+	for _, table := range tables {
+		res, err := replaceTable(db, table, old, new, opts, &updates)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	if opts.DryRun || len(updates) == 0 {
+		return results, nil
+	}
+	if err := apply(db, updates); err != nil {
+		return nil, fmt.Errorf("update %s: %w", dbPath, err)
+	}
+	return results, nil
+}
+
+func replaceTable(db *sql.DB, table, old, new string, opts Options, updates *[]update) (TableResult, error) {
+	res := TableResult{Table: table}
+	cols, err := column(db, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return res, fmt.Errorf("table %s: %w", table, err)
+	}
+
+	changed := map[int64]bool{}
+	for _, col := range cols {
+		if slices.Contains(opts.SkipColumns, col) {
+			continue
+		}
+		var (
+			conds []string
+			args  []any
+		)
+		for _, f := range Forms(old) {
+			conds = append(conds, fmt.Sprintf("instr(%s, ?) > 0", ident(col)))
+			args = append(args, f)
+		}
+		rows, err := db.Query(fmt.Sprintf("SELECT rowid, %s FROM %s WHERE typeof(%s) = 'text' AND (%s)",
+			ident(col), ident(table), ident(col), strings.Join(conds, " OR ")), args...)
+		if err != nil {
+			return res, fmt.Errorf("table %s: %w", table, err)
+		}
+		for rows.Next() {
+			var (
+				id int64
+				v  string
+			)
+			if err := rows.Scan(&id, &v); err != nil {
+				rows.Close()
+				return res, fmt.Errorf("table %s: %w", table, err)
+			}
+			replaced, n := Value(v, old, new)
+			if n == 0 || replaced == v {
+				continue
+			}
+			changed[id] = true
+			res.Replacements += n
+			*updates = append(*updates, update{table: table, col: col, rowid: id, value: replaced})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return res, fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+	res.Rows = len(changed)
+	return res, nil
+}
+
+// apply writes updates in one transaction, so a failure leaves the
+// database as it was.
+func apply(db *sql.DB, updates []update) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range updates {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", ident(u.table), ident(u.col)), u.value, u.rowid); err != nil {
+			return fmt.Errorf("table %s: %w", u.table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func tables(db *sql.DB) ([]string, error) {
+	return column(db, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name NOT LIKE '\_%' ESCAPE '\' ORDER BY name`)
+}
+
+// column runs a query returning one text column.
+func column(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func ident(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
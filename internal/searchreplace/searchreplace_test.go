@@ -0,0 +1,180 @@
+package searchreplace
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	oldURL = "http://old.example.com"
+	newURL = "http://localhost:10001"
+)
+
+func TestValue_Plain(t *testing.T) {
+	got, n := Value("see http://old.example.com/a and http://old.example.com/b", oldURL, newURL)
+	want := "see http://localhost:10001/a and http://localhost:10001/b"
+	if got != want || n != 2 {
+		t.Errorf("Value() = %q, %d; want %q, 2", got, n, want)
+	}
+}
+
+func TestValue_Serialized(t *testing.T) {
+	in := `a:2:{s:3:"url";s:27:"http://old.example.com/page";s:4:"keep";i:5;}`
+	want := `a:2:{s:3:"url";s:27:"http://localhost:10001/page";s:4:"keep";i:5;}`
+	got, n := Value(in, oldURL, newURL)
+	if got != want || n != 1 {
+		t.Errorf("Value() = %q, %d; want %q, 1", got, n, want)
+	}
+
+	// Length prefix must follow the new byte length.
+	got, _ = Value(`s:22:"http://old.example.com";`, oldURL, "https://example.test")
+	if got != `s:20:"https://example.test";` {
+		t.Errorf("Value() = %q, want updated length prefix", got)
+	}
+}
+
+func TestValue_NestedSerialized(t *testing.T) {
+	inner := `a:1:{i:0;s:22:"http://old.example.com";}`
+	in := `O:8:"stdClass":1:{s:4:"data";s:` + strconv.Itoa(len(inner)) + `:"` + inner + `";}`
+	got, n := Value(in, oldURL, newURL)
+	if n != 1 {
+		t.Fatalf("Value() made %d replacements, want 1: %q", n, got)
+	}
+	newInner := `a:1:{i:0;s:22:"http://localhost:10001";}`
+	want := `O:8:"stdClass":1:{s:4:"data";s:` + strconv.Itoa(len(newInner)) + `:"` + newInner + `";}`
+	if got != want {
+		t.Errorf("Value() = %q, want %q", got, want)
+	}
+}
+
+func TestValue_SerializedKeysUntouched(t *testing.T) {
+	in := `a:1:{s:22:"http://old.example.com";b:1;}`
+	if got, n := Value(in, oldURL, newURL); got != in || n != 0 {
+		t.Errorf("Value() = %q, %d; array keys should be kept", got, n)
+	}
+}
+
+func TestValue_BrokenSerializedFallsBackToPlain(t *testing.T) {
+	in := `s:99:"http://old.example.com";`
+	got, n := Value(in, oldURL, newURL)
+	if n != 1 || !strings.Contains(got, newURL) {
+		t.Errorf("Value() = %q, %d; want plain replacement", got, n)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	in := `{"home":"http:\/\/old.example.com\/","api":"http://old.example.com/wp-json"}`
+	want := `{"home":"http:\/\/localhost:10001\/","api":"http://localhost:10001/wp-json"}`
+	got, n := Value(in, oldURL, newURL)
+	if got != want || n != 2 {
+		t.Errorf("Value() = %q, %d; want %q, 2", got, n, want)
+	}
+}
+
+func TestForms(t *testing.T) {
+	if !slices.Contains(Forms(oldURL), `http:\/\/old.example.com`) {
+		t.Errorf("Forms() = %q, missing slash-escaped JSON form", Forms(oldURL))
+	}
+}
+
+// testDB creates a SQLite database from statements.
+func testDB(t *testing.T, stmts ...string) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".ht.sqlite")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+// dbPath returns the file behind db.
+func dbPath(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var seq int
+	var name, file string
+	if err := db.QueryRow("PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRun(t *testing.T) {
+	db := testDB(t,
+		`CREATE TABLE wp_options (option_id INTEGER PRIMARY KEY, option_name TEXT, option_value TEXT)`,
+		`INSERT INTO wp_options VALUES (1, 'siteurl', 'http://old.example.com')`,
+		`INSERT INTO wp_options VALUES (2, 'widget', 'a:1:{i:0;s:27:"http://old.example.com/page";}')`,
+		`INSERT INTO wp_options VALUES (3, 'blogname', 'It''s a site')`,
+		`CREATE TABLE wp_posts (ID INTEGER PRIMARY KEY, guid TEXT, post_content TEXT)`,
+		`INSERT INTO wp_posts VALUES (1, 'http://old.example.com/?p=1', 'Link: http://old.example.com/about')`,
+		`CREATE TABLE _mysql_data_types_cache (t TEXT)`,
+		`INSERT INTO _mysql_data_types_cache VALUES ('http://old.example.com')`,
+	)
+	path := dbPath(t, db)
+
+	dry, err := Run(path, oldURL, newURL, Options{DryRun: true, SkipColumns: []string{"guid"}})
+	if err != nil {
+		t.Fatalf("Run(dry) error: %v", err)
+	}
+	if len(dry) != 2 {
+		t.Fatalf("Run(dry) returned %d tables, want 2 (internal tables skipped): %+v", len(dry), dry)
+	}
+	if dry[0].Table != "wp_options" || dry[0].Rows != 2 || dry[0].Replacements != 2 {
+		t.Errorf("wp_options result = %+v, want 2 rows / 2 replacements", dry[0])
+	}
+	var v string
+	db.QueryRow("SELECT option_value FROM wp_options WHERE option_id = 1").Scan(&v)
+	if v != oldURL {
+		t.Errorf("dry run modified the database: %s", v)
+	}
+
+	if _, err := Run(path, oldURL, newURL, Options{SkipColumns: []string{"guid"}}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	var got []string
+	for _, q := range []string{"SELECT option_value FROM wp_options ORDER BY option_id", "SELECT guid FROM wp_posts"} {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			rows.Scan(&v)
+			got = append(got, v)
+		}
+		rows.Close()
+	}
+	want := []string{newURL, `a:1:{i:0;s:27:"http://localhost:10001/page";}`, "It's a site", "http://old.example.com/?p=1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("after Run():\n%q\nwant:\n%q", got, want)
+	}
+}
+
+// Serialized objects with protected properties contain NUL bytes, which
+// must survive the round trip.
+func TestRun_NULBytes(t *testing.T) {
+	old := "O:8:\"stdClass\":1:{s:6:\"\x00*\x00url\";s:22:\"http://old.example.com\";}"
+	db := testDB(t, `CREATE TABLE wp_options (option_id INTEGER PRIMARY KEY, option_value TEXT)`)
+	if _, err := db.Exec("INSERT INTO wp_options VALUES (1, ?)", old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Run(dbPath(t, db), oldURL, newURL, Options{}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	var got string
+	db.QueryRow("SELECT option_value FROM wp_options").Scan(&got)
+	want := "O:8:\"stdClass\":1:{s:6:\"\x00*\x00url\";s:22:\"http://localhost:10001\";}"
+	if got != want {
+		t.Errorf("after Run() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,256 @@
+package searchreplace
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errSyntax = errors.New("invalid serialized data")
+
+// Value replaces old with new in a single database value and returns the
+// result and the number of replacements. PHP-serialized data is parsed and
+// rebuilt so string length prefixes stay correct; JSON documents are
+// rewritten in both their plain and slash-escaped forms. Anything else is
+// treated as plain text.
+func Value(s, old, new string) (string, int) {
+	if old == "" || old == new {
+		return s, 0
+	}
+	if looksSerialized(s) {
+		p := &parser{s: s, old: old, new: new}
+		var out strings.Builder
+		if err := p.value(&out, true); err == nil && p.pos == len(s) {
+			return out.String(), p.count
+		}
+	}
+	if looksJSON(s) {
+		return replaceJSON(s, old, new)
+	}
+	return strings.ReplaceAll(s, old, new), strings.Count(s, old)
+}
+
+// Forms returns the encodings of old that can appear in stored values:
+// the raw string and its JSON-escaped variants.
+func Forms(old string) []string {
+	esc := jsonEscape(old)
+	forms := []string{old}
+	for _, f := range []string{esc, strings.ReplaceAll(esc, "/", `\/`)} {
+		if !slices.Contains(forms, f) {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+func looksSerialized(s string) bool {
+	if s == "N;" {
+		return true
+	}
+	if len(s) < 4 || s[1] != ':' {
+		return false
+	}
+	return strings.IndexByte("aObisdCE", s[0]) >= 0
+}
+
+func looksJSON(s string) bool {
+	t := strings.TrimSpace(s)
+	if t == "" || (t[0] != '{' && t[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(t))
+}
+
+// replaceJSON rewrites old inside a JSON document. The escaped forms are
+// replaced first so `http:\/\/` style URLs are caught too.
+func replaceJSON(s, old, new string) (string, int) {
+	count := 0
+	oldEsc, newEsc := jsonEscape(old), jsonEscape(new)
+	pairs := [][2]string{
+		{strings.ReplaceAll(oldEsc, "/", `\/`), strings.ReplaceAll(newEsc, "/", `\/`)},
+		{oldEsc, newEsc},
+	}
+	for _, p := range pairs {
+		count += strings.Count(s, p[0])
+		s = strings.ReplaceAll(s, p[0], p[1])
+	}
+	return s, count
+}
+
+func jsonEscape(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	out := strings.TrimSuffix(b.String(), "\n")
+	return out[1 : len(out)-1]
+}
+
+// parser rebuilds PHP-serialized data, rewriting string values.
+type parser struct {
+	s        string
+	pos      int
+	old, new string
+	count    int
+}
+
+// value copies one serialized value to out. Strings are rewritten when
+// replace is true; array keys and property names are copied verbatim.
+func (p *parser) value(out *strings.Builder, replace bool) error {
+	if p.pos+1 >= len(p.s) {
+		return errSyntax
+	}
+	switch p.s[p.pos] {
+	case 'N':
+		return p.copyThrough(out, ';')
+	case 'b', 'i', 'd', 'r', 'R':
+		return p.copyThrough(out, ';')
+	case 's':
+		str, err := p.str()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+		if replace {
+			var n int
+			str, n = Value(str, p.old, p.new)
+			p.count += n
+		}
+		out.WriteString("s:" + strconv.Itoa(len(str)) + `:"` + str + `";`)
+		return nil
+	case 'a':
+		p.pos += 2
+		n, err := p.int(':')
+		if err != nil {
+			return err
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		out.WriteString("a:" + strconv.Itoa(n) + ":{")
+		return p.members(out, n)
+	case 'O':
+		class, err := p.str()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		n, err := p.int(':')
+		if err != nil {
+			return err
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		out.WriteString("O:" + strconv.Itoa(len(class)) + `:"` + class + `":` + strconv.Itoa(n) + ":{")
+		return p.members(out, n)
+	case 'E':
+		// Enum case: E:len:"Class:Case"; copied verbatim.
+		start := p.pos
+		if _, err := p.str(); err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+		out.WriteString(p.s[start:p.pos])
+		return nil
+	case 'C':
+		// Custom serialization is opaque; copy it verbatim.
+		start := p.pos
+		if _, err := p.str(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		n, err := p.int(':')
+		if err != nil {
+			return err
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		if p.pos+n+1 > len(p.s) || p.s[p.pos+n] != '}' {
+			return errSyntax
+		}
+		p.pos += n + 1
+		out.WriteString(p.s[start:p.pos])
+		return nil
+	}
+	return errSyntax
+}
+
+// members copies n key/value pairs and the closing brace.
+func (p *parser) members(out *strings.Builder, n int) error {
+	for i := 0; i < n; i++ {
+		if err := p.value(out, false); err != nil {
+			return err
+		}
+		if err := p.value(out, true); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("}"); err != nil {
+		return err
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// str parses `x:len:"bytes"` at the current position, where x is any type
+// letter, and returns the bytes.
+func (p *parser) str() (string, error) {
+	p.pos += 2
+	n, err := p.int(':')
+	if err != nil {
+		return "", err
+	}
+	if err := p.expect(`"`); err != nil {
+		return "", err
+	}
+	if n < 0 || p.pos+n+1 > len(p.s) || p.s[p.pos+n] != '"' {
+		return "", errSyntax
+	}
+	str := p.s[p.pos : p.pos+n]
+	p.pos += n + 1
+	return str, nil
+}
+
+// int parses a decimal integer terminated by end and consumes end.
+func (p *parser) int(end byte) (int, error) {
+	i := strings.IndexByte(p.s[p.pos:], end)
+	if i <= 0 {
+		return 0, errSyntax
+	}
+	n, err := strconv.Atoi(p.s[p.pos : p.pos+i])
+	if err != nil {
+		return 0, errSyntax
+	}
+	p.pos += i + 1
+	return n, nil
+}
+
+func (p *parser) expect(tok string) error {
+	if !strings.HasPrefix(p.s[p.pos:], tok) {
+		return errSyntax
+	}
+	p.pos += len(tok)
+	return nil
+}
+
+func (p *parser) copyThrough(out *strings.Builder, end byte) error {
+	i := strings.IndexByte(p.s[p.pos:], end)
+	if i < 0 {
+		return errSyntax
+	}
+	out.WriteString(p.s[p.pos : p.pos+i+1])
+	p.pos += i + 1
+	return nil
+}
@@ -0,0 +1,323 @@
+// Package secrets stores site credentials outside config.json: in the OS
+// keyring where one is available, otherwise in a 0600 file.
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+)
+
+// ErrNotFound is returned by Get when no secret is stored under a key.
+var ErrNotFound = errors.New("secret not found")
+
+// service is the keyring service name all locwp secrets are filed under.
+const service = "locwp"
+
+// Store is a key/value store for secrets.
+type Store interface {
+	Name() string
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// Default returns the store for this machine: the macOS keychain, the
+// Secret Service via secret-tool on Linux, or the secrets file. Keyring
+// stores fall back to the file when the keyring is unavailable (e.g. no
+// D-Bus session). LOCWP_SECRETS=file|keychain|secret-tool overrides the
+// choice.
+func Default() Store {
+	file := NewFile(filepath.Join(config.BaseDir(), "secrets.json"))
+	switch os.Getenv("LOCWP_SECRETS") {
+	case "file":
+		return file
+	case "keychain":
+		return &fallback{Keychain{}, file}
+	case "secret-tool":
+		return &fallback{SecretTool{}, file}
+	}
+	switch {
+	case runtime.GOOS == "darwin" && exec.CommandExists("security"):
+		return &fallback{Keychain{}, file}
+	case runtime.GOOS == "linux" && exec.CommandExists("secret-tool") && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "":
+		return &fallback{SecretTool{}, file}
+	}
+	return file
+}
+
+// passwordChars avoids characters that need quoting in shells and URLs.
+const passwordChars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GeneratePassword returns a random password of n characters.
+func GeneratePassword(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range b {
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordChars[c.Int64()]
+	}
+	return string(b), nil
+}
+
+// File stores secrets as a JSON object in a file readable only by its owner.
+// Updates hold a lock on path.lock, so concurrent commands don't lose each
+// other's writes.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+var (
+	filesMu sync.Mutex
+	files   = map[string]*File{}
+)
+
+// NewFile returns the file store at path, the same one for every call with
+// that path. The file is created on first Set.
+func NewFile(path string) *File {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	f, ok := files[path]
+	if !ok {
+		f = &File{path: path}
+		files[path] = f
+	}
+	return f
+}
+
+func (f *File) Name() string { return "file " + f.path }
+
+// lock serializes access to the file within this process and, through a
+// lock file, with other locwp processes. The returned func releases it.
+func (f *File) lock() (func(), error) {
+	f.mu.Lock()
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	lf, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(lf.Fd()), syscall.LOCK_EX); err != nil {
+		lf.Close()
+		f.mu.Unlock()
+		return nil, fmt.Errorf("lock %s: %w", f.path, err)
+	}
+	return func() {
+		syscall.Flock(int(lf.Fd()), syscall.LOCK_UN)
+		lf.Close()
+		f.mu.Unlock()
+	}, nil
+}
+
+func (f *File) load() (map[string]string, error) {
+	m := map[string]string{}
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+	return m, nil
+}
+
+func (f *File) save(m map[string]string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600.
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// update applies fn to the stored secrets under the lock and saves them
+// when fn reports a change.
+func (f *File) update(fn func(m map[string]string) bool) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	m, err := f.load()
+	if err != nil {
+		return err
+	}
+	if !fn(m) {
+		return nil
+	}
+	return f.save(m)
+}
+
+func (f *File) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Writes replace the file by rename, so reads need no file lock.
+	m, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	v, ok := m[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (f *File) Set(key, value string) error {
+	return f.update(func(m map[string]string) bool {
+		m[key] = value
+		return true
+	})
+}
+
+func (f *File) Delete(key string) error {
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		return nil
+	}
+	return f.update(func(m map[string]string) bool {
+		if _, ok := m[key]; !ok {
+			return false
+		}
+		delete(m, key)
+		return true
+	})
+}
+
+// Keychain stores secrets in the macOS login keychain.
+type Keychain struct{}
+
+func (Keychain) Name() string { return "macOS keychain" }
+
+func (Keychain) Get(key string) (string, error) {
+	out, err := exec.Output("security", "find-generic-password", "-s", service, "-a", key, "-w")
+	if err != nil {
+		return "", ErrNotFound
+	}
+	return trimNewline(out), nil
+}
+
+func (k Keychain) Set(key, value string) error {
+	// Arguments of security are visible to other users in ps, so the
+	// command is given to `security -i` on stdin instead.
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("keychain secrets can't contain newlines")
+	}
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", securityQuote(service), securityQuote(key), securityQuote(value))
+	if _, err := exec.OutputWithInput(cmd, "security", "-i"); err != nil {
+		return err
+	}
+	// security -i reports a failed command but still exits 0.
+	if got, err := k.Get(key); err != nil || got != value {
+		return fmt.Errorf("keychain did not store %s", key)
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for the command line `security -i`
+// reads.
+func securityQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (Keychain) Delete(key string) error {
+	_, _ = exec.Output("security", "delete-generic-password", "-s", service, "-a", key)
+	return nil
+}
+
+// SecretTool stores secrets in the freedesktop Secret Service (GNOME
+// Keyring, KWallet) via secret-tool. Values are passed on stdin.
+type SecretTool struct{}
+
+func (SecretTool) Name() string { return "Secret Service" }
+
+func (SecretTool) Get(key string) (string, error) {
+	out, err := exec.Output("secret-tool", "lookup", "service", service, "account", key)
+	if err != nil || out == "" {
+		return "", ErrNotFound
+	}
+	return trimNewline(out), nil
+}
+
+func (SecretTool) Set(key, value string) error {
+	_, err := exec.OutputWithInput(value, "secret-tool", "store", "--label=locwp "+key, "service", service, "account", key)
+	return err
+}
+
+func (SecretTool) Delete(key string) error {
+	_, _ = exec.Output("secret-tool", "clear", "service", service, "account", key)
+	return nil
+}
+
+func trimNewline(s string) string {
+	if n := len(s); n > 0 && s[n-1] == '\n' {
+		return s[:n-1]
+	}
+	return s
+}
+
+// fallback uses a keyring store, falling back to a file store when the
+// keyring fails. Secrets written to the file earlier remain readable.
+type fallback struct {
+	primary Store
+	file    Store
+}
+
+func (s *fallback) Name() string { return s.primary.Name() }
+
+func (s *fallback) Get(key string) (string, error) {
+	if v, err := s.primary.Get(key); err == nil {
+		return v, nil
+	}
+	return s.file.Get(key)
+}
+
+func (s *fallback) Set(key, value string) error {
+	if err := s.primary.Set(key, value); err != nil {
+		return s.file.Set(key, value)
+	}
+	// Don't leave a stale copy behind in the file.
+	return s.file.Delete(key)
+}
+
+func (s *fallback) Delete(key string) error {
+	if err := s.primary.Delete(key); err != nil {
+		return err
+	}
+	return s.file.Delete(key)
+}
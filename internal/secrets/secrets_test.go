@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "home", "secrets.json")
+	f := NewFile(path)
+
+	if _, err := f.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() on empty store error = %v, want ErrNotFound", err)
+	}
+	if err := f.Set("a", "s3cret"); err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	if v, err := f.Get("a"); err != nil || v != "s3cret" {
+		t.Errorf("Get() = %q, %v", v, err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("secrets file mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	if err := f.Delete("a"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := f.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFile_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if NewFile(path) != NewFile(path) {
+		t.Error("NewFile() returned two stores for one path")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := NewFile(path).Set(fmt.Sprint("k", i), "v"); err != nil {
+				t.Errorf("Set() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		if _, err := NewFile(path).Get(fmt.Sprint("k", i)); err != nil {
+			t.Errorf("k%d lost: %v", i, err)
+		}
+	}
+	if tmps, _ := filepath.Glob(path + ".*.tmp"); len(tmps) > 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
+
+// brokenStore fails every operation, like a keyring without a session.
+type brokenStore struct{}
+
+func (brokenStore) Name() string               { return "broken" }
+func (brokenStore) Get(string) (string, error) { return "", errors.New("no keyring") }
+func (brokenStore) Set(string, string) error   { return errors.New("no keyring") }
+func (brokenStore) Delete(string) error        { return nil }
+
+func TestFallback(t *testing.T) {
+	file := NewFile(filepath.Join(t.TempDir(), "secrets.json"))
+	s := &fallback{brokenStore{}, file}
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	if v, err := file.Get("k"); err != nil || v != "v" {
+		t.Errorf("file.Get() = %q, %v; want the value in the fallback file", v, err)
+	}
+	if v, err := s.Get("k"); err != nil || v != "v" {
+		t.Errorf("Get() = %q, %v", v, err)
+	}
+}
+
+func TestDefault_FileOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	t.Setenv("LOCWP_SECRETS", "file")
+
+	s := Default()
+	if !strings.Contains(s.Name(), filepath.Join(home, "secrets.json")) {
+		t.Errorf("Default().Name() = %q, want the file store under LOCWP_HOME", s.Name())
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	a, err := GeneratePassword(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GeneratePassword(20)
+	if len(a) != 20 || a == b {
+		t.Errorf("GeneratePassword() = %q, %q; want two different 20-char passwords", a, b)
+	}
+	if strings.Trim(a, passwordChars) != "" {
+		t.Errorf("GeneratePassword() = %q contains unexpected characters", a)
+	}
+}
+
+func TestSecurityQuote(t *testing.T) {
+	if got := securityQuote(`pa"ss\word`); got != `"pa\"ss\\word"` {
+		t.Errorf("securityQuote() = %s", got)
+	}
+}
@@ -0,0 +1,110 @@
+package site
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Health states reported by Check.
+const (
+	HealthRunning  = "running"
+	HealthDegraded = "degraded"
+	HealthStopped  = "stopped"
+)
+
+// Health is the result of probing a site.
+type Health struct {
+	// State is running, degraded, stopped, provisioning or failed.
+	State string `json:"state"`
+	// Reason explains a degraded or stopped state.
+	Reason       string        `json:"reason,omitempty"`
+	HTTPStatus   int           `json:"http_status,omitempty"`
+	ResponseTime time.Duration `json:"response_time,omitempty"`
+	WPVersion    string        `json:"wp_version,omitempty"`
+}
+
+// httpTimeout bounds each site's HTTP probe. A PHP fatal returns quickly;
+// anything slower than this is as good as down for local development.
+const httpTimeout = 5 * time.Second
+
+var probeClient = &http.Client{
+	Timeout: httpTimeout,
+	// A redirect (e.g. to wp-admin/install.php) still proves PHP works.
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// Check probes a site: its provisioning state, Caddy config, FPM socket,
+// database file and an HTTP request to its home page.
+func Check(sc *Config) Health {
+	var h Health
+	h.WPVersion, _ = WPVersion(sc.WPRoot)
+
+	if sc.State == StateProvisioning || sc.State == StateFailed {
+		h.State = sc.State
+		return h
+	}
+	if !CaddyConfEnabled(sc.Port) {
+		h.State = HealthStopped
+		return h
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", sc.Port), 500*time.Millisecond)
+	if err != nil {
+		h.State = HealthStopped
+		h.Reason = "nothing listening"
+		return h
+	}
+	conn.Close()
+
+	h.State = HealthDegraded
+	if fi, err := os.Stat(sc.SocketPath()); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		h.Reason = "FPM socket missing"
+		return h
+	}
+	if sc.DBType != DBMySQL {
+		if _, err := os.Stat(sc.DBPath()); err != nil {
+			h.Reason = "database file missing"
+			return h
+		}
+	}
+
+	start := time.Now()
+	resp, err := probeClient.Get(sc.URL() + "/")
+	h.ResponseTime = time.Since(start)
+	if err != nil {
+		h.Reason = "no HTTP response"
+		return h
+	}
+	resp.Body.Close()
+	h.HTTPStatus = resp.StatusCode
+	if resp.StatusCode >= 500 {
+		h.Reason = "HTTP " + resp.Status
+		return h
+	}
+
+	h.State = HealthRunning
+	return h
+}
+
+// CheckAll probes sites concurrently with at most workers in flight and
+// returns results in the same order as sites.
+func CheckAll(sites []*Config, workers int) []Health {
+	results := make([]Health, len(sites))
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i, sc := range sites {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			results[i] = Check(sc)
+			<-sem
+		}()
+	}
+	wg.Wait()
+	return results
+}
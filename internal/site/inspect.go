@@ -0,0 +1,94 @@
+package site
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Database types detected in an existing WordPress install.
+const (
+	DBSQLite = "sqlite"
+	DBMySQL  = "mysql"
+)
+
+// Install describes an existing WordPress directory.
+type Install struct {
+	Version string
+	DBType  string
+	DBFile  string // absolute path to the SQLite file, empty for MySQL
+}
+
+var (
+	wpVersionRe = regexp.MustCompile(`\$wp_version\s*=\s*['"]([^'"]+)['"]`)
+	dbDirRe     = regexp.MustCompile(`define\(\s*['"]DB_DIR['"]\s*,\s*['"]([^'"]+)['"]`)
+	dbFileRe    = regexp.MustCompile(`define\(\s*['"]DB_FILE['"]\s*,\s*['"]([^'"]+)['"]`)
+	dbEngineRe  = regexp.MustCompile(`define\(\s*['"]DB_ENGINE['"]\s*,\s*['"]sqlite['"]`)
+)
+
+// WPVersion reads the WordPress version from wp-includes/version.php.
+func WPVersion(wpRoot string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(wpRoot, "wp-includes", "version.php"))
+	if err != nil {
+		return "", err
+	}
+	m := wpVersionRe.FindSubmatch(data)
+	if m == nil {
+		return "", fmt.Errorf("no $wp_version in %s", filepath.Join(wpRoot, "wp-includes", "version.php"))
+	}
+	return string(m[1]), nil
+}
+
+// Inspect detects the WordPress version and database type of an existing install.
+func Inspect(wpRoot string) (*Install, error) {
+	if _, err := os.Stat(filepath.Join(wpRoot, "wp-load.php")); err != nil {
+		return nil, fmt.Errorf("%s is not a WordPress directory (no wp-load.php)", wpRoot)
+	}
+	version, err := WPVersion(wpRoot)
+	if err != nil {
+		return nil, err
+	}
+	inst := &Install{Version: version, DBType: DBMySQL}
+
+	wpConfig, _ := os.ReadFile(filepath.Join(wpRoot, "wp-config.php"))
+	dropIn, _ := os.ReadFile(filepath.Join(wpRoot, "wp-content", "db.php"))
+	if dbEngineRe.Match(wpConfig) || strings.Contains(strings.ToLower(string(dropIn)), "sqlite") {
+		inst.DBType = DBSQLite
+		dir := filepath.Join(wpRoot, "wp-content", "database")
+		if m := dbDirRe.FindSubmatch(wpConfig); m != nil {
+			dir = string(m[1])
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(wpRoot, dir)
+			}
+		}
+		file := ".ht.sqlite"
+		if m := dbFileRe.FindSubmatch(wpConfig); m != nil {
+			file = string(m[1])
+		}
+		inst.DBFile = filepath.Join(dir, file)
+	}
+	return inst, nil
+}
+
+// DiskUsage returns the total size of regular files under root. Symlinks,
+// such as mounted plugins, are not followed.
+func DiskUsage(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
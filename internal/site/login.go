@@ -0,0 +1,72 @@
+package site
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoginParam is the query parameter carrying a one-time login token.
+const LoginParam = "locwp_login"
+
+// loginToken is the on-disk record of an unused login token. The file is
+// named after the token's SHA-256, so the token itself is never stored.
+type loginToken struct {
+	User    string `json:"user"`
+	Expires int64  `json:"expires"`
+}
+
+// LoginTokenDir returns the directory the auto-login mu-plugin reads
+// tokens from.
+func (sc *Config) LoginTokenDir() string {
+	return filepath.Join(sc.SiteDir, "login-tokens")
+}
+
+// NewLoginToken creates a single-use token that logs user in until ttl
+// elapses, and removes expired ones.
+func NewLoginToken(sc *Config, user string, ttl time.Duration) (string, error) {
+	dir := sc.LoginTokenDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	pruneLoginTokens(dir)
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	data, err := json.Marshal(loginToken{User: user, Expires: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(token))
+	if err := os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), data, 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// pruneLoginTokens removes expired and half-consumed tokens.
+func pruneLoginTokens(dir string) {
+	entries, _ := os.ReadDir(dir)
+	now := time.Now().Unix()
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !strings.HasSuffix(e.Name(), ".json") {
+			os.Remove(path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		var t loginToken
+		if err != nil || json.Unmarshal(data, &t) != nil || t.Expires < now {
+			os.Remove(path)
+		}
+	}
+}
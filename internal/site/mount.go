@@ -0,0 +1,113 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Mount types: where a mounted directory is linked under wp-content.
+const (
+	MountPlugin   = "plugin"
+	MountTheme    = "theme"
+	MountMUPlugin = "mu-plugin"
+)
+
+// Mount is a local directory symlinked into a site's wp-content.
+type Mount struct {
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// Name returns the directory name the mount appears under in wp-content.
+func (m Mount) Name() string {
+	return filepath.Base(m.Source)
+}
+
+// MountTarget returns the symlink path for a mount inside the site.
+func (sc *Config) MountTarget(m Mount) string {
+	dir := map[string]string{
+		MountPlugin:   "plugins",
+		MountTheme:    "themes",
+		MountMUPlugin: "mu-plugins",
+	}[m.Type]
+	return filepath.Join(sc.WPRoot, "wp-content", dir, m.Name())
+}
+
+// ValidMountType reports whether t is a known mount type.
+func ValidMountType(t string) bool {
+	return t == MountPlugin || t == MountTheme || t == MountMUPlugin
+}
+
+// ApplyMounts (re)creates the symlinks for all of a site's mounts. It is
+// idempotent and refuses to replace anything that isn't a symlink.
+func ApplyMounts(sc *Config) error {
+	for _, m := range sc.Mounts {
+		if err := applyMount(sc, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMount deletes a mount's symlink and loader, leaving the source alone.
+func RemoveMount(sc *Config, m Mount) error {
+	target := sc.MountTarget(m)
+	if fi, err := os.Lstat(target); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s is not a symlink, not removing it", target)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(muLoaderPath(sc, m)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func applyMount(sc *Config, m Mount) error {
+	src, err := os.Stat(m.Source)
+	if err != nil {
+		return fmt.Errorf("mount source: %w", err)
+	}
+	target := sc.MountTarget(m)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if fi, err := os.Lstat(target); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s already exists and is not a locwp mount", target)
+		}
+		if dest, _ := os.Readlink(target); dest == m.Source {
+			return writeMULoader(sc, m, src.IsDir())
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(m.Source, target); err != nil {
+		return err
+	}
+	return writeMULoader(sc, m, src.IsDir())
+}
+
+// WordPress only loads PHP files at the top level of mu-plugins, so a
+// mounted mu-plugin directory needs a loader that requires its main file.
+func writeMULoader(sc *Config, m Mount, isDir bool) error {
+	if m.Type != MountMUPlugin || !isDir {
+		return nil
+	}
+	main := m.Name() + ".php"
+	if _, err := os.Stat(filepath.Join(m.Source, main)); err != nil {
+		return fmt.Errorf("mu-plugin directory %s has no %s", m.Source, main)
+	}
+	loader := fmt.Sprintf("<?php\n// Generated by locwp: loads the mounted mu-plugin %s.\nrequire_once __DIR__ . '/%s/%s';\n", m.Name(), m.Name(), main)
+	return os.WriteFile(muLoaderPath(sc, m), []byte(loader), 0644)
+}
+
+func muLoaderPath(sc *Config, m Mount) string {
+	return filepath.Join(sc.WPRoot, "wp-content", "mu-plugins", "locwp-mount-"+m.Name()+".php")
+}
@@ -0,0 +1,72 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yansircc/locwp/internal/secrets"
+)
+
+// The admin password lives in the secrets store, keyed by site directory
+// so sites in different LOCWP_HOMEs never share an entry.
+
+// AdminPassword returns the site's WordPress admin password.
+func AdminPassword(sc *Config) (string, error) {
+	pass, err := secrets.Default().Get(sc.SiteDir)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return "", fmt.Errorf("no admin password stored for site %d", sc.Port)
+	}
+	return pass, err
+}
+
+// SetAdminPassword stores the site's WordPress admin password.
+func SetAdminPassword(sc *Config, pass string) error {
+	if err := secrets.Default().Set(sc.SiteDir, pass); err != nil {
+		return fmt.Errorf("store admin password: %w", err)
+	}
+	return nil
+}
+
+// DeleteSecrets removes everything stored for the site.
+func DeleteSecrets(sc *Config) error {
+	return secrets.Default().Delete(sc.SiteDir)
+}
+
+// legacyAdminPass returns the plaintext admin_pass older versions kept in
+// the site's config.json, or "".
+func legacyAdminPass(sc *Config) string {
+	data, err := os.ReadFile(filepath.Join(sc.SiteDir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var legacy struct {
+		AdminPass string `json:"admin_pass"`
+	}
+	if json.Unmarshal(data, &legacy) != nil {
+		return ""
+	}
+	return legacy.AdminPass
+}
+
+// HasLegacyAdminPass reports whether the site's config.json still holds a
+// plaintext admin password.
+func HasLegacyAdminPass(sc *Config) bool {
+	return legacyAdminPass(sc) != ""
+}
+
+// MigrateAdminPass moves a plaintext admin_pass left in config.json by
+// older versions into the secrets store and rewrites the config without
+// it. Sites without one are left alone.
+func MigrateAdminPass(sc *Config) error {
+	pass := legacyAdminPass(sc)
+	if pass == "" {
+		return nil
+	}
+	if err := SetAdminPassword(sc, pass); err != nil {
+		return err
+	}
+	return Save(sc.SiteDir, sc)
+}
@@ -3,26 +3,39 @@ package site
 import (
 	"encoding/json"
 	"fmt"
-	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
-	"time"
 
 	"github.com/yansircc/locwp/internal/config"
 )
 
 type Config struct {
-	Port       int    `json:"port"`
-	PHP        string `json:"php"`
-	WPVer      string `json:"wp_version"`
-	SiteDir    string `json:"site_dir"`
-	WPRoot     string `json:"wp_root"`
-	AdminUser  string `json:"admin_user"`
-	AdminPass  string `json:"admin_pass"`
-	AdminEmail string `json:"admin_email"`
+	Port       int      `json:"port"`
+	PHP        string   `json:"php"`
+	WPVer      string   `json:"wp_version"`
+	SiteDir    string   `json:"site_dir"`
+	WPRoot     string   `json:"wp_root"`
+	AdminUser  string   `json:"admin_user"`
+	AdminEmail string   `json:"admin_email"`
+	Adopted    bool     `json:"adopted,omitempty"`
+	DBType     string   `json:"db_type,omitempty"`
+	DBFile     string   `json:"db_file,omitempty"`
+	Mounts     []Mount  `json:"mounts,omitempty"`
+	Blueprint  string   `json:"blueprint,omitempty"`
+	State      string   `json:"state,omitempty"`
+	Socket     string   `json:"socket,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
 }
 
+// Provisioning states recorded in Config.State. A site with no state has
+// finished provisioning.
+const (
+	StateProvisioning = "provisioning"
+	StateFailed       = "failed"
+)
+
 // PortStr returns the port as a string.
 func (sc *Config) PortStr() string {
 	return strconv.Itoa(sc.Port)
@@ -33,6 +46,23 @@ func (sc *Config) URL() string {
 	return fmt.Sprintf("http://localhost:%d", sc.Port)
 }
 
+// DBPath returns the path to the site's SQLite database file.
+func (sc *Config) DBPath() string {
+	if sc.DBFile != "" {
+		return sc.DBFile
+	}
+	return filepath.Join(sc.WPRoot, "wp-content", "database", ".ht.sqlite")
+}
+
+// SocketPath returns the path of the site's PHP-FPM socket. Sites created
+// before sockets moved to config.RuntimeDir keep their /tmp path.
+func (sc *Config) SocketPath() string {
+	if sc.Socket != "" {
+		return sc.Socket
+	}
+	return fmt.Sprintf("/tmp/locwp-%d.sock", sc.Port)
+}
+
 // Save writes site config to site_dir/config.json.
 func Save(siteDir string, sc *Config) error {
 	data, err := json.MarshalIndent(sc, "", "  ")
@@ -66,6 +96,50 @@ func LoadByPort(port int) (*Config, error) {
 	return sc, nil
 }
 
+// LoadAll loads every site under LOCWP_HOME/sites, ordered by port.
+// Directories without a readable config are skipped.
+func LoadAll() ([]*Config, error) {
+	sitesDir := filepath.Join(config.BaseDir(), "sites")
+	entries, err := os.ReadDir(sitesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sites []*Config
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		sc, err := Load(filepath.Join(sitesDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		sites = append(sites, sc)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Port < sites[j].Port })
+	return sites, nil
+}
+
+// ServingRoot returns the site, in any profile, whose WordPress root is
+// dir, or nil if there is none.
+func ServingRoot(dir string) *Config {
+	dir = filepath.Clean(dir)
+	for _, pattern := range []string{
+		filepath.Join(config.RootDir(), "sites", "*", "config.json"),
+		filepath.Join(config.RootDir(), "profiles", "*", "sites", "*", "config.json"),
+	} {
+		files, _ := filepath.Glob(pattern)
+		for _, f := range files {
+			if sc, err := Load(filepath.Dir(f)); err == nil && filepath.Clean(sc.WPRoot) == dir {
+				return sc
+			}
+		}
+	}
+	return nil
+}
+
 // CaddyConfPath returns the path to the Caddy site config.
 func CaddyConfPath(port int) string {
 	return filepath.Join(config.CaddySitesDir(), strconv.Itoa(port)+".caddy")
@@ -76,16 +150,3 @@ func CaddyConfEnabled(port int) bool {
 	_, err := os.Stat(CaddyConfPath(port))
 	return err == nil
 }
-
-// Status checks if a site is responding.
-func Status(sc *Config) string {
-	if !CaddyConfEnabled(sc.Port) {
-		return "stopped"
-	}
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", sc.Port), 500*time.Millisecond)
-	if err != nil {
-		return "stopped"
-	}
-	conn.Close()
-	return "running"
-}
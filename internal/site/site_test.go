@@ -1,8 +1,17 @@
 package site
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestConfig(dir string) *Config {
@@ -46,14 +55,32 @@ func TestLoad_NotExist(t *testing.T) {
 	}
 }
 
-func TestStatus_NoCaddyConf(t *testing.T) {
+func TestCheck_NoCaddyConf(t *testing.T) {
 	baseDir := t.TempDir()
 	t.Setenv("LOCWP_HOME", baseDir)
 
 	sc := newTestConfig(baseDir)
-	status := Status(sc)
-	if status != "stopped" {
-		t.Errorf("Status() = %q, want \"stopped\" when no caddy conf exists", status)
+	if state := Check(sc).State; state != "stopped" {
+		t.Errorf("Check().State = %q, want \"stopped\" when no caddy conf exists", state)
+	}
+}
+
+func TestServingRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	root := filepath.Join(t.TempDir(), "blog")
+	sc := newTestConfig(filepath.Join(home, "profiles", "work", "sites", "11001"))
+	sc.WPRoot = root
+	os.MkdirAll(sc.SiteDir, 0755)
+	if err := Save(sc.SiteDir, sc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ServingRoot(root + "/"); got == nil || got.SiteDir != sc.SiteDir {
+		t.Errorf("ServingRoot() = %+v, want the work profile's site", got)
+	}
+	if got := ServingRoot(filepath.Join(root, "other")); got != nil {
+		t.Errorf("ServingRoot() = %+v for an unserved dir", got)
 	}
 }
 
@@ -71,3 +98,431 @@ func TestPortStr(t *testing.T) {
 		t.Errorf("PortStr() = %q, want \"10001\"", got)
 	}
 }
+
+func writeTestInstall(t *testing.T, dir, wpConfig, dropIn string) {
+	t.Helper()
+	for _, d := range []string{"wp-includes", "wp-content"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"wp-load.php":             "<?php\n",
+		"wp-includes/version.php": "<?php\n$wp_version = '6.4.2';\n$wp_db_version = 56657;\n",
+		"wp-config.php":           wpConfig,
+	}
+	if dropIn != "" {
+		files["wp-content/db.php"] = dropIn
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInspect_MySQL(t *testing.T) {
+	dir := t.TempDir()
+	writeTestInstall(t, dir, "<?php\ndefine( 'DB_NAME', 'client' );\n", "")
+
+	inst, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if inst.Version != "6.4.2" {
+		t.Errorf("Version = %q, want \"6.4.2\"", inst.Version)
+	}
+	if inst.DBType != DBMySQL {
+		t.Errorf("DBType = %q, want %q", inst.DBType, DBMySQL)
+	}
+	if inst.DBFile != "" {
+		t.Errorf("DBFile = %q, want empty for MySQL", inst.DBFile)
+	}
+}
+
+func TestInspect_SQLite(t *testing.T) {
+	dir := t.TempDir()
+	wpConfig := "<?php\ndefine( 'DB_DIR', '/data/db' );\ndefine( 'DB_FILE', 'site.sqlite' );\n"
+	writeTestInstall(t, dir, wpConfig, "<?php // SQLite integration drop-in\n")
+
+	inst, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if inst.DBType != DBSQLite {
+		t.Errorf("DBType = %q, want %q", inst.DBType, DBSQLite)
+	}
+	if inst.DBFile != "/data/db/site.sqlite" {
+		t.Errorf("DBFile = %q, want \"/data/db/site.sqlite\"", inst.DBFile)
+	}
+}
+
+func TestInspect_SQLiteDefaultPath(t *testing.T) {
+	dir := t.TempDir()
+	writeTestInstall(t, dir, "<?php\n", "<?php // sqlite-database-integration\n")
+
+	inst, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	want := filepath.Join(dir, "wp-content", "database", ".ht.sqlite")
+	if inst.DBFile != want {
+		t.Errorf("DBFile = %q, want %q", inst.DBFile, want)
+	}
+}
+
+func TestInspect_NotWordPress(t *testing.T) {
+	if _, err := Inspect(t.TempDir()); err == nil {
+		t.Error("Inspect() on empty dir should error")
+	}
+}
+
+func TestDBPath(t *testing.T) {
+	sc := &Config{WPRoot: "/srv/wp"}
+	if got, want := sc.DBPath(), "/srv/wp/wp-content/database/.ht.sqlite"; got != want {
+		t.Errorf("DBPath() = %q, want %q", got, want)
+	}
+	sc.DBFile = "/data/site.sqlite"
+	if got := sc.DBPath(); got != "/data/site.sqlite" {
+		t.Errorf("DBPath() = %q, want DBFile override", got)
+	}
+}
+
+func TestApplyMounts(t *testing.T) {
+	dir := t.TempDir()
+	sc := newTestConfig(filepath.Join(dir, "site"))
+	plugin := filepath.Join(dir, "src", "my-plugin")
+	muPlugin := filepath.Join(dir, "src", "my-mu")
+	os.MkdirAll(plugin, 0755)
+	os.MkdirAll(muPlugin, 0755)
+	os.WriteFile(filepath.Join(muPlugin, "my-mu.php"), []byte("<?php"), 0644)
+	sc.Mounts = []Mount{
+		{Source: plugin, Type: MountPlugin},
+		{Source: muPlugin, Type: MountMUPlugin},
+	}
+
+	// Applying twice must be idempotent.
+	for i := 0; i < 2; i++ {
+		if err := ApplyMounts(sc); err != nil {
+			t.Fatalf("ApplyMounts() #%d error: %v", i+1, err)
+		}
+	}
+
+	target := filepath.Join(sc.WPRoot, "wp-content", "plugins", "my-plugin")
+	if dest, err := os.Readlink(target); err != nil || dest != plugin {
+		t.Errorf("plugin symlink = %q, %v; want %q", dest, err, plugin)
+	}
+	loader := filepath.Join(sc.WPRoot, "wp-content", "mu-plugins", "locwp-mount-my-mu.php")
+	data, err := os.ReadFile(loader)
+	if err != nil || !strings.Contains(string(data), "my-mu/my-mu.php") {
+		t.Errorf("mu-plugin loader = %q, %v", data, err)
+	}
+
+	if err := RemoveMount(sc, sc.Mounts[1]); err != nil {
+		t.Fatalf("RemoveMount() error: %v", err)
+	}
+	if _, err := os.Lstat(loader); !os.IsNotExist(err) {
+		t.Error("RemoveMount() should delete the mu-plugin loader")
+	}
+	if _, err := os.Stat(muPlugin); err != nil {
+		t.Error("RemoveMount() must not touch the mount source")
+	}
+}
+
+func TestApplyMounts_RefusesRealDirectory(t *testing.T) {
+	dir := t.TempDir()
+	sc := newTestConfig(filepath.Join(dir, "site"))
+	src := filepath.Join(dir, "src", "akismet")
+	os.MkdirAll(src, 0755)
+	os.MkdirAll(filepath.Join(sc.WPRoot, "wp-content", "plugins", "akismet"), 0755)
+	sc.Mounts = []Mount{{Source: src, Type: MountPlugin}}
+
+	if err := ApplyMounts(sc); err == nil {
+		t.Error("ApplyMounts() should refuse to replace a real directory")
+	}
+}
+
+// newHealthSite returns a started site whose port is served by handler,
+// with a Caddy conf, FPM socket and database file in place.
+func newHealthSite(t *testing.T, handler http.HandlerFunc) *Config {
+	t.Helper()
+	baseDir := t.TempDir()
+	t.Setenv("LOCWP_HOME", baseDir)
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	sc := newTestConfig(filepath.Join(baseDir, "sites", portStr))
+	sc.Port = port
+	if err := os.MkdirAll(filepath.Dir(CaddyConfPath(port)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(CaddyConfPath(port), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(sc.DBPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sc.DBPath(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", sc.SocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return sc
+}
+
+func TestCheck_Running(t *testing.T) {
+	sc := newHealthSite(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/wp-admin/install.php", http.StatusFound)
+	})
+	h := Check(sc)
+	if h.State != HealthRunning {
+		t.Errorf("State = %q (%s), want running", h.State, h.Reason)
+	}
+	if h.HTTPStatus != http.StatusFound {
+		t.Errorf("HTTPStatus = %d, want 302", h.HTTPStatus)
+	}
+}
+
+func TestCheck_Degraded(t *testing.T) {
+	sc := newHealthSite(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "fatal", http.StatusBadGateway)
+	})
+	if h := Check(sc); h.State != HealthDegraded || h.HTTPStatus != http.StatusBadGateway {
+		t.Errorf("Check() = %+v, want degraded with 502", h)
+	}
+
+	os.Remove(sc.DBPath())
+	if h := Check(sc); h.State != HealthDegraded || h.Reason != "database file missing" {
+		t.Errorf("Check() = %+v, want degraded for missing database", h)
+	}
+}
+
+func TestCheck_ProvisioningState(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	sc := newTestConfig(t.TempDir())
+	sc.State = StateFailed
+	if h := Check(sc); h.State != StateFailed {
+		t.Errorf("State = %q, want failed", h.State)
+	}
+}
+
+func TestCheckAll_Order(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	var sites []*Config
+	for i := range 5 {
+		sc := newTestConfig(t.TempDir())
+		if i%2 == 0 {
+			sc.State = StateProvisioning
+		}
+		sites = append(sites, sc)
+	}
+	results := CheckAll(sites, 2)
+	for i, h := range results {
+		want := HealthStopped
+		if i%2 == 0 {
+			want = StateProvisioning
+		}
+		if h.State != want {
+			t.Errorf("results[%d].State = %q, want %q", i, h.State, want)
+		}
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	os.WriteFile(filepath.Join(dir, "a", "one"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(dir, "a", "b", "two"), make([]byte, 23), 0644)
+
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "big"), make([]byte, 1000), 0644)
+	os.Symlink(outside, filepath.Join(dir, "link"))
+
+	n, err := DiskUsage(dir)
+	if err != nil {
+		t.Fatalf("DiskUsage() error: %v", err)
+	}
+	if n != 123 {
+		t.Errorf("DiskUsage() = %d, want 123 (symlinks not followed)", n)
+	}
+}
+
+func TestNewLoginToken(t *testing.T) {
+	sc := newTestConfig(t.TempDir())
+
+	token, err := NewLoginToken(sc, "admin", time.Minute)
+	if err != nil {
+		t.Fatalf("NewLoginToken() error: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("token = %q, want 64 hex chars", token)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	path := filepath.Join(sc.LoginTokenDir(), hex.EncodeToString(sum[:])+".json")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("token file missing: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v, want 0600", fi.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), token) {
+		t.Error("token file must not contain the token itself")
+	}
+
+	// Expired tokens are pruned when the next one is created.
+	if _, err := NewLoginToken(sc, "admin", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLoginToken(sc, "admin", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(sc.LoginTokenDir())
+	if len(entries) != 2 {
+		t.Errorf("got %d token files, want 2 after pruning the expired one", len(entries))
+	}
+}
+
+func TestMigrateAdminPass(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	t.Setenv("LOCWP_SECRETS", "file")
+
+	dir := filepath.Join(home, "sites", "10001")
+	os.MkdirAll(dir, 0755)
+	legacy := `{"port": 10001, "site_dir": "` + dir + `", "admin_user": "admin", "admin_pass": "hunter2"}`
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(legacy), 0644)
+
+	sc, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	// Loading must not touch the config or the secrets store.
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.json")); string(data) != legacy {
+		t.Errorf("Load() rewrote config.json: %s", data)
+	}
+	if _, err := AdminPassword(sc); err == nil {
+		t.Error("Load() stored the password")
+	}
+	if !HasLegacyAdminPass(sc) {
+		t.Fatal("HasLegacyAdminPass() = false")
+	}
+
+	if err := MigrateAdminPass(sc); err != nil {
+		t.Fatalf("MigrateAdminPass() error: %v", err)
+	}
+	if pass, err := AdminPassword(sc); err != nil || pass != "hunter2" {
+		t.Errorf("AdminPassword() = %q, %v; want migrated password", pass, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "config.json"))
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("config.json still contains the password: %s", data)
+	}
+	if HasLegacyAdminPass(sc) {
+		t.Error("HasLegacyAdminPass() = true after migration")
+	}
+}
+
+func TestTrash_MoveRestorePurge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	t.Setenv("LOCWP_SECRETS", "file")
+
+	dir := filepath.Join(home, "sites", "10001")
+	os.MkdirAll(dir, 0755)
+	sc := newTestConfig(dir)
+	if err := Save(dir, sc); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAdminPassword(sc, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := MoveToTrash(sc)
+	if err != nil {
+		t.Fatalf("MoveToTrash() error: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("site dir still exists after MoveToTrash")
+	}
+	found, err := FindTrash("10001")
+	if err != nil || found.ID != e.ID {
+		t.Fatalf("FindTrash(10001) = %+v, %v; want %s", found, err, e.ID)
+	}
+	if _, err := FindTrash(e.ID); err != nil {
+		t.Errorf("FindTrash(%s) error: %v", e.ID, err)
+	}
+
+	restored, err := RestoreFromTrash(found)
+	if err != nil {
+		t.Fatalf("RestoreFromTrash() error: %v", err)
+	}
+	if restored.SiteDir != dir {
+		t.Errorf("restored SiteDir = %q, want %q", restored.SiteDir, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, trashMeta)); !os.IsNotExist(err) {
+		t.Errorf("%s left in restored site", trashMeta)
+	}
+	if pass, err := AdminPassword(restored); err != nil || pass != "hunter2" {
+		t.Errorf("AdminPassword() after restore = %q, %v", pass, err)
+	}
+
+	e, _ = MoveToTrash(restored)
+	os.MkdirAll(dir, 0755)
+	if _, err := RestoreFromTrash(e); err == nil {
+		t.Error("RestoreFromTrash() over an existing site dir should error")
+	}
+	if err := PurgeTrash(e); err != nil {
+		t.Fatalf("PurgeTrash() error: %v", err)
+	}
+	if entries, _ := ListTrash(); len(entries) != 0 {
+		t.Errorf("ListTrash() = %d entries after purge, want 0", len(entries))
+	}
+	if _, err := AdminPassword(sc); err == nil {
+		t.Error("secrets kept after PurgeTrash")
+	}
+}
+
+func TestTags(t *testing.T) {
+	sc := newTestConfig(t.TempDir())
+	sc.AddTags("demo", "client-a", "demo")
+	if got := strings.Join(sc.Tags, ","); got != "client-a,demo" {
+		t.Errorf("Tags = %q, want sorted and unique", got)
+	}
+	sc.RemoveTags("demo", "missing")
+	if !sc.HasTag("client-a") || sc.HasTag("demo") {
+		t.Errorf("Tags after RemoveTags = %v", sc.Tags)
+	}
+	sc.RemoveTags("client-a")
+	if sc.Tags != nil {
+		t.Errorf("Tags = %v, want nil so the field is omitted", sc.Tags)
+	}
+
+	a, b, c := newTestConfig(""), newTestConfig(""), newTestConfig("")
+	a.Port, b.Port, c.Port = 1, 2, 3
+	a.AddTags("x")
+	b.AddTags("y", "z")
+	got := WithAnyTag([]*Config{a, b, c}, []string{"z", "x"})
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("WithAnyTag() = %v, want sites 1 and 2", got)
+	}
+
+	for _, tag := range []string{"demo", "client-a", "v1.2", "a_b"} {
+		if err := ValidateTag(tag); err != nil {
+			t.Errorf("ValidateTag(%q) error: %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", "Demo", "-x", "a b", "a,b"} {
+		if err := ValidateTag(tag); err == nil {
+			t.Errorf("ValidateTag(%q) should error", tag)
+		}
+	}
+}
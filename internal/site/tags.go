@@ -0,0 +1,53 @@
+package site
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// ValidateTag checks that a tag is a short lowercase name, so that it can
+// be passed to --tag unquoted.
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: use lowercase letters, digits, '.', '_' and '-'", tag)
+	}
+	return nil
+}
+
+// HasTag reports whether the site is tagged with tag.
+func (sc *Config) HasTag(tag string) bool {
+	return slices.Contains(sc.Tags, tag)
+}
+
+// AddTags tags the site, keeping its tags sorted and unique.
+func (sc *Config) AddTags(tags ...string) {
+	for _, t := range tags {
+		if !sc.HasTag(t) {
+			sc.Tags = append(sc.Tags, t)
+		}
+	}
+	sort.Strings(sc.Tags)
+}
+
+// RemoveTags removes tags from the site.
+func (sc *Config) RemoveTags(tags ...string) {
+	sc.Tags = slices.DeleteFunc(sc.Tags, func(t string) bool { return slices.Contains(tags, t) })
+	if len(sc.Tags) == 0 {
+		sc.Tags = nil
+	}
+}
+
+// WithAnyTag returns the sites tagged with at least one of tags.
+func WithAnyTag(sites []*Config, tags []string) []*Config {
+	var out []*Config
+	for _, sc := range sites {
+		if slices.ContainsFunc(tags, sc.HasTag) {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yansircc/locwp/internal/config"
+)
+
+// trashMeta is the file in a trash entry recording when and from where
+// the site was deleted. config.NextPort reads it to keep the port
+// reserved.
+const trashMeta = "trash.json"
+
+// TrashEntry is a deleted site kept in the trash. Its config still names
+// the original site directory, which secrets are keyed by and which it is
+// restored to.
+type TrashEntry struct {
+	ID        string    `json:"id"`
+	Port      int       `json:"port"`
+	DeletedAt time.Time `json:"deleted_at"`
+	SiteDir   string    `json:"site_dir"`
+	Dir       string    `json:"dir"`
+}
+
+// ReservedUntil returns when the entry stops reserving its port.
+func (e TrashEntry) ReservedUntil() time.Time {
+	return e.DeletedAt.Add(config.TrashRetention)
+}
+
+// Config loads the trashed site's config.
+func (e TrashEntry) Config() (*Config, error) {
+	return Load(e.Dir)
+}
+
+// MoveToTrash moves a site directory into the trash.
+func MoveToTrash(sc *Config) (TrashEntry, error) {
+	now := time.Now().UTC()
+	e := TrashEntry{
+		ID:        fmt.Sprintf("%d-%s", sc.Port, now.Format("20060102T150405")),
+		Port:      sc.Port,
+		DeletedAt: now,
+		SiteDir:   sc.SiteDir,
+	}
+	e.Dir = filepath.Join(config.TrashDir(), e.ID)
+	if err := os.MkdirAll(config.TrashDir(), 0755); err != nil {
+		return e, err
+	}
+
+	// Written before the move, so a trashed site always has its metadata
+	// and keeps its port reserved even if locwp dies in between.
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return e, err
+	}
+	meta := filepath.Join(sc.SiteDir, trashMeta)
+	if err := os.WriteFile(meta, data, 0644); err != nil {
+		return e, err
+	}
+	if err := os.Rename(sc.SiteDir, e.Dir); err != nil {
+		os.Remove(meta)
+		return e, fmt.Errorf("move site %d to trash: %w", sc.Port, err)
+	}
+	return e, nil
+}
+
+// ListTrash returns the trashed sites, most recently deleted first.
+func ListTrash() ([]TrashEntry, error) {
+	files, err := filepath.Glob(filepath.Join(config.TrashDir(), "*", trashMeta))
+	if err != nil {
+		return nil, err
+	}
+	var out []TrashEntry
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var e TrashEntry
+		if json.Unmarshal(data, &e) != nil {
+			continue
+		}
+		// The trash may have moved along with LOCWP_HOME.
+		e.Dir = filepath.Dir(f)
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.After(out[j].DeletedAt) })
+	return out, nil
+}
+
+// FindTrash finds a trash entry by ID, or by port for the most recent
+// deletion of that port.
+func FindTrash(ref string) (TrashEntry, error) {
+	entries, err := ListTrash()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	port, _ := strconv.Atoi(ref)
+	for _, e := range entries {
+		if e.ID == ref || (port != 0 && e.Port == port) {
+			return e, nil
+		}
+	}
+	return TrashEntry{}, fmt.Errorf("%s not found in trash", ref)
+}
+
+// RestoreFromTrash moves a trashed site back to its directory. It fails
+// if a site has been created there since.
+func RestoreFromTrash(e TrashEntry) (*Config, error) {
+	if _, err := os.Stat(e.SiteDir); err == nil {
+		return nil, fmt.Errorf("cannot restore site %d: %s exists", e.Port, e.SiteDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(e.SiteDir), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(e.Dir, e.SiteDir); err != nil {
+		return nil, fmt.Errorf("restore site %d: %w", e.Port, err)
+	}
+	os.Remove(filepath.Join(e.SiteDir, trashMeta))
+	return Load(e.SiteDir)
+}
+
+// PurgeTrash deletes a trashed site for good, with its secrets.
+func PurgeTrash(e TrashEntry) error {
+	if sc, err := e.Config(); err == nil {
+		if err := DeleteSecrets(sc); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(e.Dir)
+}
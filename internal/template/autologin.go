@@ -0,0 +1,121 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+// AutologinPluginName is the mu-plugin file that redeems `locwp login` tokens.
+const AutologinPluginName = "locwp-autologin.php"
+
+// AutologinPrependPath returns where an adopted site's auto-login plugin
+// lives. It stays outside their WordPress root and PHP-FPM loads it with
+// auto_prepend_file, so adopting a site doesn't add files to it.
+func AutologinPrependPath(sc *site.Config) string {
+	return filepath.Join(sc.SiteDir, ".pawl", AutologinPluginName)
+}
+
+// WriteAutologinPlugin writes the auto-login mu-plugin for a site. The
+// plugin claims a token file by renaming it, so each link works once.
+// Prepended before WordPress loads, it registers its hook through
+// $wp_filter, which WordPress picks up when it boots, and first loads
+// the prepend file the site had, such as a Wordfence firewall.
+func WriteAutologinPlugin(path string, sc *site.Config) error {
+	chain := ""
+	if sc.Adopted {
+		if prev := existingPrepend(sc); prev != "" {
+			chain = fmt.Sprintf("\n// The site's own auto_prepend_file, replaced by locwp's in the pool.\nif (is_file(%[1]s)) {\n\tinclude_once %[1]s;\n}\n", phpString(prev))
+		}
+	}
+	plugin := fmt.Sprintf(`<?php
+/**
+ * Plugin Name: locwp auto-login
+ * Description: Redeems one-time login links created by "locwp login".
+ */
+%[3]s
+if (empty($_GET[%[1]s]) || !is_string($_GET[%[1]s])) {
+	return;
+}
+
+$locwp_autologin = function () {
+	$token = $_GET[%[1]s];
+	if (!preg_match('/^[0-9a-f]{64}$/', $token)) {
+		wp_die('Invalid login link.', 403);
+	}
+	$file = %[2]s . '/' . hash('sha256', $token) . '.json';
+	$claimed = $file . '.used';
+	if (!@rename($file, $claimed)) {
+		wp_die('This login link is invalid or has already been used.', 403);
+	}
+	$data = json_decode((string) file_get_contents($claimed), true);
+	@unlink($claimed);
+	if (!is_array($data) || empty($data['expires']) || $data['expires'] < time()) {
+		wp_die('This login link has expired.', 403);
+	}
+	$user = get_user_by('login', $data['user']);
+	if (!$user) {
+		wp_die('Unknown user.', 403);
+	}
+	wp_set_current_user($user->ID);
+	wp_set_auth_cookie($user->ID);
+	wp_safe_redirect(admin_url());
+	exit;
+};
+
+if (function_exists('add_action')) {
+	add_action('init', $locwp_autologin, 1);
+} else {
+	$GLOBALS['wp_filter']['init'][1][] = array('function' => $locwp_autologin, 'accepted_args' => 0);
+}
+`, phpString(site.LoginParam), phpString(sc.LoginTokenDir()), chain)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(plugin), 0644)
+}
+
+// existingPrepend returns the auto_prepend_file an adopted site already
+// has, from the .user.ini in its WordPress root or from PHP's own ini.
+// The pool's php_admin_value overrides both.
+func existingPrepend(sc *site.Config) string {
+	if data, err := os.ReadFile(filepath.Join(sc.WPRoot, ".user.ini")); err == nil {
+		if prev := iniValue(string(data), "auto_prepend_file"); prev != "" {
+			if !filepath.IsAbs(prev) {
+				prev = filepath.Join(sc.WPRoot, prev)
+			}
+			return prev
+		}
+	}
+	out, err := exec.Output(pkgmgr.Default().PHPBin(sc.PHP), "-r", `echo ini_get("auto_prepend_file");`)
+	if prev := strings.TrimSpace(out); err == nil && prev != AutologinPrependPath(sc) {
+		return prev
+	}
+	return ""
+}
+
+// iniValue returns the value of key in a php.ini-style file, unquoted.
+func iniValue(ini, key string) string {
+	value := ""
+	for _, line := range strings.Split(ini, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		v, _, _ = strings.Cut(v, ";")
+		value = strings.Trim(strings.TrimSpace(v), `"'`)
+	}
+	return value
+}
+
+// phpString quotes s as a single-quoted PHP string literal.
+func phpString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -3,15 +3,68 @@ package template
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/tools"
 )
 
+// defaultInstanceAdmin is the admin address of a Caddy instance locwp
+// runs for the default profile, kept off Caddy's default 2019 so that a
+// system Caddy on this machine is never reconfigured by it.
+const defaultInstanceAdmin = "localhost:2018"
+
+// OwnsCaddyfile reports whether locwp runs its own Caddy instance from a
+// Caddyfile in BaseDir, as named profiles, managed tools, the manual
+// package manager and the Linux ones do, rather than adding its sites to
+// the Caddyfile of a system Caddy service.
+func OwnsCaddyfile() bool {
+	m := pkgmgr.Default()
+	return config.ProfileName() != "" || tools.Managed(tools.Caddy) != "" || m.Name() == "manual" || m.Caddyfile() == ""
+}
+
+// CaddyfilePath returns the main Caddyfile of the active profile: locwp's
+// own, or the one read by the Caddy service.
+func CaddyfilePath() string {
+	if OwnsCaddyfile() {
+		return filepath.Join(config.BaseDir(), "Caddyfile")
+	}
+	return pkgmgr.Default().Caddyfile()
+}
+
+// caddyAdmin returns the admin address of locwp's own Caddy instance.
+func caddyAdmin() string {
+	if p, err := config.CurrentProfile(); err == nil && p.CaddyAdmin != "" {
+		return p.CaddyAdmin
+	}
+	return defaultInstanceAdmin
+}
+
+// CaddyReloadCmd returns the shell command that (re)starts the active
+// profile's Caddy: the system service, or locwp's own instance on its own
+// admin address, started on first use.
+func CaddyReloadCmd() string {
+	if !OwnsCaddyfile() {
+		m := pkgmgr.Default()
+		return m.ServiceCmd("restart", m.CaddyService())
+	}
+	return pkgmgr.CaddyInstanceCmd(tools.CaddyBin(), CaddyfilePath(), caddyAdmin(), filepath.Join(config.BaseDir(), "caddy.pid"))
+}
+
+// CaddyStopCmd returns the shell command that stops the active profile's
+// own Caddy instance. It is returned even when sites are now served by the
+// system service, as an instance from before may still be running.
+func CaddyStopCmd() string {
+	return tools.CaddyBin() + " stop --address " + caddyAdmin()
+}
+
 // WriteCaddyConf writes a Caddy site config block to the given path.
 func WriteCaddyConf(path string, sc *site.Config) error {
 	conf := fmt.Sprintf(`:%d {
 	root * %s
-	php_fastcgi unix//tmp/locwp-%d.sock
+	php_fastcgi unix/%s
 	file_server
 	encode gzip
 
@@ -19,7 +72,7 @@ func WriteCaddyConf(path string, sc *site.Config) error {
 		output file %s/logs/access.log
 	}
 }
-`, sc.Port, sc.WPRoot, sc.Port, sc.SiteDir)
+`, sc.Port, sc.WPRoot, sc.SocketPath(), sc.SiteDir)
 
 	return os.WriteFile(path, []byte(conf), 0644)
 }
@@ -0,0 +1,322 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/exec"
+	"github.com/yansircc/locwp/internal/pkgmgr"
+	"github.com/yansircc/locwp/internal/tools"
+)
+
+// Markers around the block locwp adds to a Caddyfile it shares with other
+// sites. Everything between them is rewritten by locwp.
+const (
+	caddyBlockBegin = "# BEGIN locwp (managed by locwp, do not edit)"
+	caddyBlockEnd   = "# END locwp"
+)
+
+// CaddyImportLine is the Caddyfile directive that loads all site configs.
+func CaddyImportLine() string {
+	return fmt.Sprintf("import %s/*.caddy", config.CaddySitesDir())
+}
+
+// CaddyfileBackupPath is where a pre-existing shared Caddyfile is copied
+// before locwp first changes it.
+func CaddyfileBackupPath() string {
+	return backupPath(CaddyfilePath())
+}
+
+func backupPath(caddyfile string) string {
+	return caddyfile + ".before-locwp"
+}
+
+// CaddyMode records how setup last pointed Caddy at the sites, in
+// LOCWP_HOME/caddy/mode.json. Which mode is active depends on the profile,
+// the package manager and managed tools, so it can change between runs;
+// the record lets the next run, and uninstall, clean up after the old one.
+type CaddyMode struct {
+	// Shared is the system Caddyfile holding locwp's import block, or ""
+	// when locwp runs its own Caddy instance.
+	Shared string `json:"shared,omitempty"`
+}
+
+func caddyModePath() string {
+	return filepath.Join(config.BaseDir(), "caddy", "mode.json")
+}
+
+// RecordedCaddyMode returns the mode setup last configured, and false if
+// none was recorded.
+func RecordedCaddyMode() (CaddyMode, bool) {
+	var m CaddyMode
+	data, err := os.ReadFile(caddyModePath())
+	if err != nil || json.Unmarshal(data, &m) != nil {
+		return CaddyMode{}, false
+	}
+	return m, true
+}
+
+func recordCaddyMode(m CaddyMode) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(caddyModePath()), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(caddyModePath(), append(data, '\n'), 0644)
+}
+
+// SharedCaddyfiles returns the shared Caddyfiles that may hold locwp's
+// block: the recorded one and the package manager's, which installs from
+// before the record was kept used.
+func SharedCaddyfiles() []string {
+	var paths []string
+	if m, _ := RecordedCaddyMode(); m.Shared != "" {
+		paths = append(paths, m.Shared)
+	}
+	if p := pkgmgr.Default().Caddyfile(); p != "" && p != ownCaddyfilePath() && !slices.Contains(paths, p) {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func ownCaddyfilePath() string {
+	return filepath.Join(config.BaseDir(), "Caddyfile")
+}
+
+// MergeCaddyImport returns existing with locwp's marked import block
+// appended, replacing any previous block and the unmarked import line
+// older versions wrote. Merging twice gives the same result.
+func MergeCaddyImport(existing, importLine string) string {
+	rest, _ := stripLocwp(existing, importLine)
+	rest = strings.TrimRight(rest, "\n\t ")
+	block := caddyBlockBegin + "\n" + importLine + "\n" + caddyBlockEnd + "\n"
+	if rest == "" {
+		return block
+	}
+	return rest + "\n\n" + block
+}
+
+// StripCaddyImport removes locwp's marked block from a Caddyfile and
+// reports whether there was one.
+func StripCaddyImport(content string) (string, bool) {
+	return stripBlock(content, caddyBlockBegin, caddyBlockEnd)
+}
+
+// stripBlock removes the block from the begin marker to the end of the
+// line holding the end marker, and reports whether there was one. The
+// text on either side is joined with one blank line.
+func stripBlock(content, begin, end string) (string, bool) {
+	b := strings.Index(content, begin)
+	if b < 0 {
+		return content, false
+	}
+	e := strings.Index(content[b:], end)
+	if e < 0 {
+		return content, false
+	}
+	e += b + len(end)
+	if nl := strings.IndexByte(content[e:], '\n'); nl >= 0 {
+		e += nl + 1
+	} else {
+		e = len(content)
+	}
+	before := strings.TrimRight(content[:b], "\n\t ")
+	after := content[e:]
+	if before == "" {
+		return strings.TrimLeft(after, "\n"), true
+	}
+	if after == "" {
+		return before + "\n", true
+	}
+	return before + "\n\n" + strings.TrimLeft(after, "\n"), true
+}
+
+// WriteCaddyfile points the active profile's Caddy at the per-site
+// configs. locwp's own Caddyfile is written whole, with the instance's
+// admin address. A shared Caddyfile is backed up the first time and only
+// gets a marked import block, so whatever else it serves is kept. The
+// result must pass `caddy validate` before it replaces the current file.
+// Whatever the previous mode left behind is removed: the block in a shared
+// Caddyfile that is no longer used, or locwp's own Caddyfile.
+func WriteCaddyfile() error {
+	path := CaddyfilePath()
+	prev, _ := RecordedCaddyMode()
+	if prev.Shared != "" && (OwnsCaddyfile() || prev.Shared != path) {
+		if err := RestoreSharedCaddyfile(prev.Shared); err != nil {
+			return fmt.Errorf("remove locwp's block from %s: %w", prev.Shared, err)
+		}
+	}
+
+	if OwnsCaddyfile() {
+		content := fmt.Sprintf("{\n\tauto_https off\n\tadmin %s\n}\n\n%s\n", caddyAdmin(), CaddyImportLine())
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := replaceValidated(path, content); err != nil {
+			return err
+		}
+		return recordCaddyMode(CaddyMode{})
+	}
+
+	if err := os.Remove(ownCaddyfilePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := MergeCaddyImport(string(existing), CaddyImportLine())
+	if content != string(existing) {
+		if _, marked := StripCaddyImport(string(existing)); err == nil && !marked {
+			if err := backupCaddyfile(path, existing); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := replaceValidated(path, content); err != nil {
+			return err
+		}
+	}
+	return recordCaddyMode(CaddyMode{Shared: path})
+}
+
+// RestoreCaddyfile undoes WriteCaddyfile: locwp's own Caddyfile is
+// removed, and so is the marked block from every shared Caddyfile that may
+// hold one.
+func RestoreCaddyfile() error {
+	if err := os.Remove(ownCaddyfilePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, path := range SharedCaddyfiles() {
+		if err := RestoreSharedCaddyfile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSharedCaddyfile takes the marked block out of a shared Caddyfile,
+// which is removed if nothing else is left and locwp created it. Other
+// changes since are kept.
+func RestoreSharedCaddyfile(path string) error {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rest, found := stripLocwp(string(existing), CaddyImportLine())
+	if !found {
+		return nil
+	}
+	backup := backupPath(path)
+	orig, backupErr := os.ReadFile(backup)
+	if onlyLocwpOptions(rest) && os.IsNotExist(backupErr) {
+		return os.Remove(path)
+	}
+	if err := os.WriteFile(path, []byte(rest), 0644); err != nil {
+		return err
+	}
+	if backupErr == nil && string(orig) == rest {
+		os.Remove(backup)
+	}
+	return nil
+}
+
+// HasCaddyImport reports whether a Caddyfile holds locwp's import.
+func HasCaddyImport(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	_, found := stripLocwp(string(data), CaddyImportLine())
+	return found
+}
+
+// CaddyfileServesOthers reports whether a shared Caddyfile configures
+// anything besides locwp's sites.
+func CaddyfileServesOthers() bool {
+	for _, path := range SharedCaddyfiles() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if rest, _ := stripLocwp(string(data), CaddyImportLine()); !onlyLocwpOptions(rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLocwp removes the marked block, and the unmarked import line
+// older versions wrote, from a Caddyfile.
+func stripLocwp(content, importLine string) (string, bool) {
+	rest, found := StripCaddyImport(content)
+	var kept []string
+	for _, line := range strings.Split(rest, "\n") {
+		if strings.TrimSpace(line) == importLine {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n"), found
+}
+
+// onlyLocwpOptions reports whether a Caddyfile is empty but for the
+// global options block older versions of locwp wrote.
+func onlyLocwpOptions(content string) bool {
+	fields := strings.Join(strings.Fields(content), " ")
+	return fields == "" || fields == "{ auto_https off }"
+}
+
+// backupCaddyfile keeps the first version of a shared Caddyfile seen
+// before locwp changed it.
+func backupCaddyfile(path string, data []byte) error {
+	backup := backupPath(path)
+	if _, err := os.Stat(backup); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(backup, data, 0644); err != nil {
+		return fmt.Errorf("back up %s: %w", path, err)
+	}
+	return nil
+}
+
+// replaceValidated writes content next to path, checks it with
+// `caddy validate` when Caddy is installed, and moves it into place.
+// Validating in the same directory keeps relative imports working.
+func replaceValidated(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".Caddyfile.locwp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if caddy := tools.CaddyBin(); exec.CommandExists(caddy) {
+		out, err := exec.CombinedOutput(caddy, "validate", "--config", tmp.Name(), "--adapter", "caddyfile")
+		if err != nil {
+			return fmt.Errorf("caddy validate rejected the new %s, left unchanged: %w\n%s", path, err, strings.TrimSpace(out))
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
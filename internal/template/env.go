@@ -0,0 +1,91 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/site"
+)
+
+// WPMemoryLimit is the PHP memory limit WP-CLI runs with.
+const WPMemoryLimit = "512M"
+
+// SiteBinDir returns the directory of a site's command shims, put first
+// on PATH by `locwp php` and `locwp shell`.
+func SiteBinDir(sc *site.Config) string {
+	return filepath.Join(sc.SiteDir, "bin")
+}
+
+// WriteSiteBin writes the site's command shims: php, linked to the site's
+// PHP binary so that `#!/usr/bin/env php` scripts such as composer use it,
+// and wp, running the WP-CLI phar with that PHP. wp is left out when
+// WP-CLI isn't installed.
+func WriteSiteBin(sc *site.Config, php, wp string) error {
+	dir := SiteBinDir(sc)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	link := filepath.Join(dir, "php")
+	if target, err := os.Readlink(link); err != nil || target != php {
+		os.Remove(link)
+		if err := os.Symlink(php, link); err != nil {
+			return err
+		}
+	}
+	if wp == "" {
+		return nil
+	}
+	script := fmt.Sprintf("#!/bin/sh\n# Generated by locwp: WP-CLI with site %d's PHP.\nexec %s -d memory_limit=%s %s \"$@\"\n", sc.Port, shellQuote(php), WPMemoryLimit, shellQuote(wp))
+	return os.WriteFile(filepath.Join(dir, "wp"), []byte(script), 0755)
+}
+
+// SiteEnv returns environ with a site's variables added: the shims and
+// PHP's directory first on PATH, where WordPress and its database are, the
+// site URL, and the WP_CLI_* variables the WP-CLI launcher reads.
+func SiteEnv(environ []string, sc *site.Config, php string) []string {
+	path := SiteBinDir(sc) + string(os.PathListSeparator) + filepath.Dir(php)
+	vars := map[string]string{
+		"LOCWP_PORT":      sc.PortStr(),
+		"LOCWP_SITE_DIR":  sc.SiteDir,
+		"WP_ROOT":         sc.WPRoot,
+		"WP_URL":          sc.URL(),
+		"WP_CLI_PHP":      php,
+		"WP_CLI_PHP_ARGS": "-d memory_limit=" + WPMemoryLimit,
+	}
+	if sc.DBType != site.DBMySQL {
+		vars["WP_DB_PATH"] = sc.DBPath()
+	}
+
+	out := make([]string, 0, len(environ)+len(vars))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		switch {
+		case k == "PATH":
+			if v != "" {
+				path += string(os.PathListSeparator) + v
+			}
+		case k == "WP_DB_PATH" || vars[k] != "":
+			// Set below; also drops those of a shell of another site.
+		default:
+			out = append(out, kv)
+		}
+	}
+	out = append(out, "PATH="+path)
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out = append(out, k+"="+vars[k])
+	}
+	return out
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
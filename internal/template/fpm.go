@@ -3,62 +3,84 @@ package template
 import (
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
 	"github.com/yansircc/locwp/internal/site"
 )
 
-// HomebrewPrefix returns the Homebrew prefix for the current platform.
-func HomebrewPrefix() string {
-	if runtime.GOARCH == "arm64" {
-		return "/opt/homebrew"
+// WritePHPConf writes WordPress-friendly PHP limits to locwp.ini in a
+// PHP conf.d directory.
+func WritePHPConf(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create conf.d dir: %w", err)
 	}
-	return "/usr/local"
+	content := `upload_max_filesize = 256M
+post_max_size = 256M
+memory_limit = 512M
+max_execution_time = 300
+max_input_vars = 5000
+`
+	return os.WriteFile(filepath.Join(dir, "locwp.ini"), []byte(content), 0644)
 }
 
-// PHPFormulaName returns the Homebrew formula name for a PHP version.
-func PHPFormulaName(version string) string {
-	if version == "" {
-		version = config.DefaultPHP
+// LocwpBin returns the absolute path of the running locwp binary, for
+// configs and workflows that call back into locwp.
+func LocwpBin() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "locwp"
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		return resolved
 	}
-	return "php@" + version
+	return exe
 }
 
-// FPMPoolDir returns the PHP-FPM pool.d directory for a given PHP version.
-func FPMPoolDir(version string) string {
-	prefix := HomebrewPrefix()
-	return filepath.Join(prefix, "etc", "php", version, "php-fpm.d")
+// LocwpCommand returns the command that runs locwp in the current
+// LOCWP_HOME and profile, for contexts such as PHP's sendmail_path that
+// don't inherit the caller's environment.
+func LocwpCommand() string {
+	var env []string
+	if home := os.Getenv("LOCWP_HOME"); home != "" {
+		env = append(env, "LOCWP_HOME="+home)
+	}
+	if name := config.ProfileName(); name != "" {
+		env = append(env, config.ProfileEnv+"="+name)
+	}
+	if len(env) == 0 {
+		return LocwpBin()
+	}
+	return "env " + strings.Join(env, " ") + " " + LocwpBin()
 }
 
-// PHPConfDir returns the PHP conf.d directory for a given version.
-func PHPConfDir(version string) string {
-	return filepath.Join(HomebrewPrefix(), "etc", "php", version, "conf.d")
+// FPMPoolPath returns where a site's pool config goes in the PHP-FPM pool.d.
+func FPMPoolPath(sc *site.Config) string {
+	return filepath.Join(pkgmgr.Default().FPMPoolDir(sc.PHP), config.PoolName(sc.Port)+".conf")
 }
 
-// WritePHPConf writes WordPress-friendly PHP limits to conf.d/locwp.ini.
-func WritePHPConf(version string) error {
-	dir := PHPConfDir(version)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("create conf.d dir: %w", err)
+// primaryGroup returns the current user's primary group name, which the
+// FPM socket is shared with (staff on macOS).
+func primaryGroup() string {
+	if u, err := user.Current(); err == nil {
+		if g, err := user.LookupGroupId(u.Gid); err == nil {
+			return g.Name
+		}
 	}
-	content := `upload_max_filesize = 256M
-post_max_size = 256M
-memory_limit = 512M
-max_execution_time = 300
-max_input_vars = 5000
-`
-	return os.WriteFile(filepath.Join(dir, "locwp.ini"), []byte(content), 0644)
+	return "staff"
 }
 
 func WriteFPMPool(path string, sc *site.Config) error {
-	pool := fmt.Sprintf(`[locwp-%d]
+	group := primaryGroup()
+	pool := fmt.Sprintf(`[%s]
 user = %s
-group = staff
-listen = /tmp/locwp-%d.sock
+group = %s
+listen = %s
 listen.owner = %s
-listen.group = staff
+listen.group = %s
 listen.mode = 0660
 
 pm = ondemand
@@ -66,7 +88,17 @@ pm.max_children = 5
 pm.process_idle_timeout = 10s
 
 php_admin_value[error_log] = %s/logs/php-error.log
-`, sc.Port, os.Getenv("USER"), sc.Port, os.Getenv("USER"), sc.SiteDir)
+php_admin_value[sendmail_path] = "%s mail sendmail %d"
+`, config.PoolName(sc.Port), os.Getenv("USER"), group, sc.SocketPath(), os.Getenv("USER"), group, sc.SiteDir, LocwpCommand(), sc.Port)
+
+	// Mounted plugins/themes are symlinks that get re-pointed as branches
+	// and worktrees change; keep PHP from serving stale resolved paths.
+	if len(sc.Mounts) > 0 {
+		pool += "php_admin_value[realpath_cache_ttl] = 2\n"
+	}
+	if sc.Adopted {
+		pool += "php_admin_value[auto_prepend_file] = " + AutologinPrependPath(sc) + "\n"
+	}
 
 	return os.WriteFile(path, []byte(pool), 0644)
 }
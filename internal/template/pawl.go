@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/yansircc/locwp/internal/blueprint"
 	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/pkgmgr"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/tools"
 )
 
 type pawlConfig struct {
@@ -25,12 +29,41 @@ type pawlStep struct {
 	OnFail string `json:"on_fail,omitempty"`
 }
 
+// mailSinkStep starts the shared SMTP sink in the background. `mail serve`
+// exits immediately when another instance already holds the port.
+var mailSinkStep = pawlStep{Name: "start-mail-sink", Run: "nohup ${locwp_bin} mail serve >/dev/null 2>&1 &"}
+
+// autologinStep installs the mu-plugin behind `locwp login`, written next
+// to the workflows by WritePawlWorkflows.
+var autologinStep = pawlStep{Name: "install-autologin", Run: "mkdir -p ${wp_root}/wp-content/mu-plugins && cp ${site_dir}/.pawl/" + AutologinPluginName + " ${wp_root}/wp-content/mu-plugins/"}
+
+// BatchEnv is set for workflows run by a bulk command. Their service steps
+// are then skipped, and the command restarts PHP-FPM and reloads Caddy once
+// for the whole batch: concurrent `caddy start`s and service restarts race
+// each other.
+const BatchEnv = "LOCWP_BATCH"
+
+// unlessBatch guards a service step so that it is skipped in a batch.
+func unlessBatch(run string) string {
+	return `[ -n "$` + BatchEnv + `" ] || { ` + run + `; }`
+}
+
+// BatchAware reports whether the site's workflows skip their service steps
+// in a batch. Workflows written by older versions don't.
+func BatchAware(sc *site.Config) bool {
+	data, err := os.ReadFile(filepath.Join(sc.SiteDir, ".pawl", "workflows", "start.json"))
+	return err == nil && strings.Contains(string(data), BatchEnv)
+}
+
+// runDirStep creates the private directory holding the site's FPM socket.
+var runDirStep = pawlStep{Name: "ensure-run-dir", Run: "mkdir -p ${run_dir} && chmod 700 ${run_dir}"}
+
 const sqlitePluginURL = "https://downloads.wordpress.org/plugin/sqlite-database-integration.latest-stable.zip"
 
 // WritePawlWorkflows generates all lifecycle workflow files under workflowDir.
 func WritePawlWorkflows(workflowDir string, sc *site.Config) error {
-	prefix := HomebrewPrefix()
-	phpBin := filepath.Join(prefix, "opt", "php@"+sc.PHP, "bin", "php")
+	pm := pkgmgr.Default()
+	phpBin := pm.PHPBin(sc.PHP)
 	portStr := sc.PortStr()
 	baseDir := filepath.Dir(filepath.Dir(sc.SiteDir))
 
@@ -42,12 +75,18 @@ func WritePawlWorkflows(workflowDir string, sc *site.Config) error {
 		"php_bin":           phpBin,
 		"site_dir":          sc.SiteDir,
 		"admin_user":        sc.AdminUser,
-		"admin_pass":        sc.AdminPass,
 		"admin_email":       sc.AdminEmail,
 		"caddy_conf":        filepath.Join(config.CaddySitesDir(), portStr+".caddy"),
 		"fpm_local":         filepath.Join(baseDir, "php", portStr+".conf"),
-		"fpm_pool":          filepath.Join(FPMPoolDir(sc.PHP), "locwp-"+portStr+".conf"),
+		"fpm_pool":          FPMPoolPath(sc),
 		"sqlite_plugin_url": sqlitePluginURL,
+		"locwp_bin":         LocwpCommand(),
+		"caddy_reload":      CaddyReloadCmd(),
+		"caddy_bin":         tools.CaddyBin(),
+		"wp_bin":            tools.WPBin(),
+		"php_start":         pm.ServiceCmd("start", pm.PHPService(sc.PHP)),
+		"php_restart":       pm.ServiceCmd("restart", pm.PHPService(sc.PHP)),
+		"run_dir":           filepath.Dir(sc.SocketPath()),
 	}
 
 	type workflowDef struct {
@@ -55,34 +94,46 @@ func WritePawlWorkflows(workflowDir string, sc *site.Config) error {
 		steps       []pawlStep
 	}
 
+	if err := WriteAutologinPlugin(filepath.Join(sc.SiteDir, ".pawl", AutologinPluginName), sc); err != nil {
+		return err
+	}
+
+	provision := provisionSteps(sc)
+	if sc.Blueprint != "" {
+		steps, err := blueprintSteps(sc)
+		if err != nil {
+			return err
+		}
+		provision = append(provision, steps...)
+	}
+
+	start := []pawlStep{
+		{Name: "enable-caddy-conf", Run: "mv ${caddy_conf}.disabled ${caddy_conf} 2>/dev/null || true"},
+		{Name: "start-php", Run: unlessBatch("${php_start}")},
+		mailSinkStep,
+		{Name: "reload-caddy", Run: unlessBatch("${caddy_reload}")},
+	}
+	// $XDG_RUNTIME_DIR is emptied on logout, so recreate the socket dir
+	// before PHP-FPM needs it. Legacy /tmp sockets need nothing.
+	if sc.Socket != "" {
+		provision = insertBefore(provision, "provision-services", runDirStep)
+		start = insertBefore(start, "start-php", runDirStep)
+	}
+
 	workflows := map[string]workflowDef{
 		"provision": {
 			description: "Provision WordPress site",
-			steps: []pawlStep{
-				{Name: "check-deps", Run: "test -x ${php_bin} && which caddy wp && ${php_bin} -m | grep -q pdo_sqlite"},
-				{Name: "download-wp", Run: "${php_bin} -d memory_limit=512M $(which wp) core download --path=${wp_root} --version=${wp_ver}", OnFail: "retry"},
-				{Name: "download-sqlite-plugin", Run: "mkdir -p ${wp_root}/wp-content/mu-plugins && curl -sL ${sqlite_plugin_url} -o /tmp/locwp-sqlite-plugin.zip && unzip -qo /tmp/locwp-sqlite-plugin.zip -d ${wp_root}/wp-content/mu-plugins/ && rm -f /tmp/locwp-sqlite-plugin.zip", OnFail: "retry"},
-				{Name: "setup-db-dropin", Run: "cp ${wp_root}/wp-content/mu-plugins/sqlite-database-integration/db.copy ${wp_root}/wp-content/db.php && sed -i '' \"s|/plugins/sqlite-database-integration|/mu-plugins/sqlite-database-integration|\" ${wp_root}/wp-content/db.php && mkdir -p ${wp_root}/wp-content/database"},
-				{Name: "gen-wp-config", Run: "${php_bin} -d memory_limit=512M $(which wp) config create --path=${wp_root} --dbname=wordpress --dbuser=unused --dbhost=unused --skip-check"},
-				{Name: "configure-sqlite", Run: "${php_bin} -d memory_limit=512M $(which wp) config set DB_DIR ${wp_root}/wp-content/database --path=${wp_root} --type=constant && ${php_bin} -d memory_limit=512M $(which wp) config set DB_FILE .ht.sqlite --path=${wp_root} --type=constant"},
-				{Name: "provision-services", Run: "brew services restart php@${php_ver} 2>/dev/null; brew services restart caddy", OnFail: "retry"},
-				{Name: "install-wp", Run: "${php_bin} -d memory_limit=512M $(which wp) core install --path=${wp_root} --url=http://localhost:${port} --title=WordPress --admin_user=${admin_user} --admin_password=${admin_pass} --admin_email=${admin_email}", OnFail: "retry"},
-				{Name: "set-permalinks", Run: "${php_bin} -d memory_limit=512M $(which wp) rewrite structure '/%postname%/' --path=${wp_root} && ${php_bin} -d memory_limit=512M $(which wp) rewrite flush --path=${wp_root}"},
-			},
+			steps:       provision,
 		},
 		"start": {
 			description: "Start WordPress site",
-			steps: []pawlStep{
-				{Name: "enable-caddy-conf", Run: "mv ${caddy_conf}.disabled ${caddy_conf} 2>/dev/null || true"},
-				{Name: "start-php", Run: "brew services start php@${php_ver}"},
-				{Name: "reload-caddy", Run: "brew services restart caddy"},
-			},
+			steps:       start,
 		},
 		"stop": {
 			description: "Stop WordPress site",
 			steps: []pawlStep{
 				{Name: "disable-caddy-conf", Run: "mv ${caddy_conf} ${caddy_conf}.disabled 2>/dev/null || true"},
-				{Name: "reload-caddy", Run: "brew services restart caddy || true"},
+				{Name: "reload-caddy", Run: unlessBatch("${caddy_reload} || true")},
 			},
 		},
 		"destroy": {
@@ -90,7 +141,7 @@ func WritePawlWorkflows(workflowDir string, sc *site.Config) error {
 			steps: []pawlStep{
 				{Name: "destroy-caddy-conf", Run: "rm -f ${caddy_conf} ${caddy_conf}.disabled"},
 				{Name: "destroy-fpm", Run: "rm -f ${fpm_local} ${fpm_pool}"},
-				{Name: "destroy-reload", Run: "brew services restart php@${php_ver} 2>/dev/null; brew services restart caddy || true"},
+				{Name: "destroy-reload", Run: unlessBatch("${php_restart} 2>/dev/null; ${caddy_reload} || true")},
 			},
 		},
 	}
@@ -113,3 +164,82 @@ func WritePawlWorkflows(workflowDir string, sc *site.Config) error {
 	}
 	return nil
 }
+
+// provisionSteps returns the provision workflow for a site. Adopted sites
+// already contain WordPress, so they only get dependency checks and a
+// service reload; their files and database are never touched.
+func provisionSteps(sc *site.Config) []pawlStep {
+	if sc.Adopted {
+		check := "test -x ${php_bin} && which ${caddy_bin} ${wp_bin}"
+		if sc.DBType == site.DBSQLite {
+			check += " && ${php_bin} -m | grep -q pdo_sqlite"
+		}
+		return []pawlStep{
+			{Name: "check-deps", Run: check},
+			{Name: "check-wp", Run: "test -f ${wp_root}/wp-load.php"},
+			{Name: "provision-services", Run: "${php_restart} 2>/dev/null; ${caddy_reload}", OnFail: "retry"},
+			mailSinkStep,
+		}
+	}
+	return []pawlStep{
+		{Name: "check-deps", Run: "test -x ${php_bin} && which ${caddy_bin} ${wp_bin} && ${php_bin} -m | grep -q pdo_sqlite"},
+		{Name: "download-wp", Run: "${php_bin} -d memory_limit=512M $(which ${wp_bin}) core download --path=${wp_root} --version=${wp_ver}", OnFail: "retry"},
+		{Name: "download-sqlite-plugin", Run: "mkdir -p ${wp_root}/wp-content/mu-plugins && curl -sL ${sqlite_plugin_url} -o /tmp/locwp-sqlite-plugin.zip && unzip -qo /tmp/locwp-sqlite-plugin.zip -d ${wp_root}/wp-content/mu-plugins/ && rm -f /tmp/locwp-sqlite-plugin.zip", OnFail: "retry"},
+		{Name: "setup-db-dropin", Run: "cp ${wp_root}/wp-content/mu-plugins/sqlite-database-integration/db.copy ${wp_root}/wp-content/db.php && sed -i.bak \"s|/plugins/sqlite-database-integration|/mu-plugins/sqlite-database-integration|\" ${wp_root}/wp-content/db.php && rm -f ${wp_root}/wp-content/db.php.bak && mkdir -p ${wp_root}/wp-content/database"},
+		{Name: "gen-wp-config", Run: "${php_bin} -d memory_limit=512M $(which ${wp_bin}) config create --path=${wp_root} --dbname=wordpress --dbuser=unused --dbhost=unused --skip-check"},
+		{Name: "configure-sqlite", Run: "${php_bin} -d memory_limit=512M $(which ${wp_bin}) config set DB_DIR ${wp_root}/wp-content/database --path=${wp_root} --type=constant && ${php_bin} -d memory_limit=512M $(which ${wp_bin}) config set DB_FILE .ht.sqlite --path=${wp_root} --type=constant"},
+		{Name: "provision-services", Run: "${php_restart} 2>/dev/null; ${caddy_reload}", OnFail: "retry"},
+		mailSinkStep,
+		// The password comes from the secrets store on stdin, never from vars.
+		{Name: "install-wp", Run: "${locwp_bin} creds ${port} --password-only | ${php_bin} -d memory_limit=512M $(which ${wp_bin}) core install --path=${wp_root} --url=http://localhost:${port} --title=WordPress --admin_user=${admin_user} --admin_email=${admin_email} --prompt=admin_password", OnFail: "retry"},
+		{Name: "set-permalinks", Run: "${php_bin} -d memory_limit=512M $(which ${wp_bin}) rewrite structure '/%postname%/' --path=${wp_root} && ${php_bin} -d memory_limit=512M $(which ${wp_bin}) rewrite flush --path=${wp_root}"},
+		autologinStep,
+	}
+}
+
+// blueprintSteps converts the site's blueprint into provision steps.
+// runPHP code is written to files next to the workflows and run with
+// wp eval-file; Playground's /wordpress/ root maps to the site's WPRoot.
+func blueprintSteps(sc *site.Config) ([]pawlStep, error) {
+	bp, err := blueprint.Load(sc.Blueprint)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := bp.Commands()
+	if err != nil {
+		return nil, err
+	}
+
+	phpDir := filepath.Join(sc.SiteDir, ".pawl", "blueprint")
+	var steps []pawlStep
+	for _, c := range cmds {
+		args := c.WP
+		if c.PHP != "" {
+			if err := os.MkdirAll(phpDir, 0755); err != nil {
+				return nil, err
+			}
+			code := strings.ReplaceAll(c.PHP, "/wordpress/", sc.WPRoot+"/")
+			path := filepath.Join(phpDir, c.Name+".php")
+			if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+				return nil, err
+			}
+			args = "eval-file " + path
+		}
+		steps = append(steps, pawlStep{
+			Name: c.Name,
+			Run:  "${php_bin} -d memory_limit=512M $(which ${wp_bin}) " + args + " --path=${wp_root}",
+		})
+	}
+	return steps, nil
+}
+
+// insertBefore returns steps with step inserted before the step named name,
+// or appended if there is none.
+func insertBefore(steps []pawlStep, name string, step pawlStep) []pawlStep {
+	for i, s := range steps {
+		if s.Name == name {
+			return append(steps[:i:i], append([]pawlStep{step}, steps[i:]...)...)
+		}
+	}
+	return append(steps, step)
+}
@@ -4,11 +4,12 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/yansircc/locwp/internal/config"
 	"github.com/yansircc/locwp/internal/site"
+	"github.com/yansircc/locwp/internal/tools"
 )
 
 func testSiteConfig(dir string) *site.Config {
@@ -19,51 +20,10 @@ func testSiteConfig(dir string) *site.Config {
 		SiteDir:    filepath.Join(dir, "sites", "10001"),
 		WPRoot:     filepath.Join(dir, "sites", "10001", "wordpress"),
 		AdminUser:  "admin",
-		AdminPass:  "admin",
 		AdminEmail: "admin@loc.wp",
 	}
 }
 
-func TestPHPFormulaName(t *testing.T) {
-	tests := []struct {
-		version string
-		want    string
-	}{
-		{"8.3", "php@8.3"},
-		{"8.2", "php@8.2"},
-		{"8.1", "php@8.1"},
-		{"", "php@8.3"}, // default
-	}
-	for _, tt := range tests {
-		got := PHPFormulaName(tt.version)
-		if got != tt.want {
-			t.Errorf("PHPFormulaName(%q) = %q, want %q", tt.version, got, tt.want)
-		}
-	}
-}
-
-func TestHomebrewPrefix(t *testing.T) {
-	prefix := HomebrewPrefix()
-	if runtime.GOARCH == "arm64" {
-		if prefix != "/opt/homebrew" {
-			t.Errorf("HomebrewPrefix() = %q on arm64, want /opt/homebrew", prefix)
-		}
-	} else {
-		if prefix != "/usr/local" {
-			t.Errorf("HomebrewPrefix() = %q on non-arm64, want /usr/local", prefix)
-		}
-	}
-}
-
-func TestFPMPoolDir(t *testing.T) {
-	dir := FPMPoolDir("8.2")
-	prefix := HomebrewPrefix()
-	want := filepath.Join(prefix, "etc", "php", "8.2", "php-fpm.d")
-	if dir != want {
-		t.Errorf("FPMPoolDir(\"8.2\") = %q, want %q", dir, want)
-	}
-}
-
 func TestWriteCaddyConf(t *testing.T) {
 	dir := t.TempDir()
 	sc := testSiteConfig(dir)
@@ -116,6 +76,7 @@ func TestWriteFPMPool(t *testing.T) {
 		"pm = ondemand",
 		"pm.max_children = 5",
 		"php_admin_value[error_log]",
+		"mail sendmail 10001\"",
 	}
 	for _, check := range checks {
 		if !strings.Contains(content, check) {
@@ -124,22 +85,33 @@ func TestWriteFPMPool(t *testing.T) {
 	}
 }
 
+func TestWriteFPMPool_Mounts(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	outPath := filepath.Join(dir, "test-fpm.conf")
+
+	WriteFPMPool(outPath, sc)
+	data, _ := os.ReadFile(outPath)
+	if strings.Contains(string(data), "realpath_cache_ttl") {
+		t.Error("FPM pool without mounts should keep default realpath cache")
+	}
+
+	sc.Mounts = []site.Mount{{Source: "/src/plugin", Type: site.MountPlugin}}
+	WriteFPMPool(outPath, sc)
+	data, _ = os.ReadFile(outPath)
+	if !strings.Contains(string(data), "php_admin_value[realpath_cache_ttl]") {
+		t.Error("FPM pool with mounts missing realpath_cache_ttl")
+	}
+}
+
 func TestWritePHPConf(t *testing.T) {
 	dir := t.TempDir()
 	confDir := filepath.Join(dir, "etc", "php", "8.3", "conf.d")
 
-	// WritePHPConf uses HomebrewPrefix, so test the content via direct write
-	os.MkdirAll(confDir, 0755)
-	content := `upload_max_filesize = 256M
-post_max_size = 256M
-memory_limit = 512M
-max_execution_time = 300
-max_input_vars = 5000
-`
-	path := filepath.Join(confDir, "locwp.ini")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		t.Fatalf("WriteFile() error: %v", err)
+	if err := WritePHPConf(confDir); err != nil {
+		t.Fatalf("WritePHPConf() error: %v", err)
 	}
+	path := filepath.Join(confDir, "locwp.ini")
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -160,15 +132,6 @@ max_input_vars = 5000
 	}
 }
 
-func TestPHPConfDir(t *testing.T) {
-	dir := PHPConfDir("8.3")
-	prefix := HomebrewPrefix()
-	want := filepath.Join(prefix, "etc", "php", "8.3", "conf.d")
-	if dir != want {
-		t.Errorf("PHPConfDir(\"8.3\") = %q, want %q", dir, want)
-	}
-}
-
 func TestWritePawlWorkflows(t *testing.T) {
 	dir := t.TempDir()
 	sc := testSiteConfig(dir)
@@ -227,10 +190,528 @@ func TestWritePawlWorkflows(t *testing.T) {
 	if !strings.Contains(content, "--title=WordPress") {
 		t.Error("provision.json missing default WordPress title")
 	}
+	if strings.Contains(content, "${admin_pass}") || strings.Contains(content, `"admin_pass"`) || !strings.Contains(content, "creds ${port} --password-only |") {
+		t.Error("provision.json must read the admin password from `locwp creds`, not vars")
+	}
 
 	// No site name reference
 	data, _ = os.ReadFile(filepath.Join(workflowDir, "start.json"))
 	if !strings.Contains(string(data), "caddy") {
 		t.Error("start.json missing caddy reference")
 	}
+
+	// Bulk commands run the service steps once for the whole batch.
+	for name, step := range map[string]string{"start": "reload-caddy", "stop": "reload-caddy", "destroy": "destroy-reload"} {
+		data, _ := os.ReadFile(filepath.Join(workflowDir, name+".json"))
+		var cfg pawlConfig
+		json.Unmarshal(data, &cfg)
+		for _, s := range cfg.Workflow {
+			if s.Name == step && !strings.HasPrefix(s.Run, `[ -n "$LOCWP_BATCH" ] || {`) {
+				t.Errorf("%s step %s not skipped in a batch: %s", name, step, s.Run)
+			}
+		}
+	}
+	sc.SiteDir = dir
+	os.MkdirAll(filepath.Join(dir, ".pawl"), 0755)
+	os.Rename(workflowDir, filepath.Join(dir, ".pawl", "workflows"))
+	if !BatchAware(sc) {
+		t.Error("BatchAware() = false for new workflows")
+	}
+	os.WriteFile(filepath.Join(dir, ".pawl", "workflows", "start.json"), []byte(`{"workflow": [{"run": "${caddy_reload}"}]}`), 0644)
+	if BatchAware(sc) {
+		t.Error("BatchAware() = true for legacy workflows")
+	}
+}
+
+func TestWritePawlWorkflows_Adopted(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	sc.Adopted = true
+	sc.DBType = site.DBMySQL
+	workflowDir := filepath.Join(dir, "workflows")
+	os.MkdirAll(workflowDir, 0755)
+
+	if err := WritePawlWorkflows(workflowDir, sc); err != nil {
+		t.Fatalf("WritePawlWorkflows() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(workflowDir, "provision.json"))
+	content := string(data)
+	for _, unwanted := range []string{"core download", "config create", "core install", "db.php"} {
+		if strings.Contains(content, unwanted) {
+			t.Errorf("adopted provision.json should not contain %q", unwanted)
+		}
+	}
+	if strings.Contains(content, "pdo_sqlite") {
+		t.Error("adopted MySQL site should not require pdo_sqlite")
+	}
+	if strings.Contains(content, "mu-plugins") {
+		t.Error("adopted provision.json should not write into wp-content")
+	}
+
+	destroy, _ := os.ReadFile(filepath.Join(workflowDir, "destroy.json"))
+	if strings.Contains(string(destroy), "${wp_root}") {
+		t.Error("adopted destroy.json should not touch the WordPress root")
+	}
+
+	poolPath := filepath.Join(dir, "pool.conf")
+	if err := WriteFPMPool(poolPath, sc); err != nil {
+		t.Fatal(err)
+	}
+	pool, _ := os.ReadFile(poolPath)
+	if !strings.Contains(string(pool), "php_admin_value[auto_prepend_file] = "+AutologinPrependPath(sc)) {
+		t.Errorf("adopted pool should prepend the auto-login plugin:\n%s", pool)
+	}
+}
+
+func TestWritePawlWorkflows_Blueprint(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	os.MkdirAll(sc.SiteDir, 0755)
+	sc.Blueprint = filepath.Join(sc.SiteDir, "blueprint.json")
+	os.WriteFile(sc.Blueprint, []byte(`{"plugins": ["query-monitor"], "steps": [{"step": "runPHP", "code": "<?php require '/wordpress/wp-load.php';"}]}`), 0644)
+	workflowDir := filepath.Join(dir, "workflows")
+	os.MkdirAll(workflowDir, 0755)
+
+	if err := WritePawlWorkflows(workflowDir, sc); err != nil {
+		t.Fatalf("WritePawlWorkflows() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(workflowDir, "provision.json"))
+	var cfg pawlConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	steps := cfg.Workflow
+	if len(steps) < 2 || steps[len(steps)-2].Name != "blueprint-1-installPlugin" {
+		t.Fatalf("blueprint steps not appended to provision: %+v", steps)
+	}
+	if !strings.Contains(steps[len(steps)-2].Run, "plugin install query-monitor --activate --path=${wp_root}") {
+		t.Errorf("installPlugin step = %q", steps[len(steps)-2].Run)
+	}
+
+	php, err := os.ReadFile(filepath.Join(sc.SiteDir, ".pawl", "blueprint", "blueprint-2-runPHP.php"))
+	if err != nil {
+		t.Fatalf("runPHP file not written: %v", err)
+	}
+	if !strings.Contains(string(php), sc.WPRoot+"/wp-load.php") {
+		t.Errorf("runPHP code not mapped to WPRoot: %s", php)
+	}
+}
+
+func TestWritePawlWorkflows_Autologin(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	workflowDir := filepath.Join(sc.SiteDir, ".pawl", "workflows")
+	os.MkdirAll(workflowDir, 0755)
+
+	if err := WritePawlWorkflows(workflowDir, sc); err != nil {
+		t.Fatalf("WritePawlWorkflows() error: %v", err)
+	}
+
+	plugin, err := os.ReadFile(filepath.Join(sc.SiteDir, ".pawl", AutologinPluginName))
+	if err != nil {
+		t.Fatalf("autologin plugin not written: %v", err)
+	}
+	for _, want := range []string{
+		"'" + sc.LoginTokenDir() + "'",
+		"$_GET['locwp_login']",
+		"rename($file, $claimed)",
+		"wp_set_auth_cookie",
+		"$GLOBALS['wp_filter']['init'][1][]",
+	} {
+		if !strings.Contains(string(plugin), want) {
+			t.Errorf("autologin plugin missing %q", want)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(workflowDir, "provision.json"))
+	var cfg pawlConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	last := cfg.Workflow[len(cfg.Workflow)-1]
+	if last.Name != "install-autologin" || !strings.Contains(last.Run, "${site_dir}/.pawl/"+AutologinPluginName) {
+		t.Errorf("last provision step = %+v, want install-autologin", last)
+	}
+}
+
+func TestWriteAutologinPlugin_ChainsPrepend(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	sc.Adopted = true
+	os.MkdirAll(sc.WPRoot, 0755)
+	os.WriteFile(filepath.Join(sc.WPRoot, ".user.ini"), []byte("; Wordfence WAF\nauto_prepend_file = 'wordfence-waf.php' ; added by Wordfence\n"), 0644)
+
+	path := AutologinPrependPath(sc)
+	if err := WriteAutologinPlugin(path, sc); err != nil {
+		t.Fatal(err)
+	}
+	plugin, _ := os.ReadFile(path)
+	waf := phpString(filepath.Join(sc.WPRoot, "wordfence-waf.php"))
+	if !strings.Contains(string(plugin), "include_once "+waf) {
+		t.Errorf("adopted prepend file should load the site's own prepend:\n%s", plugin)
+	}
+	if strings.Index(string(plugin), "include_once") > strings.Index(string(plugin), "return;") {
+		t.Error("the site's prepend must load before the early return")
+	}
+}
+
+func TestPHPString(t *testing.T) {
+	if got := phpString(`it's C:\dir`); got != `'it\'s C:\\dir'` {
+		t.Errorf("phpString() = %s", got)
+	}
+}
+
+func TestWritePawlWorkflows_RuntimeSocket(t *testing.T) {
+	dir := t.TempDir()
+	sc := testSiteConfig(dir)
+	sc.Socket = filepath.Join(dir, "run", "10001.sock")
+	workflowDir := filepath.Join(dir, "workflows")
+	os.MkdirAll(workflowDir, 0755)
+
+	if err := WritePawlWorkflows(workflowDir, sc); err != nil {
+		t.Fatalf("WritePawlWorkflows() error: %v", err)
+	}
+	for name, before := range map[string]string{"provision": "provision-services", "start": "start-php"} {
+		data, _ := os.ReadFile(filepath.Join(workflowDir, name+".json"))
+		var cfg pawlConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Vars["run_dir"] != filepath.Join(dir, "run") {
+			t.Errorf("%s vars.run_dir = %q", name, cfg.Vars["run_dir"])
+		}
+		found := false
+		for i, s := range cfg.Workflow {
+			if s.Name == "ensure-run-dir" {
+				found = i+1 < len(cfg.Workflow) && cfg.Workflow[i+1].Name == before
+			}
+		}
+		if !found {
+			t.Errorf("%s: ensure-run-dir not placed before %s", name, before)
+		}
+	}
+
+	caddy := filepath.Join(dir, "site.caddy")
+	WriteCaddyConf(caddy, sc)
+	data, _ := os.ReadFile(caddy)
+	if !strings.Contains(string(data), "php_fastcgi unix/"+sc.Socket) {
+		t.Errorf("caddy conf does not use the runtime socket:\n%s", data)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCWP_HOME", dir)
+	t.Setenv(config.ProfileEnv, "work")
+	sc := testSiteConfig(dir)
+	sc.Port = 11001
+
+	if got := filepath.Base(FPMPoolPath(sc)); got != "locwp-work-11001.conf" {
+		t.Errorf("FPMPoolPath() = %q", got)
+	}
+	pool := filepath.Join(dir, "pool.conf")
+	if err := WriteFPMPool(pool, sc); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(pool)
+	if !strings.HasPrefix(string(data), "[locwp-work-11001]") {
+		t.Errorf("pool header = %q", strings.SplitN(string(data), "\n", 2)[0])
+	}
+	if !strings.Contains(string(data), "env LOCWP_HOME="+dir+" LOCWP_PROFILE=work ") {
+		t.Error("sendmail_path does not carry the profile environment")
+	}
+
+	reload := CaddyReloadCmd()
+	if strings.Contains(reload, "brew services") || !strings.Contains(reload, "--address localhost:2020") {
+		t.Errorf("CaddyReloadCmd() = %q, want the profile's own Caddy", reload)
+	}
+	if got := CaddyfilePath(); got != filepath.Join(dir, "profiles", "work", "Caddyfile") {
+		t.Errorf("CaddyfilePath() = %q", got)
+	}
+}
+
+func TestWritePawlWorkflows_ManagedTools(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCWP_HOME", dir)
+	bin := filepath.Join(dir, "bin")
+	os.MkdirAll(bin, 0755)
+	lock := tools.Lock{}
+	for name, file := range map[string]string{tools.Caddy: "caddy", tools.WPCLI: "wp-cli.phar"} {
+		path := filepath.Join(bin, file)
+		os.WriteFile(path, nil, 0755)
+		lock[name] = tools.Entry{Version: "1.0.0", Path: path}
+	}
+	if err := tools.WriteLock(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := testSiteConfig(dir)
+	workflowDir := filepath.Join(dir, "workflows")
+	os.MkdirAll(workflowDir, 0755)
+	if err := WritePawlWorkflows(workflowDir, sc); err != nil {
+		t.Fatalf("WritePawlWorkflows() error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workflowDir, "provision.json"))
+	var cfg pawlConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Vars["wp_bin"] != filepath.Join(bin, "wp-cli.phar") || cfg.Vars["caddy_bin"] != filepath.Join(bin, "caddy") {
+		t.Errorf("vars = %v, want the managed tools", cfg.Vars)
+	}
+	if !strings.HasPrefix(cfg.Vars["caddy_reload"], filepath.Join(bin, "caddy")+" reload") {
+		t.Errorf("caddy_reload = %q, want the managed Caddy", cfg.Vars["caddy_reload"])
+	}
+	if strings.Contains(string(data), "$(which wp)") {
+		t.Error("workflow still runs wp from PATH")
+	}
+}
+
+func TestMergeCaddyImport(t *testing.T) {
+	line := "import /home/u/.locwp/caddy/sites/*.caddy"
+	user := "example.com {\n\treverse_proxy :3000\n}\n"
+
+	merged := MergeCaddyImport(user, line)
+	if !strings.HasPrefix(merged, user) || !strings.Contains(merged, caddyBlockBegin+"\n"+line+"\n"+caddyBlockEnd) {
+		t.Errorf("MergeCaddyImport() =\n%s", merged)
+	}
+	if again := MergeCaddyImport(merged, line); again != merged {
+		t.Errorf("merging twice changed the file:\n%s", again)
+	}
+	if moved := MergeCaddyImport(merged, "import /other/*.caddy"); strings.Contains(moved, line) || strings.Count(moved, caddyBlockBegin) != 1 {
+		t.Errorf("block not replaced:\n%s", moved)
+	}
+
+	rest, found := StripCaddyImport(merged)
+	if !found || rest != user {
+		t.Errorf("StripCaddyImport() = %q, %v; want the user's content back", rest, found)
+	}
+	if _, found := StripCaddyImport(user); found {
+		t.Error("StripCaddyImport() found a block in an unmanaged file")
+	}
+
+	// The unmarked file written by older versions is converted.
+	legacy := "{\n\tauto_https off\n}\n\n" + line + "\n"
+	converted := MergeCaddyImport(legacy, line)
+	if strings.Count(converted, line) != 1 || !strings.Contains(converted, caddyBlockBegin) {
+		t.Errorf("legacy file not converted:\n%s", converted)
+	}
+
+	if rest, found := stripLocwp(legacy, line); !found || !onlyLocwpOptions(rest) {
+		t.Errorf("stripLocwp(legacy) = %q, %v; want only the old global options left", rest, found)
+	}
+	if onlyLocwpOptions(user) {
+		t.Error("onlyLocwpOptions() true for a file serving other sites")
+	}
+
+	if got := MergeCaddyImport("", line); got != caddyBlockBegin+"\n"+line+"\n"+caddyBlockEnd+"\n" {
+		t.Errorf("MergeCaddyImport(\"\") = %q", got)
+	}
+}
+
+func TestWriteCaddyfile_Owned(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCWP_HOME", dir)
+	t.Setenv(config.ProfileEnv, "work")
+	t.Setenv("PATH", "") // no caddy to validate with
+
+	if !OwnsCaddyfile() {
+		t.Fatal("a named profile should own its Caddyfile")
+	}
+	if err := WriteCaddyfile(); err != nil {
+		t.Fatalf("WriteCaddyfile() error: %v", err)
+	}
+	data, err := os.ReadFile(CaddyfilePath())
+	if err != nil || !strings.Contains(string(data), "admin localhost:2020") || !strings.Contains(string(data), CaddyImportLine()) {
+		t.Errorf("Caddyfile = %q, %v", data, err)
+	}
+	if err := RestoreCaddyfile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(CaddyfilePath()); !os.IsNotExist(err) {
+		t.Error("RestoreCaddyfile() left locwp's own Caddyfile")
+	}
+}
+
+func TestWriteCaddyfile_ModeSwitch(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCWP_HOME", filepath.Join(dir, "home"))
+	t.Setenv("HOMEBREW_PREFIX", filepath.Join(dir, "brew"))
+	t.Setenv("LOCWP_PKG", "brew")
+	t.Setenv("PATH", "") // no caddy to validate with
+
+	shared := filepath.Join(dir, "brew", "etc", "Caddyfile")
+	os.MkdirAll(filepath.Dir(shared), 0755)
+	user := "example.com {\n\treverse_proxy :3000\n}\n"
+	os.WriteFile(shared, []byte(user), 0644)
+
+	if err := WriteCaddyfile(); err != nil {
+		t.Fatalf("WriteCaddyfile() error: %v", err)
+	}
+	if m, ok := RecordedCaddyMode(); !ok || m.Shared != shared {
+		t.Errorf("RecordedCaddyMode() = %+v, %v; want the shared Caddyfile", m, ok)
+	}
+	if !HasCaddyImport(shared) {
+		t.Fatal("shared Caddyfile has no import block")
+	}
+
+	// Switching to locwp's own instance takes the block out again.
+	t.Setenv("LOCWP_PKG", "manual")
+	if err := WriteCaddyfile(); err != nil {
+		t.Fatalf("WriteCaddyfile() error: %v", err)
+	}
+	if data, _ := os.ReadFile(shared); string(data) != user {
+		t.Errorf("shared Caddyfile after the switch = %q, want the user's content", data)
+	}
+	if m, ok := RecordedCaddyMode(); !ok || m.Shared != "" {
+		t.Errorf("RecordedCaddyMode() = %+v, %v; want own instance", m, ok)
+	}
+
+	// A block left by an install from before the record is still found.
+	os.WriteFile(shared, []byte(MergeCaddyImport(user, CaddyImportLine())), 0644)
+	t.Setenv("LOCWP_PKG", "brew")
+	os.Remove(filepath.Join(dir, "home", "caddy", "mode.json"))
+	if got := SharedCaddyfiles(); len(got) != 1 || got[0] != shared {
+		t.Errorf("SharedCaddyfiles() = %v", got)
+	}
+	if err := RestoreCaddyfile(); err != nil {
+		t.Fatal(err)
+	}
+	if HasCaddyImport(shared) {
+		t.Error("RestoreCaddyfile() left the block in the shared Caddyfile")
+	}
+}
+
+func TestWriteWPCLIConfig(t *testing.T) {
+	dir := t.TempDir()
+	sc := &site.Config{Port: 10001, SiteDir: dir, WPRoot: filepath.Join(dir, "my site")}
+	if err := WriteWPCLIConfig(sc); err != nil {
+		t.Fatalf("WriteWPCLIConfig() error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "wp-cli.yml"))
+	want := "path: \"" + sc.WPRoot + "\"\nurl: \"http://localhost:10001\"\n"
+	if !strings.HasSuffix(string(data), want) {
+		t.Errorf("wp-cli.yml =\n%s\nwant it to end with\n%s", data, want)
+	}
+}
+
+func TestMergeWPCLIAliases(t *testing.T) {
+	user := "color: false\n"
+	a := &site.Config{Port: 10001, WPRoot: "/a", Tags: []string{"client-a", "2024"}}
+	b := &site.Config{Port: 10002, WPRoot: "/b", Tags: []string{"client-a"}}
+
+	merged := MergeWPCLIAliases(user, []*site.Config{a, b})
+	for _, want := range []string{
+		user + "\n# BEGIN locwp aliases (managed",
+		"@10001:\n  path: \"/a\"\n  url: \"http://localhost:10001\"\n",
+		"@client-a:\n  - @10001\n  - @10002\n",
+	} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("MergeWPCLIAliases() =\n%s\nmissing %q", merged, want)
+		}
+	}
+	if strings.Contains(merged, "@2024:") {
+		t.Errorf("numeric tag became an alias group:\n%s", merged)
+	}
+	if again := MergeWPCLIAliases(merged, []*site.Config{a, b}); again != merged {
+		t.Errorf("merging twice changed the file:\n%s", again)
+	}
+
+	// Each profile keeps its own block.
+	t.Setenv("LOCWP_PROFILE", "work")
+	work := MergeWPCLIAliases(merged, []*site.Config{{Port: 11001, WPRoot: "/w"}})
+	if !strings.Contains(work, "@10001:") || !strings.Contains(work, "@work-11001:") {
+		t.Errorf("profile block replaced the default one:\n%s", work)
+	}
+	if again := MergeWPCLIAliases(work, nil); again != merged {
+		t.Errorf("removing the profile's sites =\n%s\nwant\n%s", again, merged)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	t.Setenv(WPCLIConfigEnv, path)
+	os.WriteFile(path, []byte(work), 0644)
+	if !HasWPCLIAliases() {
+		t.Error("HasWPCLIAliases() = false")
+	}
+	if err := RemoveWPCLIAliases(); err != nil {
+		t.Fatalf("RemoveWPCLIAliases() error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != user {
+		t.Errorf("after RemoveWPCLIAliases() = %q, want %q", data, user)
+	}
+
+	// A file locwp created goes away with its last alias.
+	os.Remove(path)
+	t.Setenv("LOCWP_PROFILE", "")
+	if err := WriteWPCLIAliases([]*site.Config{a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteWPCLIAliases(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("empty WP-CLI config left behind")
+	}
+}
+
+func TestSiteEnv(t *testing.T) {
+	dir := t.TempDir()
+	sc := &site.Config{Port: 10001, SiteDir: dir, WPRoot: filepath.Join(dir, "wordpress")}
+	environ := []string{"HOME=/home/u", "PATH=/usr/bin", "WP_ROOT=/other", "WP_DB_PATH=/other.sqlite"}
+
+	env := SiteEnv(environ, sc, "/opt/php@8.1/bin/php")
+	got := map[string]string{}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if _, dup := got[k]; dup {
+			t.Errorf("%s set twice", k)
+		}
+		got[k] = v
+	}
+	want := map[string]string{
+		"HOME":       "/home/u",
+		"PATH":       filepath.Join(dir, "bin") + ":/opt/php@8.1/bin:/usr/bin",
+		"WP_ROOT":    sc.WPRoot,
+		"WP_URL":     "http://localhost:10001",
+		"WP_DB_PATH": sc.DBPath(),
+		"WP_CLI_PHP": "/opt/php@8.1/bin/php",
+		"LOCWP_PORT": "10001",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	sc.DBType = site.DBMySQL
+	for _, kv := range SiteEnv(environ, sc, "/usr/bin/php") {
+		if strings.HasPrefix(kv, "WP_DB_PATH=") {
+			t.Errorf("MySQL site has %s", kv)
+		}
+	}
+}
+
+func TestWriteSiteBin(t *testing.T) {
+	dir := t.TempDir()
+	sc := &site.Config{Port: 10001, SiteDir: dir}
+	if err := WriteSiteBin(sc, "/usr/bin/php8.1", "/opt/it's/wp"); err != nil {
+		t.Fatalf("WriteSiteBin() error: %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "bin", "php")); target != "/usr/bin/php8.1" {
+		t.Errorf("php -> %q", target)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "bin", "wp"))
+	if !strings.Contains(string(data), `exec '/usr/bin/php8.1' -d memory_limit=512M '/opt/it'\''s/wp' "$@"`) {
+		t.Errorf("wp shim =\n%s", data)
+	}
+
+	// Rewriting follows a PHP version change.
+	if err := WriteSiteBin(sc, "/usr/bin/php8.3", ""); err != nil {
+		t.Fatalf("WriteSiteBin() again error: %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "bin", "php")); target != "/usr/bin/php8.3" {
+		t.Errorf("php -> %q after rewrite", target)
+	}
 }
@@ -0,0 +1,178 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yansircc/locwp/internal/config"
+	"github.com/yansircc/locwp/internal/site"
+)
+
+// WPCLIConfigEnv overrides where WP-CLI, and so locwp, looks for the
+// global WP-CLI config.
+const WPCLIConfigEnv = "WP_CLI_CONFIG_PATH"
+
+// Markers around the aliases locwp adds to the global WP-CLI config, one
+// block per profile.
+const (
+	wpcliBlockBegin = "# BEGIN locwp aliases"
+	wpcliBlockEnd   = "# END locwp aliases"
+)
+
+// WPCLIConfigPath returns the site's wp-cli.yml. WP-CLI finds it when run
+// from anywhere under the site directory.
+func WPCLIConfigPath(sc *site.Config) string {
+	return filepath.Join(sc.SiteDir, "wp-cli.yml")
+}
+
+// WriteWPCLIConfig writes the site's wp-cli.yml with its WordPress path
+// and URL.
+func WriteWPCLIConfig(sc *site.Config) error {
+	conf := fmt.Sprintf("# Generated by locwp.\npath: %s\nurl: %s\n", yamlString(sc.WPRoot), yamlString(sc.URL()))
+	return os.WriteFile(WPCLIConfigPath(sc), []byte(conf), 0644)
+}
+
+// WPCLIGlobalConfigPath returns the user's global WP-CLI config.
+func WPCLIGlobalConfigPath() string {
+	if p := os.Getenv(WPCLIConfigEnv); p != "" {
+		return p
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".wp-cli", "config.yml")
+}
+
+// WPCLIAlias returns the name of a site's WP-CLI alias, without the "@":
+// its port, prefixed with the profile name outside the default profile.
+func WPCLIAlias(port int) string {
+	return aliasName(fmt.Sprint(port))
+}
+
+func aliasName(name string) string {
+	if p := config.ProfileName(); p != "" {
+		return p + "-" + name
+	}
+	return name
+}
+
+var numeric = regexp.MustCompile(`^[0-9]+$`)
+
+// MergeWPCLIAliases returns a global WP-CLI config with the active
+// profile's block of aliases replaced by one for sites: an alias per site,
+// and an alias group per tag so that `wp @tag` runs on all of them.
+// Other profiles' blocks and everything else in the file are kept.
+func MergeWPCLIAliases(existing string, sites []*site.Config) string {
+	begin, end := wpcliMarkers()
+	rest, _ := stripBlock(existing, begin, end)
+	rest = strings.TrimRight(rest, "\n\t ")
+	if len(sites) == 0 {
+		if rest == "" {
+			return ""
+		}
+		return rest + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(begin + "\n")
+	groups := map[string][]string{}
+	var tags []string
+	for _, sc := range sites {
+		fmt.Fprintf(&b, "@%s:\n  path: %s\n  url: %s\n", WPCLIAlias(sc.Port), yamlString(sc.WPRoot), yamlString(sc.URL()))
+		for _, t := range sc.Tags {
+			// A numeric tag would clash with the port aliases.
+			if numeric.MatchString(t) {
+				continue
+			}
+			if groups[t] == nil {
+				tags = append(tags, t)
+			}
+			groups[t] = append(groups[t], "@"+WPCLIAlias(sc.Port))
+		}
+	}
+	for _, t := range tags {
+		fmt.Fprintf(&b, "@%s:\n", aliasName(t))
+		for _, a := range groups[t] {
+			fmt.Fprintf(&b, "  - %s\n", a)
+		}
+	}
+	b.WriteString(end + "\n")
+
+	if rest == "" {
+		return b.String()
+	}
+	return rest + "\n\n" + b.String()
+}
+
+// WriteWPCLIAliases updates the active profile's aliases in the global
+// WP-CLI config. The file is only written when the aliases change.
+func WriteWPCLIAliases(sites []*site.Config) error {
+	path := WPCLIGlobalConfigPath()
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := MergeWPCLIAliases(string(existing), sites)
+	if content == string(existing) {
+		return nil
+	}
+	if content == "" {
+		return os.Remove(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// HasWPCLIAliases reports whether the global WP-CLI config holds aliases
+// of any profile.
+func HasWPCLIAliases() bool {
+	data, err := os.ReadFile(WPCLIGlobalConfigPath())
+	return err == nil && strings.Contains(string(data), wpcliBlockBegin)
+}
+
+// RemoveWPCLIAliases takes every profile's aliases out of the global
+// WP-CLI config, and removes the file if nothing else is left.
+func RemoveWPCLIAliases() error {
+	path := WPCLIGlobalConfigPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	content, found := string(data), false
+	for {
+		rest, ok := stripBlock(content, wpcliBlockBegin, wpcliBlockEnd)
+		if !ok {
+			break
+		}
+		content, found = rest, true
+	}
+	if !found {
+		return nil
+	}
+	if strings.TrimSpace(content) == "" {
+		return os.Remove(path)
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// wpcliMarkers returns the markers of the active profile's alias block.
+func wpcliMarkers() (begin, end string) {
+	if p := config.ProfileName(); p != "" {
+		return wpcliBlockBegin + ": profile " + p + " (managed by locwp, do not edit)", wpcliBlockEnd + ": profile " + p
+	}
+	return wpcliBlockBegin + " (managed by locwp, do not edit)", wpcliBlockEnd
+}
+
+// yamlString quotes s as a YAML double-quoted scalar, which JSON strings
+// are.
+func yamlString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,332 @@
+// Package tools installs pinned, checksum-verified Caddy and WP-CLI
+// binaries into LOCWP_HOME/bin, so every machine runs the same versions
+// instead of whatever happens to be on PATH.
+//
+// Downloads are verified against checksums that come with locwp itself or
+// from the lockfile, never against checksum files fetched from the same
+// place as the download: a tampered mirror would serve matching ones.
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yansircc/locwp/internal/config"
+)
+
+// Tool names, as used in the lockfile.
+const (
+	Caddy = "caddy"
+	WPCLI = "wp-cli"
+)
+
+// Versions installed by `setup --tools` on a fresh LOCWP_HOME and by
+// `setup --upgrade-tools`. Bumping them is a deliberate change.
+var Pinned = map[string]string{
+	Caddy: "2.8.4",
+	WPCLI: "2.11.0",
+}
+
+// PinnedSHA512 holds the SHA-512 of each release asset of the Pinned
+// versions, for every supported platform, copied from the upstream
+// checksum files (caddy_<v>_checksums.txt, wp-cli-<v>.phar.sha512) when a
+// pin is bumped. Other versions need a checksum given to or confirmed by
+// `setup --upgrade-tools`.
+var PinnedSHA512 = map[string]string{}
+
+// MirrorEnv names a directory holding release assets under their upstream
+// names, used instead of downloading.
+const MirrorEnv = "LOCWP_TOOLS_MIRROR"
+
+// Release download locations; variables so tests can point them at a
+// local server.
+var (
+	caddyReleaseURL = "https://github.com/caddyserver/caddy/releases/download/v%s/%s"
+	wpcliReleaseURL = "https://github.com/wp-cli/wp-cli/releases/download/v%s/%s"
+)
+
+// Entry records an installed tool in the lockfile.
+type Entry struct {
+	Version string `json:"version"`
+	// Asset is the upstream file the tool came from and SHA512 its
+	// verified checksum.
+	Asset  string `json:"asset"`
+	SHA512 string `json:"sha512"`
+	// Path is the installed executable.
+	Path string `json:"path"`
+}
+
+// Lock maps tool names to what is installed.
+type Lock map[string]Entry
+
+// Dir returns LOCWP_HOME/bin, shared by all profiles.
+func Dir() string {
+	return filepath.Join(config.RootDir(), "bin")
+}
+
+// LockPath returns the lockfile recording installed tool versions.
+func LockPath() string {
+	return filepath.Join(Dir(), "tools.lock.json")
+}
+
+// ReadLock loads the lockfile; a missing one is an empty Lock.
+func ReadLock() (Lock, error) {
+	data, err := os.ReadFile(LockPath())
+	if os.IsNotExist(err) {
+		return Lock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock := Lock{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", LockPath(), err)
+	}
+	return lock, nil
+}
+
+// WriteLock saves the lockfile.
+func WriteLock(lock Lock) error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(LockPath(), append(data, '\n'), 0644)
+}
+
+// Managed returns the path of a locked, installed tool, or "".
+func Managed(name string) string {
+	lock, err := ReadLock()
+	if err != nil {
+		return ""
+	}
+	e, ok := lock[name]
+	if !ok {
+		return ""
+	}
+	if _, err := os.Stat(e.Path); err != nil {
+		return ""
+	}
+	return e.Path
+}
+
+// CaddyBin returns the managed Caddy binary, or "caddy" from PATH.
+func CaddyBin() string {
+	if p := Managed(Caddy); p != "" {
+		return p
+	}
+	return "caddy"
+}
+
+// WPBin returns the managed wp-cli.phar, or "wp" from PATH.
+func WPBin() string {
+	if p := Managed(WPCLI); p != "" {
+		return p
+	}
+	return "wp"
+}
+
+// Install downloads version of tool (or takes it from mirror, if not ""),
+// verifies it against want and installs it into Dir. With no want, the
+// downloaded file's checksum must be accepted by confirm, if not nil.
+func Install(tool, version, mirror, want string, confirm func(asset, sum string) bool) (Entry, error) {
+	asset, err := assetName(tool, version)
+	if err != nil {
+		return Entry{}, err
+	}
+	src := source{tool: tool, version: version, mirror: mirror}
+
+	data, err := src.fetch(asset)
+	if err != nil {
+		return Entry{}, err
+	}
+	sum := sha512.Sum512(data)
+	got := hex.EncodeToString(sum[:])
+	switch {
+	case want != "":
+		if !strings.EqualFold(got, want) {
+			return Entry{}, fmt.Errorf("%s: checksum mismatch: got %s, want %s", asset, got, want)
+		}
+	case confirm == nil || !confirm(asset, got):
+		return Entry{}, fmt.Errorf("%s: no known checksum; give the one published with the release to confirm it", asset)
+	}
+
+	if tool == Caddy {
+		if data, err = extractTarGz(data, "caddy"); err != nil {
+			return Entry{}, fmt.Errorf("%s: %w", asset, err)
+		}
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return Entry{}, err
+	}
+	path := filepath.Join(Dir(), binName(tool))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0755); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Entry{}, err
+	}
+	return Entry{Version: version, Asset: asset, SHA512: got, Path: path}, nil
+}
+
+// Versions returns the version to install for each tool: the locked one,
+// or the pinned one for tools not yet installed or when upgrading.
+func Versions(lock Lock, upgrade bool) map[string]string {
+	versions := map[string]string{}
+	for tool, v := range Pinned {
+		if e, ok := lock[tool]; ok && !upgrade {
+			v = e.Version
+		}
+		versions[tool] = v
+	}
+	return versions
+}
+
+// Ensure installs each tool at the given version unless the lockfile
+// already has it installed, and records the result in the lockfile.
+// Downloads must match the locked checksum of a locked version, else the
+// pinned one of a pinned version, else sums[tool]; confirm is asked about
+// any other. A checksum in sums that contradicts the known one is refused.
+func Ensure(versions, sums map[string]string, mirror string, confirm func(asset, sum string) bool) (Lock, error) {
+	lock, err := ReadLock()
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range []string{Caddy, WPCLI} {
+		v, ok := versions[tool]
+		if !ok {
+			continue
+		}
+		e, locked := lock[tool]
+		if locked && e.Version == v {
+			if _, err := os.Stat(e.Path); err == nil {
+				continue
+			}
+		}
+		want, from := "", ""
+		if locked && e.Version == v {
+			want, from = e.SHA512, "the lockfile"
+		} else if asset, err := assetName(tool, v); err == nil && PinnedSHA512[asset] != "" {
+			want, from = PinnedSHA512[asset], "the pinned checksum"
+		}
+		if given := sums[tool]; given != "" {
+			if want != "" && !strings.EqualFold(given, want) {
+				return nil, fmt.Errorf("install %s %s: checksum %s does not match %s, %s", tool, v, given, from, want)
+			}
+			want = given
+		}
+		entry, err := Install(tool, v, mirror, want, confirm)
+		if err != nil {
+			return nil, fmt.Errorf("install %s %s: %w", tool, v, err)
+		}
+		lock[tool] = entry
+		if err := WriteLock(lock); err != nil {
+			return nil, err
+		}
+	}
+	return lock, nil
+}
+
+// Platforms locwp supports, each with a Caddy release; PinnedSHA512 needs
+// an entry for every one.
+var platforms = [][2]string{
+	{"darwin", "amd64"}, {"darwin", "arm64"},
+	{"linux", "amd64"}, {"linux", "arm64"},
+}
+
+// assetName returns the release asset of a tool for this platform.
+func assetName(tool, version string) (string, error) {
+	return platformAsset(tool, version, runtime.GOOS, runtime.GOARCH)
+}
+
+// platformAsset returns the release asset of a tool for goos/goarch.
+func platformAsset(tool, version, goos, goarch string) (string, error) {
+	switch tool {
+	case Caddy:
+		osName := map[string]string{"darwin": "mac", "linux": "linux"}[goos]
+		if osName == "" || (goarch != "amd64" && goarch != "arm64") {
+			return "", fmt.Errorf("no Caddy release for %s/%s", goos, goarch)
+		}
+		return fmt.Sprintf("caddy_%s_%s_%s.tar.gz", version, osName, goarch), nil
+	case WPCLI:
+		return fmt.Sprintf("wp-cli-%s.phar", version), nil
+	}
+	return "", fmt.Errorf("unknown tool %q", tool)
+}
+
+func binName(tool string) string {
+	if tool == WPCLI {
+		return "wp-cli.phar"
+	}
+	return tool
+}
+
+// source fetches release files from a mirror directory or upstream.
+type source struct {
+	tool, version, mirror string
+}
+
+func (s source) fetch(name string) ([]byte, error) {
+	if s.mirror != "" {
+		data, err := os.ReadFile(filepath.Join(s.mirror, name))
+		if err != nil {
+			return nil, fmt.Errorf("mirror: %w", err)
+		}
+		return data, nil
+	}
+	pattern := caddyReleaseURL
+	if s.tool == WPCLI {
+		pattern = wpcliReleaseURL
+	}
+	url := fmt.Sprintf(pattern, s.version, name)
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// extractTarGz returns the contents of the file called name in a
+// gzipped tarball.
+func extractTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in archive", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
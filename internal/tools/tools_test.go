@@ -0,0 +1,229 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha512Hex(data []byte) string {
+	sum := sha512.Sum512(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func caddyTarball(t *testing.T, binary []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string][]byte{"LICENSE": []byte("license"), "caddy": binary} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// writeMirror fills dir with release files for both tools at version and
+// pins their checksums for the test.
+func writeMirror(t *testing.T, dir, version string) {
+	t.Helper()
+	caddyAsset, err := assetName(Caddy, version)
+	if err != nil {
+		t.Skip(err)
+	}
+	tarball := caddyTarball(t, []byte("#!/bin/sh\necho caddy "+version+"\n"))
+	phar := []byte("#!/usr/bin/env php\n<?php echo 'wp-cli " + version + "';\n")
+	pharAsset, _ := assetName(WPCLI, version)
+
+	files := map[string][]byte{caddyAsset: tarball, pharAsset: phar}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pinSHA512(t, name, sha512Hex(data))
+	}
+}
+
+// pinSHA512 adds a pinned checksum for the duration of the test.
+func pinSHA512(t *testing.T, asset, sum string) {
+	t.Helper()
+	old, ok := PinnedSHA512[asset]
+	PinnedSHA512[asset] = sum
+	t.Cleanup(func() {
+		if ok {
+			PinnedSHA512[asset] = old
+		} else {
+			delete(PinnedSHA512, asset)
+		}
+	})
+}
+
+func TestPinnedSHA512Complete(t *testing.T) {
+	seen := map[string]bool{}
+	for tool, v := range Pinned {
+		for _, p := range platforms {
+			asset, err := platformAsset(tool, v, p[0], p[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[asset] {
+				continue
+			}
+			seen[asset] = true
+			sum := PinnedSHA512[asset]
+			if b, err := hex.DecodeString(sum); err != nil || len(b) != sha512.Size {
+				t.Errorf("PinnedSHA512[%q] = %q, want the SHA-512 of %s %s", asset, sum, tool, v)
+			}
+		}
+	}
+}
+
+func TestEnsureFromMirror(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LOCWP_HOME", home)
+	mirror := t.TempDir()
+	writeMirror(t, mirror, "1.0.0")
+
+	if CaddyBin() != "caddy" || WPBin() != "wp" {
+		t.Fatalf("unmanaged bins = %q, %q", CaddyBin(), WPBin())
+	}
+
+	lock, err := Ensure(map[string]string{Caddy: "1.0.0", WPCLI: "1.0.0"}, nil, mirror, nil)
+	if err != nil {
+		t.Fatalf("Ensure() error: %v", err)
+	}
+	if lock[Caddy].Version != "1.0.0" || lock[WPCLI].SHA512 == "" {
+		t.Errorf("lock = %+v", lock)
+	}
+
+	caddy := filepath.Join(home, "bin", "caddy")
+	if CaddyBin() != caddy {
+		t.Errorf("CaddyBin() = %q, want %q", CaddyBin(), caddy)
+	}
+	data, err := os.ReadFile(caddy)
+	if err != nil || !strings.Contains(string(data), "echo caddy 1.0.0") {
+		t.Errorf("caddy binary = %q, %v; want the file extracted from the tarball", data, err)
+	}
+	if fi, _ := os.Stat(WPBin()); fi == nil || fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("wp-cli.phar should be executable")
+	}
+
+	read, err := ReadLock()
+	if err != nil || read[WPCLI].Path != filepath.Join(home, "bin", "wp-cli.phar") {
+		t.Errorf("ReadLock() = %+v, %v", read, err)
+	}
+	if v := Versions(read, false); v[Caddy] != "1.0.0" {
+		t.Errorf("Versions(lock, false) = %v, want the locked version", v)
+	}
+	if v := Versions(read, true); v[Caddy] != Pinned[Caddy] {
+		t.Errorf("Versions(lock, true) = %v, want the pinned version", v)
+	}
+}
+
+func TestEnsureChecksumMismatch(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	mirror := t.TempDir()
+	writeMirror(t, mirror, "1.0.0")
+	pharAsset, _ := assetName(WPCLI, "1.0.0")
+	os.WriteFile(filepath.Join(mirror, pharAsset), []byte("tampered"), 0644)
+
+	if _, err := Ensure(map[string]string{WPCLI: "1.0.0"}, nil, mirror, nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Ensure() error = %v, want checksum mismatch", err)
+	}
+	if Managed(WPCLI) != "" {
+		t.Error("a tampered tool must not be installed")
+	}
+
+	// A checksum file next to the download proves nothing.
+	os.WriteFile(filepath.Join(mirror, pharAsset+".sha512"), []byte(sha512Hex([]byte("tampered"))), 0644)
+	if _, err := Ensure(map[string]string{WPCLI: "1.0.0"}, nil, mirror, nil); err == nil {
+		t.Fatal("Ensure() trusted the mirror's checksum file")
+	}
+}
+
+func TestEnsureUnpinnedVersion(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	mirror := t.TempDir()
+	phar := []byte("<?php // 9.9.9")
+	os.WriteFile(filepath.Join(mirror, "wp-cli-9.9.9.phar"), phar, 0644)
+	versions := map[string]string{WPCLI: "9.9.9"}
+
+	if _, err := Ensure(versions, nil, mirror, nil); err == nil || !strings.Contains(err.Error(), "no known checksum") {
+		t.Fatalf("Ensure() error = %v, want a refusal without a checksum", err)
+	}
+	var asked string
+	if _, err := Ensure(versions, nil, mirror, func(asset, sum string) bool { asked = sum; return false }); err == nil {
+		t.Fatal("Ensure() installed a checksum that was not confirmed")
+	}
+	if asked != sha512Hex(phar) {
+		t.Errorf("confirm got %q, want the file's checksum", asked)
+	}
+
+	if _, err := Ensure(versions, map[string]string{WPCLI: strings.Repeat("0", 128)}, mirror, nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Ensure() error = %v, want checksum mismatch", err)
+	}
+	if _, err := Ensure(versions, map[string]string{WPCLI: sha512Hex(phar)}, mirror, nil); err != nil {
+		t.Fatalf("Ensure() with the given checksum error: %v", err)
+	}
+}
+
+func TestEnsureLockedChecksum(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	mirror := t.TempDir()
+	writeMirror(t, mirror, "1.0.0")
+	lock, err := Ensure(map[string]string{WPCLI: "1.0.0"}, nil, mirror, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same version republished with different contents is rejected,
+	// and so is a checksum given for it that contradicts the lockfile.
+	os.Remove(lock[WPCLI].Path)
+	pharAsset, _ := assetName(WPCLI, "1.0.0")
+	other := []byte("<?php // republished")
+	os.WriteFile(filepath.Join(mirror, pharAsset), other, 0644)
+	if _, err := Ensure(map[string]string{WPCLI: "1.0.0"}, nil, mirror, nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Ensure() error = %v, want checksum mismatch", err)
+	}
+	if _, err := Ensure(map[string]string{WPCLI: "1.0.0"}, map[string]string{WPCLI: sha512Hex(other)}, mirror, nil); err == nil || !strings.Contains(err.Error(), "lockfile") {
+		t.Fatalf("Ensure() error = %v, want lockfile mismatch", err)
+	}
+}
+
+func TestInstallDownload(t *testing.T) {
+	t.Setenv("LOCWP_HOME", t.TempDir())
+	mirror := t.TempDir()
+	writeMirror(t, mirror, "1.0.0")
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		http.ServeFile(w, r, filepath.Join(mirror, filepath.Base(r.URL.Path)))
+	}))
+	defer srv.Close()
+	old := wpcliReleaseURL
+	wpcliReleaseURL = srv.URL + "/v%s/%s"
+	defer func() { wpcliReleaseURL = old }()
+
+	e, err := Install(WPCLI, "1.0.0", "", PinnedSHA512["wp-cli-1.0.0.phar"], nil)
+	if err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if e.Asset != "wp-cli-1.0.0.phar" {
+		t.Errorf("Asset = %q", e.Asset)
+	}
+	if len(paths) != 1 || paths[0] != "/v1.0.0/wp-cli-1.0.0.phar" {
+		t.Errorf("requested %v, want only the release asset", paths)
+	}
+}
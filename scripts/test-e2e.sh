@@ -88,11 +88,19 @@ fi
 
 assert_http_status "$(site_url 10001)" "200" "HTTP accessible after restart"
 
+"$BINARY" tag 10001 e2e >/dev/null 2>&1 || true
+rc=0; "$BINARY" stop --tag e2e 2>&1 || rc=$?
+assert_exit_ok $rc "stop --tag succeeds"
+rc=0; "$BINARY" start --all 2>&1 || rc=$?
+assert_exit_ok $rc "start --all succeeds"
+sleep 2
+assert_http_status "$(site_url 10001)" "200" "HTTP accessible after bulk restart"
+
 # ─── Test 6: delete ─────────────────────────────────────
 echo ""
 echo -e "${YELLOW}=== Test 6: delete ===${NC}"
 
-"$BINARY" delete 10002 2>&1 || true
+"$BINARY" delete --yes 10002 2>&1 || true
 sleep 1
 
 if [[ ! -d "$LOCWP_HOME/sites/10002" ]]; then
@@ -101,6 +109,12 @@ else
   fail "site 10002 directory should be deleted"
 fi
 
+if "$BINARY" trash 2>&1 | grep -q "10002"; then
+  pass "site 10002 in trash"
+else
+  fail "site 10002 should be in trash"
+fi
+
 assert_file_exists "$LOCWP_HOME/sites/10001/config.json" "site 10001 unaffected"
 
 # ─── Test 7: wp command ─────────────────────────────────
@@ -79,7 +79,7 @@ assert_http_status "$(site_url 10001)" "200" "site works after idempotent ops"
 echo ""
 echo -e "${CYAN}━━━ Edge 4: re-add after deletion ━━━${NC}"
 
-run_cmd "$BINARY" delete 10001
+run_cmd "$BINARY" delete --yes 10001
 assert_exit_ok $? "delete succeeds"
 
 run_cmd "$BINARY" add --pass a23456
@@ -90,7 +90,7 @@ sleep 3
 new_port=$(python3 -c "import os,json; d='$LOCWP_HOME/sites'; ports=[json.load(open(os.path.join(d,x,'config.json')))['port'] for x in os.listdir(d) if os.path.isfile(os.path.join(d,x,'config.json'))]; print(max(ports))")
 assert_http_status "$(site_url $new_port)" "200" "re-added site accessible"
 
-"$BINARY" delete "$new_port" 2>/dev/null || true
+"$BINARY" delete --yes "$new_port" 2>/dev/null || true
 
 # ════════════════════════════════════════════════════════
 # Edge Case 5: rapid add/delete cycles
@@ -103,7 +103,7 @@ for i in 1 2 3; do
   assert_exit_ok $? "cycle $i: add succeeds"
   # Find the latest port
   p=$(python3 -c "import os,json; d='$LOCWP_HOME/sites'; ports=[json.load(open(os.path.join(d,x,'config.json')))['port'] for x in os.listdir(d) if os.path.isfile(os.path.join(d,x,'config.json'))]; print(max(ports))")
-  run_cmd "$BINARY" delete "$p"
+  run_cmd "$BINARY" delete --yes "$p"
   assert_exit_ok $? "cycle $i: delete succeeds"
 done
 